@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+
+	"memo/internal"
+)
+
+func cmdBranch(c internal.Store, args []string) error {
+	p, err := internal.ResolveProject(c, "")
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 0 {
+		branches, err := internal.BranchMemories(c, p)
+		if err != nil {
+			return err
+		}
+		if len(branches) == 0 {
+			fmt.Println("No branch memories in this project.")
+			return nil
+		}
+		current := internal.CurrentBranch()
+		for _, name := range internal.SortedBranches(branches) {
+			marker := " "
+			if name == current {
+				marker = "*"
+			}
+			fmt.Printf("%s %-30s %d memories\n", marker, name, len(branches[name]))
+		}
+		return nil
+	}
+
+	switch args[0] {
+	case "promote":
+		branch := internal.CurrentBranch()
+		if len(args) > 1 {
+			branch = args[1]
+		}
+		if branch == "" || branch == "HEAD" {
+			return fmt.Errorf("usage: memo branch promote <branch>")
+		}
+		n, err := internal.PromoteBranch(c, p, branch)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			fmt.Printf("No memories recorded on %s.\n", branch)
+			return nil
+		}
+		fmt.Printf("Promoted %d memories from %s to project %s.\n", n, branch, p.Name)
+		return nil
+
+	case "cleanup":
+		branches, err := internal.BranchMemories(c, p)
+		if err != nil {
+			return err
+		}
+		gone := 0
+		for _, name := range internal.SortedBranches(branches) {
+			if internal.BranchExists(name) {
+				continue
+			}
+			gone++
+			fmt.Printf("%s (branch deleted):\n", name)
+			for _, m := range branches[name] {
+				fmt.Printf("  [%s] (%s) %s\n", m.ID, m.Type, m.Content)
+			}
+			fmt.Println()
+		}
+		if gone == 0 {
+			fmt.Println("Every branch with memories still exists.")
+			return nil
+		}
+		fmt.Println("Keep them with: memo branch promote <branch>")
+		fmt.Println("Drop them with: memo forget <id>")
+		return nil
+	}
+	return fmt.Errorf("usage: memo branch [promote [BRANCH] | cleanup]")
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// extractProfile pulls the global --profile flag out of the arguments,
+// wherever it appears
+func extractProfile(args []string) ([]string, string) {
+	var rest []string
+	var profile string
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--profile" && i+1 < len(args):
+			profile = args[i+1]
+			i++
+		case strings.HasPrefix(args[i], "--profile="):
+			profile = strings.TrimPrefix(args[i], "--profile=")
+		default:
+			rest = append(rest, args[i])
+		}
+	}
+	return rest, profile
+}
+
+func cmdConfig(args []string) error {
+	if len(args) < 1 || args[0] != "show" {
+		return fmt.Errorf("usage: memo config show")
+	}
+
+	if cfg.Path != "" {
+		fmt.Printf("Config: %s\n\n", cfg.Path)
+	} else {
+		fmt.Println("Config: (no config file, using defaults)")
+		fmt.Println()
+	}
+	for _, kv := range cfg.Settings() {
+		fmt.Printf("%-20s %s\n", kv[0], kv[1])
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+
+	"memo/internal"
+)
+
+func cmdDoctor(c internal.Store, args []string) error {
+	fix := false
+	for _, a := range args {
+		if a == "--fix" {
+			fix = true
+		}
+	}
+
+	// Ask the embedding service for its dimension; fall back to what is stored
+	expectedDim := 0
+	if probe, err := internal.GetDocumentEmbedding("dimension probe"); err == nil {
+		expectedDim = len(probe)
+	}
+
+	d, err := internal.Diagnose(c, expectedDim)
+	if err != nil {
+		return err
+	}
+
+	if len(d.Problems) == 0 {
+		fmt.Println("No problems found.")
+		return nil
+	}
+
+	groups := d.ByKind()
+	for _, kind := range internal.ProblemKinds {
+		problems := groups[kind]
+		if len(problems) == 0 {
+			continue
+		}
+		fmt.Printf("%s (%d)\n", kind, len(problems))
+		for _, p := range problems {
+			if p.ID != "" {
+				fmt.Printf("  [%s] %s\n", p.ID, p.Detail)
+			} else {
+				fmt.Printf("  %s\n", p.Detail)
+			}
+		}
+		fmt.Println()
+	}
+
+	removals := d.Removals()
+	if !fix {
+		if len(removals) > 0 {
+			fmt.Println("--fix would remove:")
+			for _, r := range removals {
+				fmt.Printf("  %s\n", r)
+			}
+			fmt.Println()
+		}
+		fmt.Printf("%d problems. Use --fix to repair them.\n", len(d.Problems))
+		return nil
+	}
+
+	if len(removals) > 0 {
+		fmt.Println("Removing:")
+		for _, r := range removals {
+			fmt.Printf("  %s\n", r)
+		}
+		fmt.Println()
+	}
+
+	fixed, errs := internal.Repair(c, d)
+	for _, err := range errs {
+		fmt.Printf("  Error: %v\n", err)
+	}
+	if len(groups[internal.ProblemSchema]) > 0 {
+		fmt.Println("Schema version is not repaired here - run 'memo migrate'.")
+	}
+	fmt.Printf("Fixed %d of %d problems.\n", fixed, len(d.Problems))
+	return nil
+}
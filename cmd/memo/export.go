@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"memo/internal"
+)
+
+func cmdExport(c internal.Store, args []string) error {
+	var project, output string
+	here := false
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--project":
+			if i+1 < len(args) {
+				project = args[i+1]
+				i++
+			}
+		case "--here":
+			here = true
+		case "-o", "--output":
+			if i+1 < len(args) {
+				output = args[i+1]
+				i++
+			}
+		}
+	}
+
+	var p *internal.Project
+	if project != "" || here {
+		var err error
+		if p, err = internal.ResolveProject(c, project); err != nil {
+			return err
+		}
+	}
+
+	w := io.Writer(os.Stdout)
+	if output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	report, err := internal.Export(c, w, p)
+	if err != nil {
+		return err
+	}
+	// stdout carries the export itself, so the summary goes to stderr
+	fmt.Fprintf(os.Stderr, "Exported %d memories (%d with vectors), %d briefs, %d aliases\n",
+		report.Memories, report.Vectors, report.Briefs, report.Aliases)
+	return nil
+}
+
+func cmdImport(c internal.Store, args []string) error {
+	mode := internal.ImportSkip
+	var path string
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--mode" && i+1 < len(args) {
+			mode = args[i+1]
+			i++
+		} else {
+			path = args[i]
+		}
+	}
+	if path == "" {
+		return fmt.Errorf("usage: memo import <file|-> [--mode skip|overwrite|merge]")
+	}
+
+	r := io.Reader(os.Stdin)
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	report, err := internal.Import(c, r, mode)
+	if report != nil {
+		fmt.Printf("Imported %d new memories; %d skipped, %d overwritten, %d merged (existing IDs)\n",
+			report.Added, report.Skipped, report.Overwritten, report.Merged)
+		fmt.Printf("%d briefs, %d aliases; %d memories re-embedded\n", report.Briefs, report.Aliases, report.Reembedded)
+		if len(report.Unembedded) > 0 {
+			fmt.Fprintf(os.Stderr, "Warning: not embedded: %s - 'memo doctor --fix' will retry\n", strings.Join(report.Unembedded, ", "))
+		}
+	}
+	return err
+}
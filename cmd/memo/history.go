@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"memo/internal"
+)
+
+func cmdHistory(c internal.Store, args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: memo history <id>")
+	}
+
+	m, err := c.Peek(args[0])
+	if err != nil {
+		return err
+	}
+	if len(m.Revisions) == 0 {
+		fmt.Printf("[%s] has not changed since it was created (%s)\n", m.ID, m.Created)
+		return nil
+	}
+
+	fmt.Printf("[%s] %d revisions\n\n", m.ID, len(m.Revisions))
+	for i, r := range m.Revisions {
+		marker := " "
+		if i == len(m.Revisions)-1 {
+			marker = "*"
+		}
+		actor := r.Actor
+		if actor == "" {
+			actor = "-"
+		}
+		fmt.Printf("%s %3d  %s  %-24s %s\n", marker, r.Rev, r.Time, actor, r.Reason)
+		fmt.Printf("        %s\n", r.Content)
+	}
+	return nil
+}
+
+func cmdDiff(c internal.Store, args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: memo diff <id> [rev]")
+	}
+
+	m, err := c.Peek(args[0])
+	if err != nil {
+		return err
+	}
+	// Default to the change that produced the current state
+	rev := 0
+	if n := len(m.Revisions); n >= 2 {
+		rev = m.Revisions[n-2].Rev
+	}
+	if len(args) > 1 {
+		if rev, err = strconv.Atoi(args[1]); err != nil {
+			return fmt.Errorf("invalid revision: %s", args[1])
+		}
+	}
+	r, err := internal.FindRevision(m, rev)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("[%s] revision %d -> current\n\n", m.ID, r.Rev)
+	if r.Content == m.Content {
+		fmt.Println("Content: unchanged")
+	} else {
+		fmt.Printf("Content: %s\n", internal.WordDiff(r.Content, m.Content))
+	}
+	if tags := internal.TagDiff(r.Tags, m.Tags); len(tags) > 0 {
+		fmt.Printf("Tags:    %s\n", strings.Join(tags, " "))
+	}
+	return nil
+}
+
+func cmdRevert(c internal.Store, args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("usage: memo revert <id> <rev>")
+	}
+	rev, err := strconv.Atoi(args[1])
+	if err != nil {
+		return fmt.Errorf("invalid revision: %s", args[1])
+	}
+
+	m, err := internal.RevertMemory(c, args[0], rev)
+	if err != nil {
+		return err
+	}
+
+	reembed(c, m)
+	internal.MarkBriefsStale(c, m)
+
+	fmt.Printf("Reverted [%s] to revision %d: %s\n", m.ID, rev, m.Content)
+	return nil
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"memo/internal"
+)
+
+func cmdIngest(c internal.Store, args []string) error {
+	var path, scope string
+	var tags []string
+	yes, useLLM := false, internal.LLMConfigured()
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--yes", "-y":
+			yes = true
+		case "--no-llm":
+			useLLM = false
+		case "--scope":
+			if i+1 < len(args) {
+				scope = args[i+1]
+				i++
+			}
+		case "--tags":
+			if i+1 < len(args) {
+				tags = strings.Split(args[i+1], ",")
+				i++
+			}
+		default:
+			path = args[i]
+		}
+	}
+	if path == "" {
+		return fmt.Errorf("usage: memo ingest <file|dir> [--yes] [--scope S] [--tags t1,t2] [--no-llm]")
+	}
+
+	cands, err := internal.ReadCandidates(path)
+	if err != nil {
+		return err
+	}
+	if len(cands) == 0 {
+		fmt.Println("Nothing to ingest.")
+		return nil
+	}
+	if err := internal.Classify(cands, useLLM); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: LLM classification failed (%v), using rules\n", err)
+	}
+
+	scopes, err := internal.CurrentScopes(c)
+	if err != nil {
+		return err
+	}
+	project, scopeTags, err := scopes.Placement(scope)
+	if err != nil {
+		return err
+	}
+
+	// Without a terminal to ask, only --yes stores anything
+	review := !yes && isTerminal(os.Stdin)
+	if !yes && !review {
+		for _, cand := range cands {
+			fmt.Printf("(%s) %s\n    from %s\n", cand.Type, cand.Content, cand.Source)
+		}
+		fmt.Printf("\n%d candidates. Rerun with --yes to store them (duplicates are skipped).\n", len(cands))
+		return nil
+	}
+
+	in := bufio.NewReader(os.Stdin)
+	stored, dupes, rejected := 0, 0, 0
+	for i := 0; i < len(cands); i++ {
+		cand := cands[i]
+		fmt.Printf("[%d/%d] (%s) %s\n    from %s\n", i+1, len(cands), cand.Type, cand.Content, cand.Source)
+
+		memTags := append(append([]string{}, tags...), cand.Tags...)
+		answer := "yes"
+		var embedding []float64
+		var embeddingInput string
+		for {
+			embeddingInput = cand.Content
+			if len(memTags) > 0 {
+				embeddingInput = strings.Join(memTags, " ") + " " + cand.Content
+			}
+			var blocked bool
+			embedding, blocked, _ = checkDuplicates(c, cand.Content, embeddingInput)
+			if blocked {
+				answer = "duplicate"
+				break
+			}
+			if review {
+				// Edited content goes through the dedup check again
+				if answer = askIngest(in, &cand); answer == "edit" {
+					continue
+				}
+			}
+			break
+		}
+
+		switch answer {
+		case "duplicate":
+			fmt.Println("  Skipping duplicate.")
+			dupes++
+		case "no":
+			rejected++
+		case "quit":
+			rejected += len(cands) - i
+			i = len(cands)
+		case "all":
+			review = false
+			fallthrough
+		case "yes":
+			memo := &internal.Memory{
+				Type:    cand.Type,
+				Content: cand.Content,
+				Project: project,
+				Tags:    append(append([]string{}, scopeTags...), memTags...),
+				Source:  cand.Source,
+			}
+			if err := storeMemory(c, memo, embeddingInput, embedding); err != nil {
+				return err
+			}
+			fmt.Printf("  Remembered [%s]\n", memo.ID)
+			stored++
+		}
+		fmt.Println()
+	}
+
+	fmt.Printf("Ingested %d of %d candidates (%d duplicates, %d rejected)\n", stored, len(cands), dupes, rejected)
+	return nil
+}
+
+// askIngest asks what to do with one candidate: yes, no, all (this one and
+// every later one), quit, or edit (its content changed)
+func askIngest(in *bufio.Reader, cand *internal.Candidate) string {
+	for {
+		fmt.Print("  [y]es, [n]o, [t]ype, [e]dit, [a]ll remaining, [q]uit? ")
+		answer, err := in.ReadString('\n')
+		if err != nil && answer == "" {
+			return "quit"
+		}
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "y", "yes":
+			return "yes"
+		case "n", "no", "":
+			return "no"
+		case "a", "all":
+			return "all"
+		case "q", "quit":
+			return "quit"
+		case "t", "type":
+			fmt.Printf("  Type (%s): ", strings.Join(internal.MemoryTypes, "/"))
+			t, _ := in.ReadString('\n')
+			t = strings.TrimSpace(t)
+			for _, known := range internal.MemoryTypes {
+				if t == known {
+					cand.Type = t
+				}
+			}
+			fmt.Printf("  (%s) %s\n", cand.Type, cand.Content)
+		case "e", "edit":
+			fmt.Print("  New content: ")
+			content, _ := in.ReadString('\n')
+			if content = strings.TrimSpace(content); content != "" {
+				cand.Content = content
+				return "edit"
+			}
+		}
+	}
+}
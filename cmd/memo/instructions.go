@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"memo/internal"
+)
+
+func cmdSyncInstructions(c internal.Store, args []string) error {
+	var files []string
+	check := false
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--check":
+			check = true
+		case "--target":
+			if i+1 < len(args) {
+				file, ok := internal.InstructionTargets[args[i+1]]
+				if !ok {
+					return fmt.Errorf("unknown target: %s (use claude, agents or cursor)", args[i+1])
+				}
+				files = append(files, filepath.Join(internal.RepoRoot(), file))
+				i++
+			}
+		case "--file":
+			if i+1 < len(args) {
+				files = append(files, args[i+1])
+				i++
+			}
+		}
+	}
+	if len(files) == 0 {
+		files = existingInstructionFiles()
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no CLAUDE.md, AGENTS.md or .cursor/rules here; pick one with --target claude|agents|cursor")
+	}
+
+	p, err := internal.ResolveProject(c, "")
+	if err != nil {
+		return err
+	}
+	body, err := internal.RenderInstructions(c, p)
+	if err != nil {
+		return err
+	}
+
+	var outdated []string
+	for _, file := range files {
+		changed, err := internal.SyncInstructions(file, body, check)
+		if err != nil {
+			return err
+		}
+		switch {
+		case changed && check:
+			outdated = append(outdated, file)
+			fmt.Printf("Out of date: %s\n", file)
+		case changed:
+			fmt.Printf("Updated %s\n", file)
+		default:
+			fmt.Printf("Up to date: %s\n", file)
+		}
+	}
+	if len(outdated) > 0 {
+		return fmt.Errorf("%d instruction files out of date (run memo sync-instructions)", len(outdated))
+	}
+	return nil
+}
+
+// existingInstructionFiles returns the instruction files the repo already
+// has (a .cursor/rules directory counts for the Cursor rule)
+func existingInstructionFiles() []string {
+	root := internal.RepoRoot()
+	var files []string
+	for target, file := range internal.InstructionTargets {
+		check := file
+		if target == "cursor" {
+			check = filepath.Dir(file)
+		}
+		if _, err := os.Stat(filepath.Join(root, check)); err == nil {
+			files = append(files, filepath.Join(root, file))
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+func cmdPin(c internal.Store, args []string, pin bool) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: memo pin|unpin <id...>")
+	}
+	for _, id := range args {
+		var err error
+		if pin {
+			err = c.AddTag(id, internal.PinnedTag)
+		} else {
+			err = internal.UnpinMemory(c, id)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	verb := "Pinned"
+	if !pin {
+		verb = "Unpinned"
+	}
+	fmt.Printf("%s %s (memo sync-instructions to update instruction files)\n", verb, strings.Join(args, ", "))
+	return nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"memo/internal"
+)
+
+func cmdLog(c internal.Store, args []string) error {
+	var q internal.LogQuery
+	var project string
+	here := false
+	limit := 50
+
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--here" {
+			here = true
+			continue
+		}
+		if i+1 >= len(args) {
+			return fmt.Errorf("usage: memo log [--project P|--here] [--id ID] [--actor A] [--since T] [--until T] [--limit N]")
+		}
+		var err error
+		switch args[i] {
+		case "--project":
+			project = args[i+1]
+		case "--id":
+			q.ID = args[i+1]
+		case "--actor":
+			q.Actor = args[i+1]
+		case "--since":
+			q.Since, err = parseLogTime(args[i+1], false)
+		case "--until":
+			q.Until, err = parseLogTime(args[i+1], true)
+		case "--limit":
+			limit, err = strconv.Atoi(args[i+1])
+		default:
+			return fmt.Errorf("unknown flag: %s", args[i])
+		}
+		if err != nil {
+			return err
+		}
+		i++
+	}
+
+	if project != "" || here {
+		p, err := internal.ResolveProject(c, project)
+		if err != nil {
+			return err
+		}
+		q.Projects = p.Names()
+	}
+
+	entries, err := c.ReadLog(q)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		fmt.Println("No log entries.")
+		return nil
+	}
+	if limit > 0 && len(entries) > limit {
+		fmt.Printf("(showing the last %d of %d entries; --limit 0 for all)\n\n", limit, len(entries))
+		entries = entries[len(entries)-limit:]
+	}
+
+	for _, e := range entries {
+		target := e.ID
+		if target == "" {
+			target = internal.ProjectName(e.Project)
+		}
+		fmt.Printf("%s  %-8s %-10s %-24s %s -> %s\n", e.Time, e.Action, target, e.Actor, hashOrNone(e.Before), hashOrNone(e.After))
+		fmt.Printf("    %s\n", e.Command)
+	}
+	return nil
+}
+
+func hashOrNone(h string) string {
+	if h == "" {
+		return "-"
+	}
+	return h
+}
+
+// parseLogTime accepts a date, an RFC 3339 timestamp, or a duration ago
+// such as 90m, 2h or 7d. A bare date means its end when endOfDay is set.
+func parseLogTime(s string, endOfDay bool) (string, error) {
+	now := time.Now().UTC()
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil {
+			return now.AddDate(0, 0, -n).Format(time.RFC3339), nil
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d).Format(time.RFC3339), nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.UTC().Format(time.RFC3339), nil
+	}
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		if endOfDay {
+			t = t.Add(24*time.Hour - time.Second)
+		}
+		return t.Format(time.RFC3339), nil
+	}
+	return "", fmt.Errorf("invalid time %q (use 2026-01-31, RFC 3339, or 2h / 7d)", s)
+}
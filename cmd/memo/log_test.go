@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseLogTime(t *testing.T) {
+	tests := []struct {
+		in       string
+		endOfDay bool
+		want     string
+	}{
+		{"2026-01-31", false, "2026-01-31T00:00:00Z"},
+		{"2026-01-31", true, "2026-01-31T23:59:59Z"},
+		{"2026-01-31T10:00:00Z", true, "2026-01-31T10:00:00Z"},
+		{"2026-01-31T12:00:00+02:00", false, "2026-01-31T10:00:00Z"},
+	}
+	for _, tt := range tests {
+		got, err := parseLogTime(tt.in, tt.endOfDay)
+		if err != nil || got != tt.want {
+			t.Errorf("parseLogTime(%q, %v) = %q, %v, want %q", tt.in, tt.endOfDay, got, err, tt.want)
+		}
+	}
+
+	// Durations count back from now
+	for in, ago := range map[string]time.Duration{"90m": 90 * time.Minute, "2h": 2 * time.Hour, "7d": 7 * 24 * time.Hour} {
+		got, err := parseLogTime(in, false)
+		if err != nil {
+			t.Errorf("parseLogTime(%q): %v", in, err)
+			continue
+		}
+		when, err := time.Parse(time.RFC3339, got)
+		if err != nil {
+			t.Errorf("parseLogTime(%q) = %q, not RFC 3339", in, got)
+			continue
+		}
+		if off := time.Since(when) - ago; off < -time.Minute || off > time.Minute {
+			t.Errorf("parseLogTime(%q) = %s, %s off", in, got, off)
+		}
+	}
+
+	for _, in := range []string{"", "yesterday", "7x", "2026-13-01", "d"} {
+		if got, err := parseLogTime(in, false); err == nil {
+			t.Errorf("parseLogTime(%q) = %q, want an error", in, got)
+		}
+	}
+}
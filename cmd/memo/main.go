@@ -1,8 +1,10 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -10,18 +12,64 @@ import (
 	"memo/internal"
 )
 
+// cfg holds the resolved settings for the selected profile
+var cfg *internal.Config
+
 func main() {
-	if len(os.Args) < 2 {
+	args, profile := extractProfile(os.Args[1:])
+	if len(args) < 1 {
 		printHelp()
 		return
 	}
 
-	cmd := os.Args[1]
-	args := os.Args[2:]
+	cmd := args[0]
+	args = args[1:]
 
-	client := internal.NewClient()
+	var err error
+	cfg, err = internal.LoadConfig(profile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if cmd == "config" {
+		if err := cmdConfig(args); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	client, err := internal.OpenStore(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
 	defer client.Close()
 
+	if cmd != "init" && cmd != "migrate" {
+		warnIfOutdated(client)
+	}
+	internal.StartAudit(strings.Join(append([]string{"memo"}, os.Args[1:]...), " "))
+	if err := runCommand(client, cmd, args); err != nil {
+		if err != errUnknownCommand {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+		os.Exit(1)
+	}
+}
+
+// errUnknownCommand is returned by runCommand after printing the help
+var errUnknownCommand = errors.New("unknown command")
+
+// runCommand runs one command against the store. Commands that change
+// memories are journaled so memo undo can reverse them.
+func runCommand(client internal.Store, cmd string, args []string) error {
+	// sync serve journals each push on its own
+	if undoable[cmd] && !(cmd == "sync" && len(args) > 0 && args[0] == "serve") {
+		internal.BeginUndo(strings.Join(append([]string{cmd}, args...), " "))
+	}
+
 	var err error
 	switch cmd {
 	case "init":
@@ -32,18 +80,48 @@ func main() {
 		err = cmdRecall(client, args)
 	case "similar":
 		err = cmdSimilar(client, args)
+	case "search":
+		err = cmdSearch(client, args)
 	case "context":
 		err = cmdContext(client, args)
+	case "about":
+		err = cmdAbout(client, args)
 	case "list":
 		err = cmdList(client, args)
 	case "get":
 		err = cmdGet(client, args)
 	case "forget":
 		err = cmdForget(client, args)
+	case "trash":
+		err = cmdTrash(client, args)
+	case "restore":
+		err = cmdRestore(client, args)
+	case "undo":
+		err = cmdUndo(client)
+	case "log":
+		err = cmdLog(client, args)
 	case "update":
 		err = cmdUpdate(client, args)
 	case "tag":
 		err = cmdTag(client, args)
+	case "snapshot":
+		err = cmdSnapshot(client, args)
+	case "sync":
+		err = cmdSync(client, args)
+	case "ingest":
+		err = cmdIngest(client, args)
+	case "pin":
+		err = cmdPin(client, args, true)
+	case "unpin":
+		err = cmdPin(client, args, false)
+	case "sync-instructions":
+		err = cmdSyncInstructions(client, args)
+	case "history":
+		err = cmdHistory(client, args)
+	case "diff":
+		err = cmdDiff(client, args)
+	case "revert":
+		err = cmdRevert(client, args)
 	case "related":
 		err = cmdRelated(client, args)
 	case "reindex":
@@ -52,40 +130,76 @@ func main() {
 		err = cmdStats(client)
 	case "projects":
 		err = cmdProjects(client)
+	case "project":
+		err = cmdProject(client, args)
+	case "move":
+		err = cmdMove(client, args)
+	case "branch":
+		err = cmdBranch(client, args)
 	case "prune":
 		err = cmdPrune(client, args)
+	case "stale":
+		err = cmdStale(client, args)
+	case "verify":
+		err = cmdVerify(client, args)
 	case "merge":
 		err = cmdMerge(client, args)
 	case "brief":
 		err = cmdBrief(client, args)
 	case "dedup":
 		err = cmdDedup(client, args)
+	case "export":
+		err = cmdExport(client, args)
+	case "import":
+		err = cmdImport(client, args)
+	case "migrate":
+		err = cmdMigrate(client, args)
+	case "doctor":
+		err = cmdDoctor(client, args)
 	case "help", "-h", "--help":
 		printHelp()
 	default:
 		fmt.Printf("Unknown command: %s\n", cmd)
 		printHelp()
-		os.Exit(1)
+		return errUnknownCommand
 	}
 
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+	// Whatever a command changed before failing can still be undone
+	if undoErr := internal.CommitUndo(client); undoErr != nil {
+		fmt.Fprintf(os.Stderr, "Warning: memo undo will not cover this command (%v)\n", undoErr)
 	}
+	return err
+}
+
+// undoable lists the commands memo undo can reverse
+var undoable = map[string]bool{
+	"remember": true, "update": true, "tag": true, "forget": true, "merge": true,
+	"prune": true, "revert": true, "restore": true, "move": true, "verify": true,
+	"stale": true, "branch": true, "project": true, "import": true,
+	"pin": true, "unpin": true, "ingest": true, "snapshot": true, "sync": true,
+	"doctor": true,
 }
 
-func cmdInit(c *internal.Client) error {
+func cmdInit(c internal.Store) error {
 	fmt.Println("Initializing memo index...")
 	if err := c.Init(); err != nil {
 		return err
 	}
+
+	// A fresh store starts at the current schema; existing data waits for memo migrate
+	if version, err := c.SchemaVersion(); err == nil && version == 0 {
+		if ids, err := c.GetAllMemoryIDs(); err == nil && len(ids) == 0 {
+			c.SetSchemaVersion(internal.SchemaVersion)
+		}
+	}
+
 	fmt.Println("Index created.")
 	return nil
 }
 
-func cmdRemember(c *internal.Client, args []string) error {
+func cmdRemember(c internal.Store, args []string) error {
 	if len(args) < 2 {
-		return fmt.Errorf("usage: memo remember <type> <content> [--tags t1,t2] [--force]")
+		return fmt.Errorf("usage: memo remember <type> <content> [--tags t1,t2] [--scope S] [--file PATH[:SYMBOL]]... [--branch|--no-branch] [--force]")
 	}
 
 	memType := args[0]
@@ -93,12 +207,33 @@ func cmdRemember(c *internal.Client, args []string) error {
 	// Parse content, tags, and flags
 	var contentParts []string
 	var tags []string
+	var scope string
+	var anchors []internal.Anchor
 	force := false
+	branch := cfg.Git.AutoBranch
+	branchFlag := false
 
 	for i := 1; i < len(args); i++ {
 		if args[i] == "--tags" && i+1 < len(args) {
 			tags = strings.Split(args[i+1], ",")
 			i++
+		} else if args[i] == "--scope" && i+1 < len(args) {
+			scope = args[i+1]
+			i++
+		} else if args[i] == "--file" && i+1 < len(args) {
+			anchor, err := internal.ParseAnchor(args[i+1])
+			if err != nil {
+				return err
+			}
+			if !anchor.Exists() {
+				fmt.Fprintf(os.Stderr, "Warning: %s does not exist (yet)\n", anchor.Path)
+			}
+			anchors = append(anchors, anchor)
+			i++
+		} else if args[i] == "--branch" {
+			branch, branchFlag = true, true
+		} else if args[i] == "--no-branch" {
+			branch = false
 		} else if args[i] == "--force" {
 			force = true
 		} else {
@@ -111,6 +246,25 @@ func cmdRemember(c *internal.Client, args []string) error {
 		return fmt.Errorf("content cannot be empty")
 	}
 
+	scopes, err := internal.CurrentScopes(c)
+	if err != nil {
+		return err
+	}
+	project, scopeTags, err := scopes.Placement(scope)
+	if err != nil {
+		return err
+	}
+	if branch && scope != internal.ScopeBranch {
+		// git.auto_branch only applies off the default branch; --branch always does
+		onBranch := scopes.Branch != "" && scopes.Branch != "HEAD"
+		switch {
+		case branchFlag && (project == "" || !onBranch):
+			return fmt.Errorf("--branch needs a project or dir memory stored on a git branch")
+		case project != "" && onBranch && (branchFlag || scopes.Branch != internal.DefaultBranch()):
+			scopeTags = append(scopeTags, "branch:"+scopes.Branch)
+		}
+	}
+
 	// Build embedding input: prepend tags for better semantic signal
 	embeddingInput := content
 	if len(tags) > 0 {
@@ -120,50 +274,8 @@ func cmdRemember(c *internal.Client, args []string) error {
 	// Check for duplicates (unless --force)
 	var embedding []float64
 	if !force {
-		var blocked bool
-		var hasRelated bool
-
-		// Try vector similarity first
-		var err error
-		embedding, err = internal.GetDocumentEmbedding(embeddingInput)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: embedding service unavailable, using text search for dedup\n")
-		} else {
-			dupes, simErr := c.Similar(embedding, 5, "")
-			if simErr != nil {
-				fmt.Fprintf(os.Stderr, "Warning: vector search failed (%v), falling back to text search\n", simErr)
-			} else {
-				for _, d := range dupes {
-					score := parseScore(d.Score)
-					if score >= 0.93 {
-						fmt.Printf("Duplicate: [%s] (%.0f%%) %s\n", d.Memory.ID, score*100, d.Memory.Content)
-						blocked = true
-					} else if score >= 0.85 {
-						fmt.Printf("Similar:   [%s] (%.0f%%) %s\n", d.Memory.ID, score*100, d.Memory.Content)
-						hasRelated = true
-					} else if score >= 0.70 {
-						fmt.Printf("Related:   [%s] (%.0f%%) %s\n", d.Memory.ID, score*100, d.Memory.Content)
-						hasRelated = true
-					}
-				}
-			}
-		}
-
-		// Fall back to text search if embedding failed or vector search failed
-		if embedding == nil || !blocked {
-			textResults, textErr := c.TextSearch(content, 5)
-			if textErr == nil {
-				for _, m := range textResults {
-					if blocked {
-						break
-					}
-					if m.Content == content {
-						fmt.Printf("Duplicate: [%s] (text match) %s\n", m.ID, m.Content)
-						blocked = true
-					}
-				}
-			}
-		}
+		var blocked, hasRelated bool
+		embedding, blocked, hasRelated = checkDuplicates(c, content, embeddingInput)
 
 		if blocked {
 			fmt.Printf("\nSkipping - use --force to save anyway, or memo update <id> to edit existing.\n")
@@ -175,36 +287,115 @@ func cmdRemember(c *internal.Client, args []string) error {
 		}
 	}
 
-	project := internal.GetProject()
-	memo, err := c.Remember(memType, content, tags, project)
+	memo := &internal.Memory{
+		Type:    memType,
+		Content: content,
+		Project: project,
+		Tags:    append(scopeTags, tags...),
+		Anchors: anchors,
+	}
+	if err := storeMemory(c, memo, embeddingInput, embedding); err != nil {
+		return err
+	}
+
+	fmt.Printf("Remembered [%s]: %s\n", memo.ID, content)
+	return nil
+}
+
+// checkDuplicates prints the stored memories a new one would duplicate or
+// resemble. It returns the new memory's embedding (nil if the service is
+// down), whether it is a duplicate, and whether anything merely similar
+// or related was found.
+func checkDuplicates(c internal.Store, content, embeddingInput string) (embedding []float64, blocked, hasRelated bool) {
+	embedding, err := internal.GetDocumentEmbedding(embeddingInput)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: embedding service unavailable, using text search for dedup\n")
+		embedding = nil
+	}
+
+	// Hybrid search catches both paraphrases and shared exact identifiers
+	opts := internal.DefaultSearchOptions()
+	opts.Limit = 5
+	opts.Embedding = embedding
+	results, err := internal.HybridSearch(c, content, opts)
+	if err != nil && embedding != nil {
+		fmt.Fprintf(os.Stderr, "Warning: vector search failed (%v), falling back to text search\n", err)
+		opts.Embedding = nil
+		results, err = internal.HybridSearch(c, content, opts)
+	}
 	if err != nil {
+		return embedding, false, false
+	}
+
+	for _, r := range results {
+		m, score := r.Memory, r.Similarity
+		if m.Content == content {
+			fmt.Printf("Duplicate: [%s] (text match) %s\n", m.ID, m.Content)
+			blocked = true
+		} else if opts.Embedding == nil {
+			continue
+		} else if score >= cfg.Dedup.Duplicate {
+			fmt.Printf("Duplicate: [%s] (%.0f%%) %s\n", m.ID, score*100, m.Content)
+			blocked = true
+		} else if score >= cfg.Dedup.Similar {
+			fmt.Printf("Similar:   [%s] (%.0f%%) %s\n", m.ID, score*100, m.Content)
+			hasRelated = true
+		} else if score >= cfg.Dedup.Related {
+			fmt.Printf("Related:   [%s] (%.0f%%) %s\n", m.ID, score*100, m.Content)
+			hasRelated = true
+		}
+	}
+	return embedding, blocked, hasRelated
+}
+
+// storeMemory records git state on a new memory, stores it and embeds it
+// (using embedding if the dedup check already computed it)
+func storeMemory(c internal.Store, memo *internal.Memory, embeddingInput string, embedding []float64) error {
+	if memo.Project != "" {
+		internal.RecordGitState(memo)
+	}
+	if err := internal.RememberMemory(c, memo); err != nil {
 		return err
 	}
 
 	// Embed synchronously to avoid race conditions between consecutive calls
-	if embedding != nil {
-		c.EmbedMemory(memo.ID, embedding)
-	} else {
-		emb, err := internal.GetDocumentEmbedding(embeddingInput)
-		if err == nil {
-			c.EmbedMemory(memo.ID, emb)
-		}
+	var err error
+	if embedding == nil {
+		embedding, err = internal.GetDocumentEmbedding(embeddingInput)
+	}
+	if err == nil {
+		err = c.EmbedMemory(memo.ID, embedding)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: not embedded (%v) - 'memo doctor --fix' will retry\n", err)
 	}
 
 	// Mark brief as stale so it regenerates on next context call
-	c.MarkBriefStale(project)
-
-	fmt.Printf("Remembered [%s]: %s\n", memo.ID, content)
+	if memo.Project != "" {
+		c.MarkBriefStale(memo.Project)
+	}
 	return nil
 }
 
-func cmdRecall(c *internal.Client, args []string) error {
+// reembed replaces the embedding of a memory whose content changed. It runs
+// synchronously, like storeMemory, so the process cannot exit first.
+func reembed(c internal.Store, m *internal.Memory) {
+	embedding, err := internal.GetDocumentEmbedding(internal.EmbeddingInput(m))
+	if err == nil {
+		err = c.EmbedMemory(m.ID, embedding)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: not embedded (%v) - 'memo doctor --fix' will retry\n", err)
+	}
+}
+
+func cmdRecall(c internal.Store, args []string) error {
 	if len(args) < 1 {
 		return fmt.Errorf("usage: memo recall <query> [limit]")
 	}
 
 	query := args[0]
-	limit := 10
+	limit := cfg.Limits.Recall
 	if len(args) > 1 {
 		if l, err := strconv.Atoi(args[1]); err == nil {
 			limit = l
@@ -223,15 +414,31 @@ func cmdRecall(c *internal.Client, args []string) error {
 	return nil
 }
 
-func cmdSimilar(c *internal.Client, args []string) error {
-	var query string
-	var project string
-	limit := 5
+func cmdSimilar(c internal.Store, args []string) error {
+	var query, typ, tag string
+	here, rerank, explain, expand := false, false, false, false
+	limit := cfg.Limits.Similar
 
 	for i := 0; i < len(args); i++ {
 		switch args[i] {
 		case "--here":
-			project = internal.GetProject()
+			here = true
+		case "--rerank":
+			rerank = true
+		case "--expand":
+			expand = true
+		case "--explain":
+			rerank, explain = true, true
+		case "--type":
+			if i+1 < len(args) {
+				typ = args[i+1]
+				i++
+			}
+		case "--tag":
+			if i+1 < len(args) {
+				tag = args[i+1]
+				i++
+			}
 		case "--limit":
 			if i+1 < len(args) {
 				if l, err := strconv.Atoi(args[i+1]); err == nil {
@@ -247,24 +454,80 @@ func cmdSimilar(c *internal.Client, args []string) error {
 	}
 
 	if query == "" {
-		return fmt.Errorf("usage: memo similar <query> [--here] [--limit N]")
+		return fmt.Errorf("usage: memo similar <query> [--here] [--type T] [--tag T] [--limit N] [--expand] [--rerank] [--explain]")
 	}
 
-	if project != "" {
-		fmt.Printf("Searching for: %s (project: %s)\n", query, project)
+	var filter internal.Filter
+	fetch := limit
+	if here {
+		scopes, err := internal.CurrentScopes(c)
+		if err != nil {
+			return err
+		}
+		filter = scopes.Filter()
+		fetch = limit * 2 // room for nearer scopes to move up
+		fmt.Printf("Searching for: %s (project: %s)\n", query, scopes.Project.Name)
 	} else {
 		fmt.Printf("Searching for: %s\n", query)
 	}
+	filter.Type = typ
+	// The filter holds one tag pattern; under --here that is the scope
+	// chain, so --tag is checked on the results instead
+	var tagged internal.Filter
+	if !here {
+		filter.Tag = tag
+	} else if tag != "" {
+		tagged.Tag = tag
+		fetch *= 3
+	}
+	if rerank {
+		fetch = max(fetch, internal.RerankCandidates())
+	}
 
 	embedding, err := internal.GetEmbedding(query)
 	if err != nil {
 		return err
 	}
 
-	results, err := c.Similar(embedding, limit, project)
+	embeddings := [][]float64{embedding}
+	if expand {
+		// Short queries embed poorly; their rewrites' neighbors fill in
+		embeddings = append(embeddings, expandedEmbeddings(query, explain)...)
+	}
+	results, err := internal.SimilarMerged(c, embeddings, fetch, filter)
 	if err != nil {
 		return err
 	}
+	if tagged.Tag != "" {
+		kept := results[:0]
+		for _, r := range results {
+			if tagged.Matches(r.Memory) {
+				kept = append(kept, r)
+			}
+		}
+		results = kept
+	}
+	if here {
+		internal.RankByScope(results)
+	}
+
+	var placed map[string]internal.Reranked
+	if rerank {
+		results = results[:min(len(results), max(limit, internal.RerankCandidates()))]
+		memos := make([]internal.Memory, len(results))
+		byID := make(map[string]internal.SimilarResult, len(results))
+		for i, r := range results {
+			memos[i] = r.Memory
+			byID[r.Memory.ID] = r
+		}
+		memos, placed = rerankMemories(query, memos, explain)
+		for i, m := range memos {
+			results[i] = byID[m.ID]
+		}
+	}
+	if len(results) > limit {
+		results = results[:limit]
+	}
 
 	fmt.Println()
 	if len(results) == 0 {
@@ -273,29 +536,78 @@ func cmdSimilar(c *internal.Client, args []string) error {
 	}
 
 	for _, r := range results {
-		fmt.Printf("[%s] (%s) (%s) %s\n", r.Memory.ID, r.Score, r.Memory.Type, r.Memory.Content)
+		fmt.Printf("[%s] (%s) (%s) %s\n", r.Memory.ID, r.Score, typeAndScope(r.Memory), r.Memory.Content)
+		if explain {
+			fmt.Printf("    %s\n", explainRerank(placed, r.Memory.ID))
+		}
 	}
 	return nil
 }
 
-func cmdContext(c *internal.Client, args []string) error {
-	limit := 10
-	if len(args) > 0 {
-		if l, err := strconv.Atoi(args[0]); err == nil {
-			limit = l
+func cmdContext(c internal.Store, args []string) error {
+	limit := cfg.Limits.Context
+	var query string
+	rerank, explain, expand := false, false, false
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--file":
+			if i+1 < len(args) {
+				return cmdAbout(c, args[i+1:i+2])
+			}
+		case "--rerank":
+			rerank = true
+		case "--expand":
+			expand = true
+		case "--explain":
+			rerank, explain = true, true
+		default:
+			if l, err := strconv.Atoi(args[i]); err == nil {
+				limit = l
+			} else {
+				query = args[i]
+			}
 		}
 	}
+	if (rerank || expand) && query == "" {
+		return fmt.Errorf("--rerank and --expand need a query: memo context \"query\" --rerank")
+	}
 
-	project := internal.GetProject()
-	fmt.Printf("Context for project: %s\n", project)
+	scopes, err := internal.CurrentScopes(c)
+	if err != nil {
+		return err
+	}
+	p := scopes.Project
+	project := p.Key
+	fmt.Printf("Context for project: %s\n", p.Name)
 	fmt.Println("================================")
 	fmt.Println()
 
-	memos, err := c.Context(project, limit)
+	// Gather the whole scope chain, nearest scope first, or what a query
+	// finds in it, most relevant first
+	var memos []internal.Memory
+	var placed map[string]internal.Reranked
+	if query != "" && rerank {
+		memos, err = searchMemories(c, query, scopes.Filter(), max(limit, internal.RerankCandidates()), expand)
+		memos, placed = rerankMemories(query, memos, explain)
+	} else if query != "" {
+		memos, err = searchMemories(c, query, scopes.Filter(), limit, expand)
+	} else {
+		memos, err = internal.Collect(c.Iterate(scopes.Filter()), 0)
+		internal.SortByScope(memos)
+	}
 	if err != nil {
 		return err
 	}
+	total := len(memos)
+	if limit > 0 && len(memos) > limit {
+		memos = memos[:limit]
+	}
+	hintLegacyProject(c, p)
 
+	if len(memos) == 0 && query != "" {
+		fmt.Printf("No memories here match: %s\n", query)
+		return nil
+	}
 	if len(memos) == 0 {
 		fmt.Println("No memories found for this project.")
 		fmt.Println()
@@ -320,13 +632,72 @@ func cmdContext(c *internal.Client, args []string) error {
 	}
 
 	for _, m := range memos {
-		fmt.Printf("[%s] (%s) %s\n", m.ID, m.Type, m.Content)
+		fmt.Printf("[%s] (%s) %s\n", m.ID, typeAndScope(m), m.Content)
+		if explain {
+			fmt.Printf("    %s\n", explainRerank(placed, m.ID))
+		}
+	}
+	if total > len(memos) && query == "" {
+		fmt.Printf("\n(showing %d of %d; memo context 0 for all)\n", len(memos), total)
+	}
+	return nil
+}
+
+// cmdAbout shows the memories visible here that are anchored to a file,
+// a directory or a glob
+func cmdAbout(c internal.Store, args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: memo about <path|glob>")
+	}
+
+	scopes, err := internal.CurrentScopes(c)
+	if err != nil {
+		return err
+	}
+	filter := scopes.Filter()
+	filter.File = internal.RepoPath(args[0])
+
+	memos, err := internal.Collect(c.Iterate(filter), 0)
+	if err != nil {
+		return err
+	}
+	internal.SortByScope(memos)
+
+	if len(memos) == 0 {
+		fmt.Printf("No memories about %s.\n", filter.File)
+		return nil
+	}
+	fmt.Printf("%d memories about %s\n\n", len(memos), filter.File)
+	for _, m := range memos {
+		fmt.Printf("[%s] (%s) %s\n", m.ID, typeAndScope(m), m.Content)
+		for _, a := range m.Anchors {
+			if a.Matches(filter.File) {
+				fmt.Printf("    @ %s\n", a)
+			}
+		}
 	}
 	return nil
 }
 
-func cmdBrief(c *internal.Client, args []string) error {
-	project := internal.GetProject()
+// typeAndScope labels a memory with its type, plus its scope unless it is
+// an ordinary project memory
+func typeAndScope(m internal.Memory) string {
+	switch scope := internal.ScopeOf(m); scope {
+	case internal.ScopeProject:
+	case internal.ScopeBranch:
+		return m.Type + ", " + scope + " " + internal.BranchOf(m)
+	default:
+		return m.Type + ", " + scope
+	}
+	return m.Type
+}
+
+func cmdBrief(c internal.Store, args []string) error {
+	p, err := internal.ResolveProject(c, "")
+	if err != nil {
+		return err
+	}
+	project := p.Key
 
 	// memo brief --refresh forces regeneration
 	forceRefresh := false
@@ -341,7 +712,7 @@ func cmdBrief(c *internal.Client, args []string) error {
 	}
 
 	if c.IsBriefStale(project) {
-		allMemos, err := c.Context(project, 100)
+		allMemos, err := internal.Collect(c.Iterate(internal.Filter{Projects: p.Names()}), 0)
 		if err != nil {
 			return err
 		}
@@ -352,12 +723,15 @@ func cmdBrief(c *internal.Client, args []string) error {
 
 		currentBrief, _ := c.GetBrief(project)
 		fmt.Println("Generating brief...")
-		brief, err := internal.GenerateBrief(project, currentBrief, allMemos)
+		brief, err := internal.GenerateBrief(p.Name, currentBrief, allMemos)
 		if err != nil {
 			return err
 		}
 		c.SetBrief(project, brief)
 		c.MarkBriefFresh(project)
+		if err := internal.AuditBrief(c, project, currentBrief, brief); err != nil {
+			return err
+		}
 	}
 
 	brief, err := c.GetBrief(project)
@@ -366,83 +740,98 @@ func cmdBrief(c *internal.Client, args []string) error {
 		return nil
 	}
 
-	fmt.Printf("Brief for %s:\n\n%s\n", project, brief)
+	fmt.Printf("Brief for %s:\n\n%s\n", p.Name, brief)
 	return nil
 }
 
-func cmdList(c *internal.Client, args []string) error {
-	var typeFilter, tagFilter, projectFilter string
+func cmdList(c internal.Store, args []string) error {
+	var filter internal.Filter
+	var project string
+	here := false
+	limit := cfg.Limits.List
 
 	for i := 0; i < len(args); i++ {
 		switch args[i] {
 		case "--type":
 			if i+1 < len(args) {
-				typeFilter = args[i+1]
+				filter.Type = args[i+1]
 				i++
 			}
 		case "--tag":
 			if i+1 < len(args) {
-				tagFilter = args[i+1]
+				filter.Tag = args[i+1]
 				i++
 			}
 		case "--project":
 			if i+1 < len(args) {
-				projectFilter = args[i+1]
+				project = args[i+1]
+				i++
+			}
+		case "--file":
+			if i+1 < len(args) {
+				filter.File = internal.RepoPath(args[i+1])
 				i++
 			}
 		case "--here":
-			projectFilter = internal.GetProject()
+			here = true
+		case "--limit":
+			if i+1 < len(args) {
+				if l, err := strconv.Atoi(args[i+1]); err == nil {
+					limit = l
+				}
+				i++
+			}
+		case "--all":
+			limit = 0
 		}
 	}
 
-	// If project filter, add it as tag filter
-	if projectFilter != "" {
-		if tagFilter != "" {
-			tagFilter = tagFilter + "|project*"
-		} else {
-			tagFilter = "project*"
+	if project != "" || here {
+		p, err := internal.ResolveProject(c, project)
+		if err != nil {
+			return err
 		}
+		filter.Projects = p.Names()
 	}
 
-	memos, err := c.List(typeFilter, tagFilter, 100)
+	total, err := c.Count(filter)
 	if err != nil {
 		return err
 	}
-
-	// Filter by project client-side and extract project for display
-	var filtered []internal.Memory
-	for _, m := range memos {
-		proj := getProjectFromTags(m.Tags)
-		if projectFilter != "" && proj != projectFilter {
-			continue
-		}
-		filtered = append(filtered, m)
+	memos, err := internal.Collect(c.Iterate(filter), limit)
+	if err != nil {
+		return err
 	}
 
-	fmt.Printf("%d memories\n\n", len(filtered))
-	for _, m := range filtered {
+	if len(memos) < total {
+		fmt.Printf("%d memories (showing %d; use --limit N or --all)\n\n", total, len(memos))
+	} else {
+		fmt.Printf("%d memories\n\n", total)
+	}
+	for _, m := range memos {
 		proj := getProjectFromTags(m.Tags)
 		fmt.Printf("[%s] (%s) [%s] %s\n", m.ID, m.Type, proj, m.Content)
 	}
 	return nil
 }
 
-func parseScore(s string) float64 {
-	var f float64
-	fmt.Sscanf(s, "%f", &f)
-	return f
-}
-
 func getProjectFromTags(tags []string) string {
+	scope := "?"
 	for _, tag := range tags {
 		if len(tag) > 8 && tag[:8] == "project:" {
-			return tag[8:]
+			return internal.ProjectName(tag[8:])
+		}
+		// Memories above project scope show where they live instead
+		if strings.HasPrefix(tag, "org:") || strings.HasPrefix(tag, "user:") {
+			scope = tag
+		} else if tag == "scope:global" {
+			scope = "global"
 		}
 	}
-	return "?"
+	return scope
 }
 
-func cmdGet(c *internal.Client, args []string) error {
+func cmdGet(c internal.Store, args []string) error {
 	if len(args) < 1 {
 		return fmt.Errorf("usage: memo get <id>")
 	}
@@ -456,27 +845,44 @@ func cmdGet(c *internal.Client, args []string) error {
 	fmt.Printf("Type:     %s\n", memo.Type)
 	fmt.Printf("Content:  %s\n", memo.Content)
 	fmt.Printf("Tags:     %s\n", strings.Join(memo.Tags, ", "))
+	if len(memo.Anchors) > 0 {
+		files := make([]string, len(memo.Anchors))
+		for i, a := range memo.Anchors {
+			files[i] = a.String()
+		}
+		fmt.Printf("Files:    %s\n", strings.Join(files, ", "))
+	}
+	if memo.Source != "" {
+		fmt.Printf("Source:   %s\n", memo.Source)
+	}
 	fmt.Printf("Created:  %s\n", memo.Created)
+	if memo.Verified != "" {
+		fmt.Printf("Verified: %s\n", memo.Verified)
+	}
 	fmt.Printf("Accessed: %s\n", memo.Accessed)
 	fmt.Printf("Access#:  %d\n", memo.AccessCount)
 	return nil
 }
 
-func cmdForget(c *internal.Client, args []string) error {
+func cmdForget(c internal.Store, args []string) error {
 	if len(args) < 1 {
 		return fmt.Errorf("usage: memo forget <id>")
 	}
 
-	if err := c.Forget(args[0]); err != nil {
+	m, err := c.Peek(args[0])
+	if err != nil {
+		return err
+	}
+	if err := internal.TrashMemory(c, args[0]); err != nil {
 		return err
 	}
 
-	c.MarkBriefStale(internal.GetProject())
-	fmt.Printf("Forgot: %s\n", args[0])
+	internal.MarkBriefsStale(c, m)
+	fmt.Printf("Forgot: %s (memo undo or memo restore %s brings it back)\n", args[0], args[0])
 	return nil
 }
 
-func cmdTag(c *internal.Client, args []string) error {
+func cmdTag(c internal.Store, args []string) error {
 	if len(args) < 2 {
 		return fmt.Errorf("usage: memo tag <id> <tag>")
 	}
@@ -492,39 +898,48 @@ func cmdTag(c *internal.Client, args []string) error {
 	return nil
 }
 
-func cmdUpdate(c *internal.Client, args []string) error {
+func cmdUpdate(c internal.Store, args []string) error {
 	if len(args) < 2 {
-		return fmt.Errorf("usage: memo update <id> <content>")
+		return fmt.Errorf("usage: memo update <id> <content> [--reason R]")
 	}
 
 	id := args[0]
-	content := strings.Join(args[1:], " ")
+	reason := "update"
+	var contentParts []string
+	for i := 1; i < len(args); i++ {
+		if args[i] == "--reason" && i+1 < len(args) {
+			reason = args[i+1]
+			i++
+		} else {
+			contentParts = append(contentParts, args[i])
+		}
+	}
+	content := strings.Join(contentParts, " ")
+	if content == "" {
+		return fmt.Errorf("usage: memo update <id> <content> [--reason R]")
+	}
 
-	if err := c.Update(id, content); err != nil {
+	m, err := internal.Revise(c, id, "update", reason, func(m *internal.Memory) error {
+		m.Content = content
+		return nil
+	})
+	if err != nil {
 		return err
 	}
 
-	// Re-embed asynchronously (use document embedding for storage)
-	go func() {
-		embedding, err := internal.GetDocumentEmbedding(content)
-		if err != nil {
-			return
-		}
-		c.EmbedMemory(id, embedding)
-	}()
-
-	c.MarkBriefStale(internal.GetProject())
+	reembed(c, m)
+	internal.MarkBriefsStale(c, m)
 	fmt.Printf("Updated [%s]: %s\n", id, content)
 	return nil
 }
 
-func cmdRelated(c *internal.Client, args []string) error {
+func cmdRelated(c internal.Store, args []string) error {
 	if len(args) < 1 {
 		return fmt.Errorf("usage: memo related <id> [limit]")
 	}
 
 	id := args[0]
-	limit := 5
+	limit := cfg.Limits.Related
 	if len(args) > 1 {
 		if l, err := strconv.Atoi(args[1]); err == nil {
 			limit = l
@@ -538,7 +953,7 @@ func cmdRelated(c *internal.Client, args []string) error {
 	}
 
 	// Find similar (limit+1 to exclude self)
-	results, err := c.Similar(embedding, limit+1, "")
+	results, err := c.Similar(embedding, limit+1, internal.Filter{})
 	if err != nil {
 		return err
 	}
@@ -553,7 +968,7 @@ func cmdRelated(c *internal.Client, args []string) error {
 	return nil
 }
 
-func cmdPrune(c *internal.Client, args []string) error {
+func cmdPrune(c internal.Store, args []string) error {
 	days := 30
 	dryRun := true
 
@@ -571,15 +986,13 @@ func cmdPrune(c *internal.Client, args []string) error {
 		}
 	}
 
-	memos, err := c.AllMemories()
-	if err != nil {
-		return err
-	}
-
 	cutoff := time.Now().AddDate(0, 0, -days)
 	var candidates []internal.Memory
 
-	for _, m := range memos {
+	it := c.Iterate(internal.Filter{})
+	defer it.Close()
+	for it.Next() {
+		m := it.Val()
 		if m.AccessCount > 0 {
 			continue
 		}
@@ -591,6 +1004,10 @@ func cmdPrune(c *internal.Client, args []string) error {
 			candidates = append(candidates, m)
 		}
 	}
+	if err := it.Err(); err != nil {
+		return err
+	}
+	it.Close()
 
 	if len(candidates) == 0 {
 		fmt.Printf("No stale memories found (access_count=0, older than %d days).\n", days)
@@ -611,15 +1028,17 @@ func cmdPrune(c *internal.Client, args []string) error {
 		fmt.Printf("\n%d candidates. Use --delete to remove them.\n", len(candidates))
 	} else {
 		for _, m := range candidates {
-			c.Forget(m.ID)
+			if err := internal.TrashMemory(c, m.ID); err != nil {
+				return err
+			}
 			fmt.Printf("Pruned [%s]: %s\n", m.ID, m.Content)
 		}
-		fmt.Printf("\nPruned %d memories.\n", len(candidates))
+		fmt.Printf("\nPruned %d memories (moved to the trash; memo undo restores them).\n", len(candidates))
 	}
 	return nil
 }
 
-func cmdMerge(c *internal.Client, args []string) error {
+func cmdMerge(c internal.Store, args []string) error {
 	if len(args) < 2 {
 		return fmt.Errorf("usage: memo merge <id1> <id2> [\"merged content\"]")
 	}
@@ -641,53 +1060,32 @@ func cmdMerge(c *internal.Client, args []string) error {
 		merged = m1.Content + " | " + m2.Content
 	}
 
-	// Combine tags (deduplicate)
-	tagSet := make(map[string]bool)
-	for _, t := range m1.Tags {
-		tagSet[t] = true
-	}
-	for _, t := range m2.Tags {
-		tagSet[t] = true
-	}
-
-	// Update first memo with merged content
-	if err := c.Update(args[0], merged); err != nil {
+	// Update first memo with merged content, plus m2's tags and anchors
+	if _, err := internal.MergeMemories(c, m1.ID, m2, merged); err != nil {
 		return err
 	}
 
-	// Add any new tags from m2
-	for _, t := range m2.Tags {
-		found := false
-		for _, t1 := range m1.Tags {
-			if t == t1 {
-				found = true
-				break
-			}
-		}
-		if !found {
-			c.AddTag(args[0], t)
-		}
+	// Move second memo to the trash, so memo undo can bring both back
+	if err := internal.TrashMemory(c, m2.ID); err != nil {
+		return err
 	}
 
-	// Delete second memo
-	c.Forget(args[1])
-
 	// Re-embed
 	embedding, err := internal.GetDocumentEmbedding(merged)
 	if err == nil {
 		c.EmbedMemory(args[0], embedding)
 	}
 
-	c.MarkBriefStale(internal.GetProject())
+	internal.MarkBriefsStale(c, m1, m2)
 	fmt.Printf("Merged [%s] + [%s] → [%s]: %s\n", args[0], args[1], args[0], merged)
 	return nil
 }
 
-func cmdReindex(c *internal.Client) error {
+func cmdReindex(c internal.Store) error {
 	fmt.Println("Reindexing all memories...")
 
 	// Delete existing vector set
-	c.DeleteVectorSet()
+	c.DeleteEmbeddings()
 
 	ids, err := c.GetAllMemoryIDs()
 	if err != nil {
@@ -709,12 +1107,7 @@ func cmdReindex(c *internal.Client) error {
 		fmt.Printf("  %s: %.50s...\n", id, memo.Content)
 
 		// Prepend tags for better semantic signal
-		embInput := memo.Content
-		if len(memo.Tags) > 0 {
-			embInput = strings.Join(memo.Tags, " ") + " " + memo.Content
-		}
-
-		embedding, err := internal.GetDocumentEmbedding(embInput)
+		embedding, err := internal.GetDocumentEmbedding(internal.EmbeddingInput(memo))
 		if err != nil {
 			fmt.Printf("    Error: %v\n", err)
 			continue
@@ -731,7 +1124,7 @@ func cmdReindex(c *internal.Client) error {
 	return nil
 }
 
-func cmdStats(c *internal.Client) error {
+func cmdStats(c internal.Store) error {
 	fmt.Println("Memory Statistics")
 	fmt.Println("=================")
 
@@ -749,7 +1142,7 @@ func cmdStats(c *internal.Client) error {
 	return nil
 }
 
-func cmdProjects(c *internal.Client) error {
+func cmdProjects(c internal.Store) error {
 	projects, err := c.Projects()
 	if err != nil {
 		return err
@@ -760,25 +1153,51 @@ func cmdProjects(c *internal.Client) error {
 		return nil
 	}
 
+	// Count memories filed under an alias with the project it resolves to
+	aliases, err := c.ProjectAliases()
+	if err != nil {
+		return err
+	}
+	for alias, key := range aliases {
+		if count, ok := projects[alias]; ok && alias != key {
+			projects[key] += count
+			delete(projects, alias)
+		}
+	}
+
+	keys := make([]string, 0, len(projects))
+	for key := range projects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
 	fmt.Println("Projects")
 	fmt.Println("========")
-	for name, count := range projects {
-		fmt.Printf("%-20s %d memories\n", name, count)
+	for _, key := range keys {
+		fmt.Printf("%-20s %-40s %d memories\n", internal.ProjectName(key), key, projects[key])
 	}
 	return nil
 }
 
-func cmdDedup(c *internal.Client, args []string) error {
+// dedupBatchSize is how many memories memo dedup sends to the LLM at once
+const dedupBatchSize = 200
+
+func cmdDedup(c internal.Store, args []string) error {
 	// Parse --project flag or default to current project
-	project := internal.GetProject()
+	var name string
 	for i := 0; i < len(args); i++ {
 		if args[i] == "--project" && i+1 < len(args) {
-			project = args[i+1]
+			name = args[i+1]
 			i++
 		}
 	}
+	p, err := internal.ResolveProject(c, name)
+	if err != nil {
+		return err
+	}
+	project := p.Name
 
-	memos, err := c.Context(project, 100)
+	memos, err := internal.Collect(c.Iterate(internal.Filter{Projects: p.Names()}), 0)
 	if err != nil {
 		return err
 	}
@@ -788,15 +1207,31 @@ func cmdDedup(c *internal.Client, args []string) error {
 		return nil
 	}
 
-	fmt.Printf("Analyzing %d memories for project: %s\n\n", len(memos), project)
-
-	// Build memory list
-	var memList string
-	for _, m := range memos {
-		memList += fmt.Sprintf("[%s] (%s) %s\n", m.ID, m.Type, m.Content)
+	// Large projects are reviewed in chunks that fit in one prompt. Duplicates
+	// split across chunks are missed, so memo remember's dedup still matters.
+	batches := (len(memos) + dedupBatchSize - 1) / dedupBatchSize
+	if batches > 1 {
+		fmt.Printf("Analyzing %d memories for project: %s (%d batches of up to %d)\n\n", len(memos), project, batches, dedupBatchSize)
+	} else {
+		fmt.Printf("Analyzing %d memories for project: %s\n\n", len(memos), project)
 	}
 
-	prompt := fmt.Sprintf(`You are reviewing all memories for a project to find redundancies, contradictions, and outdated information.
+	for b := 0; b < batches; b++ {
+		batch := memos[b*dedupBatchSize:]
+		if len(batch) > dedupBatchSize {
+			batch = batch[:dedupBatchSize]
+		}
+		if batches > 1 {
+			fmt.Printf("=== Batch %d/%d ===\n", b+1, batches)
+		}
+
+		// Build memory list
+		var memList string
+		for _, m := range batch {
+			memList += fmt.Sprintf("[%s] (%s) %s\n", m.ID, m.Type, m.Content)
+		}
+
+		prompt := fmt.Sprintf(`You are reviewing all memories for a project to find redundancies, contradictions, and outdated information.
 
 Project: %s
 
@@ -817,12 +1252,16 @@ ACTION: ids
   content: new content (for MERGE/UPDATE)
   command: the memo CLI command to execute`, project, memList)
 
-	result, err := internal.CallLLM(prompt)
-	if err != nil {
-		return fmt.Errorf("LLM error: %w", err)
-	}
+		result, err := internal.CallLLM(prompt)
+		if err != nil {
+			return fmt.Errorf("LLM error: %w", err)
+		}
 
-	fmt.Println(result)
+		fmt.Println(result)
+		if b+1 < batches {
+			fmt.Println()
+		}
+	}
 	return nil
 }
 
@@ -831,23 +1270,86 @@ func printHelp() {
 
 Commands:
   init                              Initialize the search index
-  remember <type> <content> [--tags t1,t2] [--force]  Store a memory
+  remember <type> <content> [--tags t1,t2] [--scope S] [--file PATH[:SYMBOL]] [--branch] [--force]
+                                    Store a memory
+                                    (scope: dir, branch, project (default), org, user, global;
+                                    --file anchors it to a file, symbol or line range (repeatable);
+                                    --branch records the git branch, --no-branch overrides git.auto_branch)
   recall <query> [limit]            Search memories (full-text)
-  similar <query> [--here] [--limit N]  Semantic search (--here = this project)
-  context [limit]                   Show memories for current project
-  list [--type TYPE] [--project P] [--here]  List memories with filters
+  similar <query> [--here] [--type T] [--tag T] [--limit N] [--expand] [--rerank] [--explain]
+                                    Semantic search (--here = this scope chain)
+  search <query> [--here|--project P] [--type T] [--tag T] [--limit N]
+         [--text-weight W] [--vector-weight W] [--expand] [--rerank] [--explain]
+                                    Hybrid search: full-text and semantic rankings fused
+  context [query] [limit] [--file PATH] [--expand] [--rerank] [--explain]
+                                    Show memories visible here, nearest scope first
+                                    (or the most relevant to a query)
+                                    --rerank reorders the top results with the LLM or a
+                                    cross-encoder (rerank.provider); --explain adds reasons;
+                                    --expand also searches LLM rewrites of the query (cached)
+  about <path|glob>                 Show memories anchored to a file or directory
+  list [--type TYPE] [--tag T] [--project P] [--file GLOB] [--here] [--limit N|--all]
+                                    List memories with filters
   get <id>                          Get a specific memory
-  update <id> <content>             Update a memory's content
+  update <id> <content> [--reason R]  Update a memory's content
+  ingest <file|dir> [--yes] [--scope S] [--tags t1,t2] [--no-llm]
+                                    Split notes, CLAUDE.md files or memory directories into
+                                    memories, classified and dedup-checked, approving each
+  pin <id...> / unpin <id...>       Mark memories for the agent instruction files
+  sync-instructions [--target claude|agents|cursor] [--file PATH] [--check]
+                                    Write the brief, pinned memories and preferences into a
+                                    managed block in CLAUDE.md, AGENTS.md or .cursor/rules/memo.mdc
+                                    (--check: exit 1 if out of date)
+  history <id>                      Show a memory's revisions
+  diff <id> [rev]                   Compare a revision (default: the previous one) with now
+  revert <id> <rev>                 Restore a revision's content and tags
   tag <id> <tag>                    Add a tag to a memory
   related <id> [limit]              Find memories similar to one
-  forget <id>                       Delete a memory
+  forget <id>                       Move a memory to the trash
+  trash [list | empty]              Show deleted memories, or purge them now
+                                    (kept for trash.retention_days)
+  restore <id>                      Bring a memory back from the trash
+  undo                              Reverse the last command that changed memories
+  log [--project P|--here] [--id ID] [--actor A] [--since T] [--until T] [--limit N]
+                                    Show who changed what (T: 2026-01-31, RFC 3339, or 2h / 7d ago)
   brief [--refresh]                  Show/regenerate project understanding
   dedup [--project P]                Find redundant/outdated memories (LLM-powered)
   merge <id1> <id2> ["content"]      Merge two memories (optional content override)
   prune [--days N] [--delete]       Find stale memories (default: dry run)
+  stale [--lines N] [--list]        Find memories whose files changed since they were stored,
+                                    then verify, update or forget each
+  verify <id...>                    Mark memories as still accurate for the current code
   reindex                           Generate embeddings for all memories
+  export [--project P|--here] [-o FILE]  Write memories, vectors, briefs and aliases as JSONL
+  import <file|-> [--mode skip|overwrite|merge]  Load an export (existing IDs: skip by default)
+  snapshot create <name>            Copy every memory, vector, brief and alias at one moment
+  snapshot list                     Show snapshots
+  snapshot diff <name> [--project P|--here]     What changed since a snapshot
+  snapshot restore <name> [--project P|--here] [--yes]
+                                    Roll the store (or one project) back to a snapshot
+  snapshot delete <name>            Remove a snapshot
+  sync serve [--addr HOST:PORT]     Let other machines sync with this store (default 127.0.0.1:7979)
+  sync <remote>                     Pull the remote's changes, then push this store's
+  sync push|pull <remote>           Only send, or only receive (remote: URL or [sync.remotes] name)
+  sync                              Show when each remote was last synced
+  migrate [--dry-run] [--no-backup] Upgrade stored memories to the current schema
+  doctor [--fix]                    Check vectors, index, briefs and documents for consistency
   stats                             Show memory statistics
   projects                          List all projects with memory counts
+  project [alias NAME | unalias NAME]  Show this project's identity, or manage
+                                    names that resolve to it
+  project rename <old> <new>        Refile a project's memories and brief under a new key
+  project merge <from> <into>       Fold one project into another
+  project delete <name> [--yes] [--no-export]  Delete a project (exported first)
+  move <id...> --to PROJECT         Refile memories under another project
+  branch                            Count this project's memories per git branch
+  branch promote [BRANCH]           Make a merged branch's memories project-wide
+  branch cleanup                    List memories whose branch no longer exists
+  config show                       Show resolved settings (secrets masked)
+
+Global flags:
+  --profile NAME                    Use a profile from ~/.config/memo/config.toml
+                                    (default: $MEMO_PROFILE, then default_profile)
 
 Types: fact, context, learned, preference
 
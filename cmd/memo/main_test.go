@@ -0,0 +1,278 @@
+package main
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"memo/internal"
+	"memo/internal/storetest"
+)
+
+// setup points memo at an empty home with no config file and a fake
+// embedding service, and runs the test outside any git repository
+func setup(t *testing.T) *storetest.Store {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	t.Setenv("MEMO_CONFIG", filepath.Join(home, "config.toml"))
+	t.Setenv("MEMO_ACTOR", "tester")
+	t.Setenv("MEMO_PROFILE", "")
+	t.Setenv("MEMO_SYNC_TOKEN", "")
+
+	srv := httptest.NewServer(http.HandlerFunc(fakeEmbeddings))
+	t.Cleanup(srv.Close)
+	t.Setenv("EMBEDDINGS_URL", srv.URL)
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(home); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	if cfg, err = internal.LoadConfig(""); err != nil {
+		t.Fatal(err)
+	}
+	return storetest.New()
+}
+
+// fakeEmbeddings serves TEI's /embed with a bag-of-words vector, so texts
+// sharing words come out similar and identical texts identical
+func fakeEmbeddings(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Inputs string `json:"inputs"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	_, text, _ := strings.Cut(req.Inputs, ": ")
+	vec := make([]float64, 64)
+	for _, word := range strings.Fields(strings.ToLower(text)) {
+		h := fnv.New32a()
+		h.Write([]byte(strings.Trim(word, ".,")))
+		vec[h.Sum32()%64]++
+	}
+	var norm float64
+	for _, x := range vec {
+		norm += x * x
+	}
+	for i := range vec {
+		vec[i] /= math.Max(math.Sqrt(norm), 1)
+	}
+	json.NewEncoder(w).Encode([][]float64{vec})
+}
+
+// memo runs one command as main would and returns what it printed
+func memo(t *testing.T, s internal.Store, args ...string) (string, error) {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	done := make(chan []byte)
+	go func() {
+		out, _ := io.ReadAll(r)
+		done <- out
+	}()
+
+	internal.StartAudit("memo " + strings.Join(args, " "))
+	err = runCommand(s, args[0], args[1:])
+	w.Close()
+	os.Stdout = stdout
+	return string(<-done), err
+}
+
+var rememberedID = regexp.MustCompile(`Remembered \[(\w+)\]`)
+
+// step is one command in a scenario. $1, $2... in args stand for the IDs
+// of the memories remembered so far, in order.
+type step struct {
+	args []string
+	want []string
+	// err is a substring of the error the command must fail with
+	err string
+}
+
+func TestCommands(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{"remember and get", []step{
+			{args: []string{"remember", "fact", "The API listens on port 8080", "--tags", "api"}, want: []string{"Remembered ["}},
+			{args: []string{"get", "$1"}, want: []string{"Content:  The API listens on port 8080", ", api"}},
+		}},
+		{"remember refuses a duplicate", []step{
+			{args: []string{"remember", "fact", "Deploys run from the release branch"}},
+			{args: []string{"remember", "fact", "Deploys run from the release branch"}, want: []string{"Duplicate: [$1]", "Skipping"}},
+			{args: []string{"stats"}, want: []string{"Total: 1"}},
+		}},
+		{"remember needs content", []step{
+			{args: []string{"remember", "fact"}, err: "usage: memo remember"},
+		}},
+		{"recall matches words", []step{
+			{args: []string{"remember", "fact", "Use pnpm instead of npm"}},
+			{args: []string{"remember", "fact", "Tests need a running database"}},
+			{args: []string{"recall", "pnpm"}, want: []string{"1 results found", "[$1] (fact) Use pnpm instead of npm"}},
+		}},
+		{"search fuses text and vector", []step{
+			{args: []string{"remember", "learned", "ERR_QUOTA_7 means the tenant hit its budget"}},
+			{args: []string{"search", "ERR_QUOTA_7"}, want: []string{"[$1] (text+vector)"}},
+		}},
+		{"update records a revision", []step{
+			{args: []string{"remember", "fact", "Builds take five minutes"}},
+			{args: []string{"update", "$1", "Builds take two minutes"}, want: []string{"Updated [$1]"}},
+			{args: []string{"history", "$1"}, want: []string{"2 revisions", "Builds take five minutes", "Builds take two minutes"}},
+		}},
+		{"update needs content", []step{
+			{args: []string{"remember", "fact", "Releases ship on Tuesdays"}},
+			{args: []string{"update", "$1", "--reason", "typo"}, err: "usage: memo update"},
+			{args: []string{"get", "$1"}, want: []string{"Releases ship on Tuesdays"}},
+		}},
+		{"verify keeps the history", []step{
+			{args: []string{"remember", "fact", "Logs go to stderr"}},
+			{args: []string{"verify", "$1"}, want: []string{"Verified [$1]"}},
+			{args: []string{"verify", "$1"}},
+			{args: []string{"history", "$1"}, want: []string{"has not changed since it was created"}},
+			{args: []string{"get", "$1"}, want: []string{"Verified: "}},
+		}},
+		{"tag and list by tag", []step{
+			{args: []string{"remember", "preference", "Prefer table-driven tests"}},
+			{args: []string{"remember", "preference", "Keep functions short"}},
+			{args: []string{"tag", "$2", "style"}},
+			{args: []string{"list", "--tag", "style"}, want: []string{"Keep functions short"}},
+		}},
+		{"forget, then undo", []step{
+			{args: []string{"remember", "fact", "The cache lives in Redis"}},
+			{args: []string{"forget", "$1"}, want: []string{"Forgot: $1"}},
+			{args: []string{"get", "$1"}, err: "memory not found"},
+			{args: []string{"undo"}, want: []string{"Undid: memo forget $1"}},
+			{args: []string{"get", "$1"}, want: []string{"The cache lives in Redis"}},
+		}},
+		{"forget, then restore", []step{
+			{args: []string{"remember", "fact", "Staging resets nightly"}},
+			{args: []string{"forget", "$1"}},
+			{args: []string{"trash"}, want: []string{"$1"}},
+			{args: []string{"restore", "$1"}},
+			{args: []string{"get", "$1"}, want: []string{"Staging resets nightly"}},
+		}},
+		{"undo a remember", []step{
+			{args: []string{"remember", "fact", "Migrations run on boot"}},
+			{args: []string{"undo"}},
+			{args: []string{"get", "$1"}, err: "memory not found"},
+		}},
+		{"log shows each change", []step{
+			{args: []string{"remember", "fact", "Feature flags live in LaunchDarkly"}},
+			{args: []string{"update", "$1", "Feature flags live in Unleash"}},
+			{args: []string{"log", "--id", "$1"}, want: []string{"remember", "update", "tester"}},
+		}},
+		{"unknown command", []step{
+			{args: []string{"frobnicate"}, want: []string{"Unknown command: frobnicate"}, err: "unknown command"},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := setup(t)
+			var ids []string
+			expand := func(text string) string {
+				for i := len(ids); i > 0; i-- {
+					text = strings.ReplaceAll(text, "$"+string(rune('0'+i)), ids[i-1])
+				}
+				return text
+			}
+			for _, st := range tt.steps {
+				args := make([]string, len(st.args))
+				for i, a := range st.args {
+					args[i] = expand(a)
+				}
+				out, err := memo(t, s, args...)
+				switch {
+				case st.err == "" && err != nil:
+					t.Fatalf("memo %s: %v\n%s", strings.Join(args, " "), err, out)
+				case st.err != "" && (err == nil || !strings.Contains(err.Error(), st.err)):
+					t.Fatalf("memo %s: got error %v, want %q", strings.Join(args, " "), err, st.err)
+				}
+				for _, want := range st.want {
+					if want = expand(want); !strings.Contains(out, want) {
+						t.Errorf("memo %s: output lacks %q:\n%s", strings.Join(args, " "), want, out)
+					}
+				}
+				if m := rememberedID.FindStringSubmatch(out); m != nil {
+					ids = append(ids, m[1])
+				}
+			}
+		})
+	}
+}
+
+func TestDoctorQuarantinesUnreadableMemories(t *testing.T) {
+	s := setup(t)
+	if _, err := memo(t, s, "remember", "fact", "The queue is drained before deploys"); err != nil {
+		t.Fatal(err)
+	}
+	out, err := memo(t, s, "remember", "fact", "Nobody can read this one")
+	if err != nil {
+		t.Fatal(err)
+	}
+	broken := rememberedID.FindStringSubmatch(out)[1]
+	s.Corrupt(broken)
+
+	out, err = memo(t, s, "doctor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "--fix would remove:") || !strings.Contains(out, "["+broken+"] move to quarantine") {
+		t.Errorf("doctor did not report the unreadable memory:\n%s", out)
+	}
+	if _, ok := s.Quarantined()[broken]; ok {
+		t.Fatal("doctor without --fix changed the store")
+	}
+
+	if out, err = memo(t, s, "doctor", "--fix"); err != nil {
+		t.Fatalf("doctor --fix: %v\n%s", err, out)
+	}
+	if _, ok := s.Quarantined()[broken]; !ok {
+		t.Errorf("doctor --fix did not quarantine the unreadable memory:\n%s", out)
+	}
+	if n, _ := s.Count(internal.Filter{}); n != 1 {
+		t.Errorf("%d memories left, want the readable one", n)
+	}
+}
+
+func TestUpdateReembedsBeforeReturning(t *testing.T) {
+	s := setup(t)
+	out, err := memo(t, s, "remember", "fact", "Builds take five minutes", "--tags", "ci")
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := rememberedID.FindStringSubmatch(out)[1]
+	if _, err := memo(t, s, "update", id, "Builds take two minutes"); err != nil {
+		t.Fatal(err)
+	}
+
+	m, _ := s.Peek(id)
+	want, err := internal.GetDocumentEmbedding(internal.EmbeddingInput(m))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := s.GetEmbeddingByID(id); !reflect.DeepEqual(got, want) {
+		t.Error("the stored vector is not the updated memory's")
+	}
+}
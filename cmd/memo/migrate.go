@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"memo/internal"
+)
+
+func cmdMigrate(c internal.Store, args []string) error {
+	dryRun := false
+	backup := true
+	for _, a := range args {
+		switch a {
+		case "--dry-run":
+			dryRun = true
+		case "--no-backup":
+			backup = false
+		}
+	}
+
+	backupPath := ""
+	if backup {
+		backupPath = internal.DefaultBackupPath("migrate")
+	}
+
+	report, err := internal.Migrate(c, dryRun, backupPath)
+	if err != nil {
+		return err
+	}
+
+	if report.From == report.To {
+		fmt.Printf("Schema is up to date (version %d).\n", report.To)
+		return nil
+	}
+
+	fmt.Printf("Schema version: %d → %d\n", report.From, report.To)
+	for _, m := range report.Pending {
+		fmt.Printf("  v%d: %s\n", m.Version, m.Description)
+	}
+	fmt.Println()
+
+	ids := make([]string, 0, len(report.Changes))
+	for id := range report.Changes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	if dryRun {
+		for _, id := range ids {
+			fmt.Printf("[%s] %s\n", id, strings.Join(report.Changes[id], " "))
+		}
+		fmt.Printf("\n%d of %d memories would change. Run without --dry-run to apply.\n", len(ids), report.Total)
+		return nil
+	}
+
+	if report.Backup != "" {
+		fmt.Printf("Backup: %s\n", report.Backup)
+	}
+	fmt.Printf("Updated %d of %d memories and rebuilt the index.\n", len(ids), report.Total)
+	fmt.Printf("Migrated to schema version %d.\n", report.To)
+	return nil
+}
+
+// warnIfOutdated nudges towards memo migrate when stored documents predate this binary
+func warnIfOutdated(c internal.Store) {
+	version, err := c.SchemaVersion()
+	if err != nil || version >= internal.SchemaVersion {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Warning: memory schema is version %d, this memo expects %d - run 'memo migrate'\n", version, internal.SchemaVersion)
+}
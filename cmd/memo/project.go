@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"memo/internal"
+)
+
+func cmdProject(c internal.Store, args []string) error {
+	if len(args) == 0 {
+		return cmdProjectShow(c)
+	}
+
+	switch args[0] {
+	case "alias":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: memo project alias <name> [--to KEY]")
+		}
+		key := internal.GetProject()
+		if len(args) > 3 && args[2] == "--to" {
+			key = args[3]
+		}
+		return cmdProjectAlias(c, args[1], key)
+	case "unalias":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: memo project unalias <name>")
+		}
+		if err := c.DeleteProjectAlias(args[1]); err != nil {
+			return err
+		}
+		fmt.Printf("Removed alias %s\n", args[1])
+		return nil
+	case "rename":
+		if len(args) < 3 {
+			return fmt.Errorf("usage: memo project rename <old> <new>")
+		}
+		return cmdProjectRename(c, args[1], args[2])
+	case "merge":
+		if len(args) < 3 {
+			return fmt.Errorf("usage: memo project merge <from> <into>")
+		}
+		return cmdProjectMerge(c, args[1], args[2])
+	case "delete":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: memo project delete <name> [--yes] [--no-export]")
+		}
+		return cmdProjectDelete(c, args[1], args[2:])
+	}
+	return fmt.Errorf("usage: memo project [alias|unalias|rename|merge|delete] ...")
+}
+
+func cmdProjectShow(c internal.Store) error {
+	p, err := internal.ResolveProject(c, "")
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Name:     %s\n", p.Name)
+	fmt.Printf("Key:      %s\n", p.Key)
+	fmt.Printf("Source:   %s\n", p.Source)
+	if len(p.Aliases) > 0 {
+		fmt.Printf("Aliases:  %s\n", strings.Join(p.Aliases, ", "))
+	}
+	count, err := c.Count(internal.Filter{Projects: p.Names()})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Memories: %d\n", count)
+	hintLegacyProject(c, p)
+	return nil
+}
+
+func cmdProjectAlias(c internal.Store, alias, key string) error {
+	if alias == key {
+		return fmt.Errorf("%s is the project key itself", alias)
+	}
+
+	aliases, err := c.ProjectAliases()
+	if err != nil {
+		return err
+	}
+	if target, ok := aliases[alias]; ok && target != key {
+		return fmt.Errorf("%s already resolves to %s (memo project unalias %s first)", alias, target, alias)
+	}
+	if err := c.SetProjectAlias(alias, key); err != nil {
+		return err
+	}
+	c.MarkBriefStale(key)
+
+	count, _ := c.Count(internal.Filter{Projects: []string{alias}})
+	fmt.Printf("%s now resolves to %s (%d memories filed under it)\n", alias, key, count)
+	return nil
+}
+
+func cmdProjectRename(c internal.Store, oldName, newName string) error {
+	from, err := internal.ResolveProject(c, oldName)
+	if err != nil {
+		return err
+	}
+	if from.Key == newName {
+		return fmt.Errorf("%s is already called %s", oldName, newName)
+	}
+	if count, err := c.Count(internal.Filter{Projects: []string{newName}}); err != nil {
+		return err
+	} else if count > 0 {
+		return fmt.Errorf("%s already has %d memories (use memo project merge %s %s)", newName, count, oldName, newName)
+	}
+
+	moved, err := internal.RenameProject(c, from, newName)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Renamed %s to %s (%d memories). %s now resolves to %s.\n", from.Key, newName, moved, from.Key, newName)
+	return nil
+}
+
+func cmdProjectMerge(c internal.Store, fromName, intoName string) error {
+	from, err := internal.ResolveProject(c, fromName)
+	if err != nil {
+		return err
+	}
+	into, err := internal.ResolveProject(c, intoName)
+	if err != nil {
+		return err
+	}
+	if from.Key == into.Key {
+		return fmt.Errorf("%s and %s are the same project (%s)", fromName, intoName, from.Key)
+	}
+
+	moved, err := internal.RenameProject(c, from, into.Key)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Merged %d memories from %s into %s. Run memo dedup --project %s to find overlaps.\n", moved, from.Key, into.Key, into.Key)
+	return nil
+}
+
+func cmdProjectDelete(c internal.Store, name string, args []string) error {
+	yes, export := false, true
+	for _, a := range args {
+		switch a {
+		case "--yes", "-y":
+			yes = true
+		case "--no-export":
+			export = false
+		}
+	}
+
+	p, err := internal.ResolveProject(c, name)
+	if err != nil {
+		return err
+	}
+	count, err := c.Count(internal.Filter{Projects: p.Names()})
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return fmt.Errorf("project %s has no memories", p.Key)
+	}
+
+	if !yes && !confirm(fmt.Sprintf("Delete %d memories of %s?", count, p.Key)) {
+		fmt.Println("Aborted.")
+		return nil
+	}
+
+	backupPath := ""
+	if export {
+		backupPath = internal.DefaultBackupPath("project-" + strings.NewReplacer("/", "_", ":", "_").Replace(p.Key))
+	}
+	deleted, err := internal.DeleteProject(c, p, backupPath)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Deleted %d memories of %s (memo undo brings them back).\n", deleted, p.Key)
+	if backupPath != "" {
+		fmt.Printf("Exported to %s; to restore it later:\n  memo import %s\n", backupPath, backupPath)
+	}
+	return nil
+}
+
+func cmdMove(c internal.Store, args []string) error {
+	var ids []string
+	var to string
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--to" && i+1 < len(args) {
+			to = args[i+1]
+			i++
+		} else {
+			ids = append(ids, args[i])
+		}
+	}
+	if len(ids) == 0 || to == "" {
+		return fmt.Errorf("usage: memo move <id...> --to PROJECT")
+	}
+
+	p, err := internal.ResolveProject(c, to)
+	if err != nil {
+		return err
+	}
+	if err := internal.MoveMemories(c, ids, p.Key); err != nil {
+		return err
+	}
+	fmt.Printf("Moved %d memories to %s\n", len(ids), p.Key)
+	return nil
+}
+
+// confirm asks a yes/no question on stdin, defaulting to no
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// hintLegacyProject points out memories filed under the directory name,
+// as every memory was before projects had stable keys
+func hintLegacyProject(c internal.Store, p *internal.Project) {
+	if p.Name == p.Key {
+		return
+	}
+	aliases, err := c.ProjectAliases()
+	if err != nil {
+		return
+	}
+	if _, claimed := aliases[p.Name]; claimed {
+		return
+	}
+	count, err := c.Count(internal.Filter{Projects: []string{p.Name}})
+	if err != nil || count == 0 {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Note: %d memories are filed under the old project name %q.\n", count, p.Name)
+	fmt.Fprintf(os.Stderr, "If they belong to this repo, run: memo project alias %s\n\n", p.Name)
+}
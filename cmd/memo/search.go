@@ -0,0 +1,253 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"memo/internal"
+)
+
+func cmdSearch(c internal.Store, args []string) error {
+	var query, project string
+	var filter internal.Filter
+	here, rerank, explain, expand := false, false, false, false
+	opts := internal.DefaultSearchOptions()
+
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--here":
+			here = true
+		case "--rerank":
+			rerank = true
+		case "--expand":
+			expand = true
+		case "--explain":
+			rerank, explain = true, true
+		case "--project":
+			if i+1 < len(args) {
+				project = args[i+1]
+				i++
+			}
+		case "--type":
+			if i+1 < len(args) {
+				filter.Type = args[i+1]
+				i++
+			}
+		case "--tag":
+			if i+1 < len(args) {
+				filter.Tag = args[i+1]
+				i++
+			}
+		case "--limit":
+			if i+1 < len(args) {
+				if l, err := strconv.Atoi(args[i+1]); err == nil {
+					opts.Limit = l
+				}
+				i++
+			}
+		case "--text-weight", "--vector-weight":
+			if i+1 < len(args) {
+				w, err := strconv.ParseFloat(args[i+1], 64)
+				if err != nil || w < 0 {
+					return fmt.Errorf("%s needs a non-negative number", args[i])
+				}
+				if args[i] == "--text-weight" {
+					opts.TextWeight = w
+				} else {
+					opts.VectorWeight = w
+				}
+				i++
+			}
+		default:
+			if query == "" {
+				query = args[i]
+			}
+		}
+	}
+	if query == "" {
+		return fmt.Errorf("usage: memo search <query> [--here|--project P] [--type T] [--tag T] [--limit N] [--text-weight W] [--vector-weight W] [--expand] [--rerank] [--explain]")
+	}
+
+	// The filter holds one tag pattern; under --here that is the scope
+	// chain, so --tag is checked on the results instead
+	var tagged internal.Filter
+	if here {
+		// The whole scope chain visible here, as memo context shows it
+		scopes, err := internal.CurrentScopes(c)
+		if err != nil {
+			return err
+		}
+		scoped := scopes.Filter()
+		scoped.Type = filter.Type
+		tagged.Tag, filter = filter.Tag, scoped
+		fmt.Printf("Searching for: %s (project: %s)\n", query, scopes.Project.Name)
+	} else if project != "" {
+		p, err := internal.ResolveProject(c, project)
+		if err != nil {
+			return err
+		}
+		filter.Projects = p.Names()
+		fmt.Printf("Searching for: %s (project: %s)\n", query, p.Name)
+	} else {
+		fmt.Printf("Searching for: %s\n", query)
+	}
+
+	opts.Filter = filter
+	opts.Embedding = queryEmbedding(query)
+	if expand && opts.Embedding != nil {
+		opts.Expansions = expandedEmbeddings(query, explain)
+	}
+	limit := opts.Limit
+	if tagged.Tag != "" {
+		opts.Limit = 0 // everything either ranking found, narrowed below
+	} else if rerank {
+		opts.Limit = max(limit, internal.RerankCandidates())
+	}
+	results, err := internal.HybridSearch(c, query, opts)
+	if err != nil {
+		return err
+	}
+	if tagged.Tag != "" {
+		kept := results[:0]
+		for _, r := range results {
+			if tagged.Matches(r.Memory) {
+				kept = append(kept, r)
+			}
+		}
+		results = kept
+	}
+
+	var placed map[string]internal.Reranked
+	if rerank {
+		results = results[:min(len(results), max(limit, internal.RerankCandidates()))]
+		memos := make([]internal.Memory, len(results))
+		byID := make(map[string]internal.SearchResult, len(results))
+		for i, r := range results {
+			memos[i] = r.Memory
+			byID[r.Memory.ID] = r
+		}
+		memos, placed = rerankMemories(query, memos, explain)
+		for i, m := range memos {
+			results[i] = byID[m.ID]
+		}
+	}
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+
+	fmt.Println()
+	if len(results) == 0 {
+		fmt.Println("No matching memories found.")
+		return nil
+	}
+	for _, r := range results {
+		fmt.Printf("[%s] (%s) (%s) %s\n", r.Memory.ID, matchedBy(r), typeAndScope(r.Memory), r.Memory.Content)
+		if explain {
+			fmt.Printf("    %s | %s\n", explainRanks(r), explainRerank(placed, r.Memory.ID))
+		}
+	}
+	return nil
+}
+
+// explainRanks describes where each ranking placed a result
+func explainRanks(r internal.SearchResult) string {
+	text, vector := "-", "-"
+	if r.TextRank > 0 {
+		text = fmt.Sprintf("#%d", r.TextRank)
+	}
+	if r.VectorRank > 0 {
+		vector = fmt.Sprintf("#%d", r.VectorRank)
+	}
+	return fmt.Sprintf("text %s, vector %s, similarity %.2f, fused %.4f", text, vector, r.Similarity, r.Score)
+}
+
+// rerankMemories reorders memos against query with the configured
+// reranker, returning how it placed each (by ID). If the reranker fails
+// the order is kept and the map is nil.
+func rerankMemories(query string, memos []internal.Memory, explain bool) ([]internal.Memory, map[string]internal.Reranked) {
+	ranked, err := internal.Rerank(query, memos, explain)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: reranking failed (%v), keeping the original order\n", err)
+		return memos, nil
+	}
+	placed := make(map[string]internal.Reranked, len(ranked))
+	reordered := make([]internal.Memory, len(ranked))
+	for i, r := range ranked {
+		reordered[i] = r.Memory
+		placed[r.Memory.ID] = r
+	}
+	return reordered, placed
+}
+
+// explainRerank describes the reranker's verdict on a memory
+func explainRerank(placed map[string]internal.Reranked, id string) string {
+	r, ok := placed[id]
+	if !ok {
+		return "not reranked"
+	}
+	s := fmt.Sprintf("rerank %.2f (was #%d)", r.Score, r.Rank)
+	if r.Reason != "" {
+		s += ": " + r.Reason
+	}
+	return s
+}
+
+// matchedBy says which rankings found a result
+func matchedBy(r internal.SearchResult) string {
+	switch {
+	case r.TextRank > 0 && r.VectorRank > 0:
+		return "text+vector"
+	case r.TextRank > 0:
+		return "text"
+	}
+	return "vector"
+}
+
+// queryEmbedding embeds a search query, or returns nil (full-text only)
+// when the embedding service is unavailable
+func queryEmbedding(query string) []float64 {
+	embedding, err := internal.GetEmbedding(query)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: embedding service unavailable, using full-text search only\n")
+		return nil
+	}
+	return embedding
+}
+
+// expandedEmbeddings embeds an LLM's rewrites of query for --expand
+// (printing them if show). Without the LLM the query is searched as given.
+func expandedEmbeddings(query string, show bool) [][]float64 {
+	e, err := internal.ExpandQuery(query)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: query expansion failed (%v), searching the query as given\n", err)
+		return nil
+	}
+	if show {
+		for _, text := range e.Texts() {
+			fmt.Printf("  also: %s\n", text)
+		}
+	}
+	return internal.EmbedExpansion(e)
+}
+
+// searchMemories returns the memories matching filter that a hybrid search
+// for query ranks highest (with --expand, also for its rewrites)
+func searchMemories(c internal.Store, query string, filter internal.Filter, limit int, expand bool) ([]internal.Memory, error) {
+	opts := internal.DefaultSearchOptions()
+	opts.Limit = limit
+	opts.Filter = filter
+	opts.Embedding = queryEmbedding(query)
+	if expand && opts.Embedding != nil {
+		opts.Expansions = expandedEmbeddings(query, false)
+	}
+	results, err := internal.HybridSearch(c, query, opts)
+	if err != nil {
+		return nil, err
+	}
+	memos := make([]internal.Memory, len(results))
+	for i, r := range results {
+		memos[i] = r.Memory
+	}
+	return memos, nil
+}
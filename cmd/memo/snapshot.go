@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"memo/internal"
+)
+
+func cmdSnapshot(c internal.Store, args []string) error {
+	if len(args) == 0 || args[0] == "list" {
+		return cmdSnapshotList()
+	}
+	if len(args) < 2 {
+		return fmt.Errorf("usage: memo snapshot [list|create|diff|restore|delete] <name>")
+	}
+
+	name := args[1]
+	switch args[0] {
+	case "create":
+		info, err := internal.CreateSnapshot(c, name)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Snapshot %s: %d memories (%s)\n", info.Name, info.Memories, info.Path)
+		return nil
+	case "delete":
+		if err := internal.DeleteSnapshot(name); err != nil {
+			return err
+		}
+		fmt.Printf("Deleted snapshot %s\n", name)
+		return nil
+	case "diff":
+		p, _, err := snapshotScope(c, args[2:])
+		if err != nil {
+			return err
+		}
+		return cmdSnapshotDiff(c, name, p)
+	case "restore":
+		p, yes, err := snapshotScope(c, args[2:])
+		if err != nil {
+			return err
+		}
+		return cmdSnapshotRestore(c, name, p, yes)
+	}
+	return fmt.Errorf("usage: memo snapshot [list|create|diff|restore|delete] <name>")
+}
+
+// snapshotScope parses --project P / --here (one project instead of the
+// whole store) and --yes
+func snapshotScope(c internal.Store, args []string) (*internal.Project, bool, error) {
+	var project string
+	here, yes := false, false
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--project":
+			if i+1 < len(args) {
+				project = args[i+1]
+				i++
+			}
+		case "--here":
+			here = true
+		case "--yes", "-y":
+			yes = true
+		}
+	}
+	if project == "" && !here {
+		return nil, yes, nil
+	}
+	p, err := internal.ResolveProject(c, project)
+	return p, yes, err
+}
+
+func cmdSnapshotList() error {
+	snapshots, err := internal.ListSnapshots()
+	if err != nil {
+		return err
+	}
+	if len(snapshots) == 0 {
+		fmt.Println("No snapshots. Create one with: memo snapshot create <name>")
+		return nil
+	}
+	for _, s := range snapshots {
+		fmt.Printf("%-32s %s  %5d memories  %s\n", s.Name, s.Created, s.Memories, formatSize(s.Size))
+	}
+	return nil
+}
+
+func formatSize(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", n)
+}
+
+func cmdSnapshotDiff(c internal.Store, name string, p *internal.Project) error {
+	diff, err := internal.DiffSnapshot(c, name, p)
+	if err != nil {
+		return err
+	}
+	if diff.Empty() {
+		fmt.Printf("No changes since snapshot %s.\n", name)
+		return nil
+	}
+	printSnapshotDiff(diff)
+	return nil
+}
+
+// printSnapshotDiff lists changes since a snapshot: + stored since,
+// - deleted since, ~ changed (with a word diff from the snapshot to now)
+func printSnapshotDiff(diff *internal.SnapshotDiff) {
+	for _, m := range diff.Removed {
+		fmt.Printf("- [%s] (%s) %s\n", m.ID, m.Type, m.Content)
+	}
+	for _, m := range diff.Added {
+		fmt.Printf("+ [%s] (%s) %s\n", m.ID, m.Type, m.Content)
+	}
+	for _, ch := range diff.Changed {
+		fmt.Printf("~ [%s] (%s) %s\n", ch.After.ID, ch.After.Type, internal.WordDiff(ch.Before.Content, ch.After.Content))
+		if ch.Before.Type != ch.After.Type {
+			fmt.Printf("    type: %s -> %s\n", ch.Before.Type, ch.After.Type)
+		}
+		if tags := internal.TagDiff(ch.Before.Tags, ch.After.Tags); len(tags) > 0 {
+			fmt.Printf("    tags: %s\n", strings.Join(tags, " "))
+		}
+	}
+	for _, project := range diff.Briefs {
+		fmt.Printf("~ brief of %s\n", project)
+	}
+	fmt.Printf("\n%d added, %d removed, %d changed, %d briefs changed since the snapshot\n",
+		len(diff.Added), len(diff.Removed), len(diff.Changed), len(diff.Briefs))
+}
+
+func cmdSnapshotRestore(c internal.Store, name string, p *internal.Project, yes bool) error {
+	diff, err := internal.DiffSnapshot(c, name, p)
+	if err != nil {
+		return err
+	}
+	if diff.Empty() {
+		fmt.Printf("Nothing to restore: no changes since snapshot %s.\n", name)
+		return nil
+	}
+	printSnapshotDiff(diff)
+
+	what := "the whole store"
+	if p != nil {
+		what = p.Key
+	}
+	if !yes && !confirm(fmt.Sprintf("\nRoll %s back to snapshot %s?", what, name)) {
+		fmt.Println("Aborted.")
+		return nil
+	}
+
+	// Briefs and aliases are outside memo undo, so keep the current state too
+	backup := "before-restore-" + time.Now().UTC().Format("20060102T150405Z")
+	if _, err := internal.CreateSnapshot(c, backup); err != nil {
+		return fmt.Errorf("snapshot before restoring: %w", err)
+	}
+
+	report, err := internal.RestoreSnapshot(c, name, p)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Restored %s: %d recreated, %d reverted, %d moved to the trash, %d briefs, %d aliases\n",
+		name, report.Recreated, report.Reverted, report.Trashed, report.Briefs, report.Aliases)
+	fmt.Printf("The previous state is snapshot %s (memo undo also reverses the memory changes)\n", backup)
+	return nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"memo/internal"
+)
+
+func cmdStale(c internal.Store, args []string) error {
+	minLines := cfg.Git.StaleLines
+	review := isTerminal(os.Stdin)
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--list":
+			review = false
+		case "--lines":
+			if i+1 < len(args) {
+				if n, err := strconv.Atoi(args[i+1]); err == nil {
+					minLines = n
+				}
+				i++
+			}
+		}
+	}
+
+	p, err := internal.ResolveProject(c, "")
+	if err != nil {
+		return err
+	}
+	stale, err := internal.FindStale(c, p, minLines)
+	if err != nil {
+		return err
+	}
+	if len(stale) == 0 {
+		fmt.Println("No stale memories.")
+		return nil
+	}
+
+	fmt.Printf("%d memories refer to files that changed:\n\n", len(stale))
+	in := bufio.NewReader(os.Stdin)
+	for _, s := range stale {
+		m := s.Memory
+		fmt.Printf("[%s] (%s) %s\n", m.ID, m.Type, m.Content)
+		for _, f := range s.Files {
+			fmt.Printf("    %s\n", describeStale(f))
+		}
+		if review {
+			if err := reviewStale(c, in, m); err != nil {
+				return err
+			}
+		}
+		fmt.Println()
+	}
+
+	if !review {
+		fmt.Println("Review each with: memo verify <id> (still accurate), memo update <id> <content>, or memo forget <id>")
+	}
+	return nil
+}
+
+// describeStale formats one changed file like a diffstat line
+func describeStale(f internal.StaleFile) string {
+	if f.Deleted {
+		return fmt.Sprintf("%-40s deleted", f.Anchor.String())
+	}
+	line := fmt.Sprintf("%-40s | +%d -%d", f.Anchor.String(), f.Added, f.Removed)
+	if f.SymbolGone {
+		line += fmt.Sprintf(" (%s not found)", f.Anchor.Symbol)
+	}
+	return line
+}
+
+// reviewStale asks what to do about one stale memory
+func reviewStale(c internal.Store, in *bufio.Reader, m internal.Memory) error {
+	for {
+		fmt.Print("  [v]erify still true, [u]pdate, [f]orget, [s]kip? ")
+		answer, err := in.ReadString('\n')
+		if err != nil && answer == "" {
+			return nil
+		}
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "v", "verify":
+			if err := internal.VerifyMemory(c, m.ID); err != nil {
+				return err
+			}
+			fmt.Println("  Verified.")
+			return nil
+		case "u", "update":
+			fmt.Print("  New content: ")
+			content, _ := in.ReadString('\n')
+			content = strings.TrimSpace(content)
+			if content == "" {
+				continue
+			}
+			if err := cmdUpdate(c, []string{m.ID, content}); err != nil {
+				return err
+			}
+			return internal.VerifyMemory(c, m.ID)
+		case "f", "forget":
+			return cmdForget(c, []string{m.ID})
+		case "s", "skip", "":
+			return nil
+		}
+	}
+}
+
+func cmdVerify(c internal.Store, args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: memo verify <id...>")
+	}
+	for _, id := range args {
+		if err := internal.VerifyMemory(c, id); err != nil {
+			return fmt.Errorf("%s: %w", id, err)
+		}
+		fmt.Printf("Verified [%s] against the current code\n", id)
+	}
+	return nil
+}
+
+// isTerminal reports whether f is an interactive terminal (/dev/null is a
+// character device too, so it is ruled out explicitly)
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	null, err := os.Stat(os.DevNull)
+	return err != nil || !os.SameFile(info, null)
+}
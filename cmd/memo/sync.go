@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"memo/internal"
+)
+
+func cmdSync(c internal.Store, args []string) error {
+	if len(args) == 0 {
+		return cmdSyncStatus()
+	}
+	switch args[0] {
+	case "serve":
+		addr := "127.0.0.1:7979"
+		if len(args) > 2 && args[1] == "--addr" {
+			addr = args[2]
+		}
+		if err := internal.CheckServeAddr(addr); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Serving this store for memo sync on http://%s\n", addr)
+		srv := &http.Server{Addr: addr, Handler: internal.SyncHandler(c), ReadHeaderTimeout: 10 * time.Second}
+		return srv.ListenAndServe()
+	case "push", "pull":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: memo sync %s <remote>", args[0])
+		}
+		remote, err := internal.ResolveRemote(args[1])
+		if err != nil {
+			return err
+		}
+		if args[0] == "push" {
+			return syncPush(c, remote)
+		}
+		return syncPull(c, remote)
+	}
+
+	// memo sync REMOTE: pull first so local edits meet the remote's before being sent
+	remote, err := internal.ResolveRemote(args[0])
+	if err != nil {
+		return err
+	}
+	if err := syncPull(c, remote); err != nil {
+		return err
+	}
+	return syncPush(c, remote)
+}
+
+func syncPull(c internal.Store, remote string) error {
+	report, err := internal.SyncPull(c, remote)
+	if report != nil {
+		fmt.Printf("Pulled from %s: %d created, %d updated, %d deleted, %d unchanged\n",
+			remote, report.Created, report.Updated, report.Deleted, report.Unchanged)
+		printSyncConflicts(report, "here", "remote")
+	}
+	return err
+}
+
+func syncPush(c internal.Store, remote string) error {
+	report, err := internal.SyncPush(c, remote)
+	if err != nil {
+		return err
+	}
+	if report.Sent == 0 {
+		fmt.Printf("Pushed to %s: nothing new\n", remote)
+		return nil
+	}
+	fmt.Printf("Pushed %d changes to %s: %d created, %d updated, %d deleted, %d unchanged\n",
+		report.Sent, remote, report.Created, report.Updated, report.Deleted, report.Unchanged)
+	printSyncConflicts(report, "remote", "here")
+	return nil
+}
+
+// printSyncConflicts shows memories changed on both sides, naming the
+// receiving and sending side from this machine's point of view
+func printSyncConflicts(report *internal.SyncReport, receiver, sender string) {
+	if len(report.Unembedded) > 0 {
+		fmt.Printf("%d memories could not be embedded; run memo reindex on the %s side\n", len(report.Unembedded), receiver)
+	}
+	if len(report.Conflicts) == 0 {
+		return
+	}
+	fmt.Printf("\n%d conflicts (the most recent change was kept):\n", len(report.Conflicts))
+	for _, conflict := range report.Conflicts {
+		kept := receiver
+		if conflict.SenderWon {
+			kept = sender
+		}
+		fmt.Printf("  [%s] kept %s\n", conflict.ID, kept)
+		fmt.Printf("    %-7s %s\n", receiver+":", syncContent(conflict.Receiver))
+		fmt.Printf("    %-7s %s\n", sender+":", syncContent(conflict.Sender))
+	}
+}
+
+func syncContent(content string) string {
+	if content == "" {
+		return "(deleted)"
+	}
+	return content
+}
+
+func cmdSyncStatus() error {
+	remotes, err := internal.SyncRemotes()
+	if err != nil {
+		return err
+	}
+	if len(remotes) == 0 {
+		fmt.Println("Not synced with any remote. Start one with: memo sync serve, then: memo sync <url>")
+		return nil
+	}
+	for _, r := range remotes {
+		fmt.Printf("%s\n  pulled: %s\n  pushed: %s\n", r.URL, orNever(r.PulledAt), orNever(r.PushedAt))
+	}
+	return nil
+}
+
+func orNever(t string) string {
+	if t == "" {
+		return "never"
+	}
+	return t
+}
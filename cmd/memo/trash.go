@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+
+	"memo/internal"
+)
+
+func cmdTrash(c internal.Store, args []string) error {
+	if len(args) > 0 && args[0] == "empty" {
+		n, err := internal.PurgeTrash(c, 0)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Permanently deleted %d memories.\n", n)
+		return nil
+	}
+	if len(args) > 0 && args[0] != "list" {
+		return fmt.Errorf("usage: memo trash [list|empty]")
+	}
+
+	if err := internal.ExpireTrash(c); err != nil {
+		return err
+	}
+	entries, err := c.TrashEntries()
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		fmt.Println("Trash is empty.")
+		return nil
+	}
+
+	kept := fmt.Sprintf("kept %d days", cfg.Trash.RetentionDays)
+	if cfg.Trash.RetentionDays == 0 {
+		kept = "kept until memo trash empty"
+	}
+	fmt.Printf("%d memories in the trash (%s)\n\n", len(entries), kept)
+	for _, e := range entries {
+		content, _ := e.Doc["content"].(string)
+		memType, _ := e.Doc["type"].(string)
+		fmt.Printf("[%s] (%s) deleted %s  %s\n", e.ID, memType, e.Deleted, content)
+	}
+	return nil
+}
+
+func cmdRestore(c internal.Store, args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: memo restore <id>")
+	}
+
+	m, err := internal.RestoreMemory(c, args[0])
+	if err != nil {
+		return err
+	}
+	internal.MarkBriefsStale(c, m)
+	fmt.Printf("Restored [%s]: %s\n", m.ID, m.Content)
+	return nil
+}
+
+func cmdUndo(c internal.Store) error {
+	op, err := internal.Undo(c)
+	if op != nil {
+		fmt.Printf("Undid: memo %s (%s, %d memories)\n", op.Command, op.Time, len(op.Changes))
+	}
+	return err
+}
@@ -0,0 +1,239 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Anchor ties a memory to a file in the repo, optionally narrowed to a
+// symbol or a line range
+type Anchor struct {
+	Path   string `json:"path" yaml:"path"`
+	Symbol string `json:"symbol,omitempty" yaml:"symbol,omitempty"`
+	Lines  string `json:"lines,omitempty" yaml:"lines,omitempty"`
+	// Blob is the file's git blob hash when the memory was stored or last verified
+	Blob string `json:"blob,omitempty" yaml:"blob,omitempty"`
+}
+
+var lineRange = regexp.MustCompile(`^\d+(-\d+)?$`)
+
+// ParseAnchor parses PATH, PATH:Symbol or PATH:10-20. PATH may be relative
+// to the working directory; it is stored relative to the repo root.
+func ParseAnchor(spec string) (Anchor, error) {
+	var a Anchor
+	file, target, _ := strings.Cut(spec, ":")
+	if file == "" {
+		return a, fmt.Errorf("invalid anchor %q (want PATH[:Symbol|:LINE[-LINE]])", spec)
+	}
+	a.Path = RepoPath(file)
+	if lineRange.MatchString(target) {
+		a.Lines = target
+	} else {
+		a.Symbol = target
+	}
+	return a, nil
+}
+
+// String formats an anchor the way ParseAnchor reads it
+func (a Anchor) String() string {
+	switch {
+	case a.Symbol != "":
+		return a.Path + ":" + a.Symbol
+	case a.Lines != "":
+		return a.Path + ":" + a.Lines
+	}
+	return a.Path
+}
+
+// Exists reports whether the anchored file is present in the working tree
+func (a Anchor) Exists() bool {
+	root := "."
+	if toplevel, err := gitOutput("rev-parse", "--show-toplevel"); err == nil {
+		root = toplevel
+	}
+	_, err := os.Stat(filepath.Join(root, filepath.FromSlash(a.Path)))
+	return err == nil
+}
+
+// RepoPath converts a path relative to the working directory (or absolute)
+// into one relative to the repo root, with forward slashes
+func RepoPath(p string) string {
+	toplevel, err := gitOutput("rev-parse", "--show-toplevel")
+	if err != nil {
+		return filepath.ToSlash(filepath.Clean(p))
+	}
+	abs := p
+	if !filepath.IsAbs(p) {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return filepath.ToSlash(filepath.Clean(p))
+		}
+		abs = filepath.Join(cwd, p)
+	}
+	// Resolve symlinks on the directory side, as git reports real paths
+	if real, err := filepath.EvalSymlinks(filepath.Dir(abs)); err == nil {
+		abs = filepath.Join(real, filepath.Base(abs))
+	}
+	rel, err := filepath.Rel(toplevel, abs)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(filepath.Clean(p))
+	}
+	return filepath.ToSlash(rel)
+}
+
+// AnchorPaths returns the distinct files a memory is anchored to
+func AnchorPaths(m Memory) []string {
+	seen := make(map[string]bool)
+	var paths []string
+	for _, a := range m.Anchors {
+		if !seen[a.Path] {
+			seen[a.Path] = true
+			paths = append(paths, a.Path)
+		}
+	}
+	return paths
+}
+
+// Matches reports whether the anchor's path matches a repo path or glob
+func (a Anchor) Matches(pattern string) bool {
+	return matchPath(pattern, a.Path)
+}
+
+// anchoredTo reports whether any anchor path matches a glob. A pattern
+// without wildcards also matches every file below it, so "internal"
+// selects internal/redis.go.
+func anchoredTo(m Memory, pattern string) bool {
+	for _, a := range m.Anchors {
+		if a.Matches(pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchPath(pattern, name string) bool {
+	pattern = strings.TrimSuffix(pattern, "/")
+	if !strings.ContainsAny(pattern, "*?[") {
+		return name == pattern || strings.HasPrefix(name, pattern+"/")
+	}
+	re := globRegexp(pattern)
+	return re != nil && re.MatchString(name)
+}
+
+// globs caches compiled patterns, since filters match one against every memory
+var globs sync.Map
+
+// globRegexp compiles a glob with path.Match's syntax, where ** also spans
+// directories. It returns nil for a malformed pattern, which matches nothing.
+func globRegexp(pattern string) *regexp.Regexp {
+	if re, ok := globs.Load(pattern); ok {
+		return re.(*regexp.Regexp)
+	}
+	re, err := compileGlob(pattern)
+	if err != nil {
+		re = nil
+	}
+	globs.Store(pattern, re)
+	return re
+}
+
+func compileGlob(pattern string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			b.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			class, n, err := globClass(pattern[i:])
+			if err != nil {
+				return nil, err
+			}
+			b.WriteString(class)
+			i += n - 1
+		case c == '\\' && i+1 < len(pattern):
+			i++
+			b.WriteString(regexp.QuoteMeta(string(pattern[i])))
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+// globClass translates the character class at the start of a glob, as
+// path.Match reads it ("[^a-z]" negates, "\\" escapes), returning the
+// regexp class and how many bytes of the glob it took
+func globClass(glob string) (string, int, error) {
+	var b strings.Builder
+	b.WriteString("[")
+	i := 1
+	if strings.HasPrefix(glob[i:], "^") {
+		b.WriteString("^")
+		i++
+	}
+	char := func() (string, bool) {
+		if i >= len(glob) || glob[i] == ']' || glob[i] == '-' {
+			return "", false
+		}
+		if glob[i] == '\\' {
+			i++
+			if i >= len(glob) {
+				return "", false
+			}
+		}
+		c := regexp.QuoteMeta(string(glob[i]))
+		i++
+		return c, true
+	}
+	for items := 0; ; items++ {
+		if i < len(glob) && glob[i] == ']' && items > 0 {
+			b.WriteString("]")
+			return b.String(), i + 1, nil
+		}
+		lo, ok := char()
+		if !ok {
+			return "", 0, path.ErrBadPattern
+		}
+		b.WriteString(lo)
+		if i < len(glob) && glob[i] == '-' {
+			i++
+			hi, ok := char()
+			if !ok {
+				return "", 0, path.ErrBadPattern
+			}
+			b.WriteString("-" + hi)
+		}
+	}
+}
+
+// globPrefix returns the literal part of a glob before its first wildcard
+func globPrefix(pattern string) string {
+	if i := strings.IndexAny(pattern, "*?["); i >= 0 {
+		return pattern[:i]
+	}
+	return strings.TrimSuffix(pattern, "/")
+}
+
+func hasAnchor(anchors []Anchor, a Anchor) bool {
+	for _, b := range anchors {
+		if b.Path == a.Path && b.Symbol == a.Symbol && b.Lines == a.Lines {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,129 @@
+package internal
+
+import (
+	"path"
+	"testing"
+)
+
+func TestMatchPath(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"internal/redis.go", "internal/redis.go", true},
+		{"internal", "internal/redis.go", true},
+		{"internal/", "internal/redis.go", true},
+		{"internal", "internal2/redis.go", false},
+		{"internal/redis.go", "internal/redis.go.orig", false},
+		{"internal/*.go", "internal/redis.go", true},
+		{"internal/*.go", "internal/sub/redis.go", false},
+		{"*.go", "main.go", true},
+		{"cmd/memo/ma?n.go", "cmd/memo/main.go", true},
+		{"internal/[rs]*.go", "internal/sqlite.go", true},
+		{"internal/[rs]*.go", "internal/anchor.go", false},
+		{"**/*.go", "main.go", true},
+		{"**/*.go", "cmd/memo/main.go", true},
+		{"**/*.go", "README.md", false},
+		{"internal/**", "internal/a/b/c.go", true},
+		{"internal/**", "cmd/main.go", false},
+		{"cmd/**/main.go", "cmd/main.go", true},
+		{"cmd/**/main.go", "cmd/memo/main.go", true},
+		{"cmd/**/main.go", "cmd/memo/main_test.go", false},
+		{"docs/**/*.md", "docs/a.b/c.md", true},
+		{"a.b/**", "aXb/c", false},
+		{"internal/**/[rs]*.go", "internal/store/sqlite.go", true},
+		{"internal/**/[rs]*.go", "internal/store/anchor.go", false},
+		{"**/[^a]*.go", "internal/anchor.go", false},
+		{"**/v[0-9].md", "docs/v2.md", true},
+		{`**/a\*b`, "x/a*b", true},
+		{`**/a\*b`, "x/axb", false},
+		{"**/[", "x/[", false},
+		{"**/[]", "x/]", false},
+	}
+	for _, tt := range tests {
+		if got := matchPath(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("matchPath(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMatchPathAgreesWithPathMatch(t *testing.T) {
+	patterns := []string{"*.go", "cmd/*/main.go", "internal/[rs]*.go", "internal/[^a-r]*", "v?.md", `a\*b`, "a[", "[]a]"}
+	names := []string{"main.go", "cmd/memo/main.go", "internal/redis.go", "internal/anchor.go", "internal/store.go", "v2.md", "a*b", "a["}
+	for _, pattern := range patterns {
+		for _, name := range names {
+			want, _ := path.Match(pattern, name)
+			if got := matchPath(pattern, name); got != want {
+				t.Errorf("matchPath(%q, %q) = %v, path.Match says %v", pattern, name, got, want)
+			}
+		}
+	}
+}
+
+func TestGlobRegexp(t *testing.T) {
+	tests := []struct {
+		pattern, want string
+	}{
+		{"**/*.go", `^(.*/)?[^/]*\.go$`},
+		{"a/**", `^a/.*$`},
+		{"a/?.txt", `^a/[^/]\.txt$`},
+		{"a+b/**/c", `^a\+b/(.*/)?c$`},
+		{"**/[^a-c.]x", `^(.*/)?[^a-c\.]x$`},
+		{`[\]]`, `^[\]]$`},
+	}
+	for _, tt := range tests {
+		if got := globRegexp(tt.pattern).String(); got != tt.want {
+			t.Errorf("globRegexp(%q) = %s, want %s", tt.pattern, got, tt.want)
+		}
+	}
+	for _, pattern := range []string{"a[", "a[]", "[-a]", "[a-]"} {
+		if re := globRegexp(pattern); re != nil {
+			t.Errorf("globRegexp(%q) = %s, want nil like path.Match's error", pattern, re)
+		}
+	}
+	if globRegexp("**/*.go") != globRegexp("**/*.go") {
+		t.Error("the compiled glob is not cached")
+	}
+}
+
+func TestGlobPrefix(t *testing.T) {
+	tests := []struct {
+		pattern, want string
+	}{
+		{"internal/**/*.go", "internal/"},
+		{"internal/", "internal"},
+		{"*.go", ""},
+		{"cmd/memo/main.go", "cmd/memo/main.go"},
+	}
+	for _, tt := range tests {
+		if got := globPrefix(tt.pattern); got != tt.want {
+			t.Errorf("globPrefix(%q) = %q, want %q", tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestParseAnchor(t *testing.T) {
+	chdir(t, t.TempDir()) // outside any repo, paths are kept as given
+	tests := []struct {
+		spec string
+		want Anchor
+	}{
+		{"internal/redis.go", Anchor{Path: "internal/redis.go"}},
+		{"internal/redis.go:Similar", Anchor{Path: "internal/redis.go", Symbol: "Similar"}},
+		{"internal/redis.go:10-20", Anchor{Path: "internal/redis.go", Lines: "10-20"}},
+		{"internal/redis.go:42", Anchor{Path: "internal/redis.go", Lines: "42"}},
+		{"./internal/../internal/redis.go", Anchor{Path: "internal/redis.go"}},
+	}
+	for _, tt := range tests {
+		got, err := ParseAnchor(tt.spec)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseAnchor(%q) = %+v, %v, want %+v", tt.spec, got, err, tt.want)
+		}
+		if back, _ := ParseAnchor(got.String()); back != got {
+			t.Errorf("%+v formats as %q, which parses as %+v", got, got.String(), back)
+		}
+	}
+	if _, err := ParseAnchor(":Symbol"); err == nil {
+		t.Error("ParseAnchor accepted an anchor without a path")
+	}
+}
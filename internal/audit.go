@@ -0,0 +1,156 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// LogEntry is one mutation in the audit log
+type LogEntry struct {
+	Time    string `json:"time"`
+	Actor   string `json:"actor"`
+	Project string `json:"project,omitempty"`
+	Command string `json:"command"`
+	// Action is what happened: remember, update, tag, merge, forget, brief...
+	Action string `json:"action"`
+	ID     string `json:"id,omitempty"`
+	// Before and After hash the memory (or brief) on either side of the
+	// change; empty when it did not exist
+	Before string `json:"before,omitempty"`
+	After  string `json:"after,omitempty"`
+}
+
+// LogQuery selects audit log entries; empty fields match everything
+type LogQuery struct {
+	// Projects matches entries for any of these project keys or aliases
+	Projects []string
+	ID       string
+	// Actor matches any actor containing it
+	Actor string
+	// Since and Until bound the entry time, inclusive (timestamps as Now formats them)
+	Since, Until string
+}
+
+// Matches reports whether an entry passes the query
+func (q LogQuery) Matches(e LogEntry) bool {
+	if len(q.Projects) > 0 && !slices.Contains(q.Projects, e.Project) {
+		return false
+	}
+	if q.ID != "" && e.ID != q.ID {
+		return false
+	}
+	if q.Actor != "" && !strings.Contains(e.Actor, q.Actor) {
+		return false
+	}
+	if q.Since != "" && e.Time < q.Since {
+		return false
+	}
+	if q.Until != "" && e.Time > q.Until {
+		return false
+	}
+	return true
+}
+
+// auditCommand is the command line entries are attributed to
+var auditCommand string
+
+// StartAudit attributes the changes that follow to a command line
+func StartAudit(command string) {
+	auditCommand = command
+}
+
+// Actor identifies who is running memo: $MEMO_ACTOR if set, else the user
+// (git email or $USER), prefixed with "claude/" when Claude Code runs it
+func Actor() string {
+	if actor := os.Getenv("MEMO_ACTOR"); actor != "" {
+		return actor
+	}
+	user := currentUser()
+	switch {
+	case os.Getenv("CLAUDECODE") == "":
+	case user == "":
+		return "claude"
+	default:
+		return "claude/" + user
+	}
+	if user == "" {
+		return "unknown"
+	}
+	return user
+}
+
+// record appends an entry to the log as soon as the change is made, so
+// a command that is interrupted still has its changes logged
+func record(s Store, action, id, project, before, after string) error {
+	err := s.AppendLog(LogEntry{
+		Time:    Now(),
+		Actor:   Actor(),
+		Project: project,
+		Command: auditCommand,
+		Action:  action,
+		ID:      id,
+		Before:  before,
+		After:   after,
+	})
+	if err != nil {
+		return fmt.Errorf("audit log not written: %w", err)
+	}
+	return nil
+}
+
+// auditMemory records a change to a memory; before is nil for new
+// memories and after is nil for deleted ones
+func auditMemory(s Store, action, id string, before, after *Memory) error {
+	project := ""
+	for _, m := range []*Memory{after, before} {
+		if m != nil && project == "" {
+			project = memoryProject(*m)
+		}
+	}
+	return record(s, action, id, project, hashMemory(before), hashMemory(after))
+}
+
+// AuditBrief records a brief regeneration
+func AuditBrief(s Store, project, before, after string) error {
+	return record(s, "brief", "", project, hashText(before), hashText(after))
+}
+
+// memoryProject returns the project a memory is filed under, if any
+func memoryProject(m Memory) string {
+	if m.Project != "" {
+		return m.Project
+	}
+	for _, tag := range m.Tags {
+		if key, ok := strings.CutPrefix(tag, "project:"); ok {
+			return key
+		}
+	}
+	return ""
+}
+
+// hashMemory fingerprints what a memory says: type, content, tags and anchors
+func hashMemory(m *Memory) string {
+	if m == nil {
+		return ""
+	}
+	data, _ := json.Marshal(struct {
+		Type    string
+		Content string
+		Tags    []string
+		Anchors []Anchor
+	}{m.Type, m.Content, m.Tags, m.Anchors})
+	return hashText(string(data))
+}
+
+func hashText(s string) string {
+	if s == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:6])
+}
@@ -0,0 +1,80 @@
+package internal
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLogQueryMatches(t *testing.T) {
+	e := LogEntry{Time: "2026-03-10T12:00:00Z", Actor: "claude/ana@example.com", Project: "github.com/acme/api", Action: "update", ID: "a1"}
+	tests := []struct {
+		name string
+		q    LogQuery
+		want bool
+	}{
+		{"empty query", LogQuery{}, true},
+		{"project or alias", LogQuery{Projects: []string{"old-api", "github.com/acme/api"}}, true},
+		{"other project", LogQuery{Projects: []string{"github.com/acme/web"}}, false},
+		{"id", LogQuery{ID: "a1"}, true},
+		{"other id", LogQuery{ID: "a2"}, false},
+		{"actor substring", LogQuery{Actor: "ana"}, true},
+		{"other actor", LogQuery{Actor: "bob"}, false},
+		{"since is inclusive", LogQuery{Since: "2026-03-10T12:00:00Z"}, true},
+		{"until is inclusive", LogQuery{Until: "2026-03-10T12:00:00Z"}, true},
+		{"after until", LogQuery{Until: "2026-03-10T11:59:59Z"}, false},
+		{"before since", LogQuery{Since: "2026-03-11T00:00:00Z"}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.q.Matches(e); got != tt.want {
+			t.Errorf("%s: Matches() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestActor(t *testing.T) {
+	t.Setenv("MEMO_ACTOR", "ci-bot")
+	if got := Actor(); got != "ci-bot" {
+		t.Errorf("Actor() = %q with MEMO_ACTOR set", got)
+	}
+}
+
+func TestAuditIsWrittenAsChangesHappen(t *testing.T) {
+	t.Setenv("MEMO_ACTOR", "tester")
+	chdir(t, t.TempDir())
+	s, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	StartAudit("memo remember fact ...")
+	memo := &Memory{Type: "fact", Content: "Releases are tagged vX.Y.Z", Project: "github.com/acme/api"}
+	if err := RememberMemory(s, memo); err != nil {
+		t.Fatal(err)
+	}
+	StartAudit("memo update ...")
+	if err := s.Update(memo.ID, "Releases are tagged X.Y.Z"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing is flushed: the entries are in the log already
+	entries, err := s.ReadLog(LogQuery{ID: memo.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actions, commands []string
+	for _, e := range entries {
+		actions = append(actions, e.Action)
+		commands = append(commands, e.Command)
+		if e.Actor != "tester" || e.Project != "github.com/acme/api" || e.After == "" {
+			t.Errorf("entry %+v", e)
+		}
+	}
+	if want := []string{"remember", "update"}; !reflect.DeepEqual(actions, want) {
+		t.Errorf("actions %v, want %v", actions, want)
+	}
+	if want := []string{"memo remember fact ...", "memo update ..."}; !reflect.DeepEqual(commands, want) {
+		t.Errorf("commands %v, want %v", commands, want)
+	}
+	if entries[0].Before != "" || entries[1].Before != entries[0].After {
+		t.Errorf("hashes do not chain: %+v", entries)
+	}
+}
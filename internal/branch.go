@@ -0,0 +1,105 @@
+package internal
+
+import (
+	"sort"
+	"strings"
+)
+
+// CurrentBranch returns the checked-out branch, "HEAD" when detached,
+// or "" outside a git repo
+func CurrentBranch() string {
+	if branch, err := gitOutput("symbolic-ref", "--quiet", "--short", "HEAD"); err == nil {
+		return branch
+	}
+	if _, err := gitOutput("rev-parse", "--git-dir"); err == nil {
+		return "HEAD"
+	}
+	return ""
+}
+
+// DefaultBranch returns the branch origin/HEAD points at, else main or master
+func DefaultBranch() string {
+	if ref, err := gitOutput("symbolic-ref", "--quiet", "--short", "refs/remotes/origin/HEAD"); err == nil {
+		return strings.TrimPrefix(ref, "origin/")
+	}
+	for _, name := range []string{"main", "master"} {
+		if BranchExists(name) {
+			return name
+		}
+	}
+	return "main"
+}
+
+// BranchExists reports whether a branch exists locally or on origin
+func BranchExists(name string) bool {
+	for _, ref := range []string{"refs/heads/" + name, "refs/remotes/origin/" + name} {
+		if _, err := gitOutput("rev-parse", "--verify", "--quiet", ref); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// BranchOf returns the branch a memory was recorded on, if any
+func BranchOf(m Memory) string {
+	for _, tag := range m.Tags {
+		if branch, ok := strings.CutPrefix(tag, "branch:"); ok {
+			return branch
+		}
+	}
+	return ""
+}
+
+// BranchMemories groups a project's branch memories by branch
+func BranchMemories(s Store, p *Project) (map[string][]Memory, error) {
+	it := s.Iterate(Filter{Projects: p.Names(), Tag: "branch:*"})
+	defer it.Close()
+
+	branches := make(map[string][]Memory)
+	for it.Next() {
+		m := it.Val()
+		if branch := BranchOf(m); branch != "" {
+			branches[branch] = append(branches[branch], m)
+		}
+	}
+	return branches, it.Err()
+}
+
+// SortedBranches returns the keys of a BranchMemories result in order
+func SortedBranches(branches map[string][]Memory) []string {
+	names := make([]string, 0, len(branches))
+	for name := range branches {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// PromoteBranch drops the branch tag from a project's memories recorded on
+// branch, so they become ordinary project memories. Returns how many moved.
+func PromoteBranch(s Store, p *Project, branch string) (int, error) {
+	memos, err := Collect(s.Iterate(Filter{Projects: p.Names(), Tag: "branch:" + branch}), 0)
+	if err != nil {
+		return 0, err
+	}
+
+	for i, m := range memos {
+		_, err := Revise(s, m.ID, "promote", "promote from branch "+branch, func(m *Memory) error {
+			var tags []string
+			for _, tag := range m.Tags {
+				if tag != "branch:"+branch {
+					tags = append(tags, tag)
+				}
+			}
+			m.Tags = tags
+			return nil
+		})
+		if err != nil {
+			return i, err
+		}
+	}
+	if len(memos) > 0 {
+		s.MarkBriefStale(p.Key)
+	}
+	return len(memos), nil
+}
@@ -0,0 +1,394 @@
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Problem classes reported by Diagnose, in report order
+const (
+	ProblemUnreadable       = "unreadable document"
+	ProblemMalformed        = "malformed document"
+	ProblemIndex            = "search index"
+	ProblemSchema           = "schema version"
+	ProblemOrphanVector     = "orphan vector"
+	ProblemMissingEmbedding = "missing embedding"
+	ProblemDimension        = "dimension mismatch"
+	ProblemOrphanBrief      = "orphan brief"
+)
+
+// ProblemKinds lists the problem classes in the order memo doctor reports them
+var ProblemKinds = []string{
+	ProblemUnreadable, ProblemMalformed, ProblemIndex, ProblemSchema,
+	ProblemOrphanVector, ProblemMissingEmbedding, ProblemDimension, ProblemOrphanBrief,
+}
+
+// Problem is one inconsistency found by Diagnose
+type Problem struct {
+	Kind   string
+	ID     string // memory ID, project name, or empty for store-wide problems
+	Detail string
+}
+
+// Diagnosis is the result of a consistency check
+type Diagnosis struct {
+	Problems []Problem
+	// Dimension is the embedding size vectors are expected to have (0 if unknown)
+	Dimension int
+	// docs holds the readable documents, for Repair
+	docs map[string]map[string]interface{}
+	// vectors is the total number of stored embeddings
+	vectors int
+}
+
+// ByKind groups problems by class
+func (d *Diagnosis) ByKind() map[string][]Problem {
+	groups := make(map[string][]Problem)
+	for _, p := range d.Problems {
+		groups[p.Kind] = append(groups[p.Kind], p)
+	}
+	return groups
+}
+
+// Diagnose checks documents, embeddings, the search index and briefs
+// against each other. expectedDim is the embedding service's output size,
+// or 0 to infer it from the most common stored dimension.
+func Diagnose(s Store, expectedDim int) (*Diagnosis, error) {
+	d := &Diagnosis{}
+
+	ids, err := s.GetAllMemoryIDs()
+	if err != nil {
+		return nil, err
+	}
+	docs, err := s.Documents()
+	if err != nil {
+		return nil, err
+	}
+	d.docs = docs
+
+	stored := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		stored[id] = true
+		if _, ok := docs[id]; !ok {
+			d.add(ProblemUnreadable, id, "cannot be parsed")
+		}
+	}
+	sorted := make([]string, 0, len(docs))
+	for id := range docs {
+		sorted = append(sorted, id)
+	}
+	sort.Strings(sorted)
+	for _, id := range sorted {
+		if issues := validateDocument(id, docs[id]); len(issues) > 0 {
+			d.add(ProblemMalformed, id, strings.Join(issues, ", "))
+		}
+	}
+
+	indexIssues, err := s.CheckIndex()
+	if err != nil {
+		return nil, err
+	}
+	for _, issue := range indexIssues {
+		d.add(ProblemIndex, "", issue)
+	}
+
+	if version, err := s.SchemaVersion(); err == nil && version < SchemaVersion {
+		d.add(ProblemSchema, "", fmt.Sprintf("version %d, expected %d (run memo migrate)", version, SchemaVersion))
+	}
+
+	dims, err := s.EmbeddingDims()
+	if err != nil {
+		return nil, err
+	}
+	d.vectors = len(dims)
+	d.Dimension = expectedDim
+	if d.Dimension == 0 {
+		d.Dimension = commonDimension(dims)
+	}
+
+	vecIDs := make([]string, 0, len(dims))
+	for id := range dims {
+		vecIDs = append(vecIDs, id)
+	}
+	sort.Strings(vecIDs)
+	for _, id := range vecIDs {
+		if !stored[id] {
+			d.add(ProblemOrphanVector, id, "no memory document")
+		} else if _, ok := docs[id]; ok && d.Dimension != 0 && dims[id] != d.Dimension {
+			d.add(ProblemDimension, id, fmt.Sprintf("%d dims, expected %d", dims[id], d.Dimension))
+		}
+	}
+	for _, id := range sorted {
+		if _, ok := dims[id]; !ok {
+			d.add(ProblemMissingEmbedding, id, "not found by similar/related")
+		}
+	}
+
+	projects, err := s.Projects()
+	if err != nil {
+		return nil, err
+	}
+	aliases, err := s.ProjectAliases()
+	if err != nil {
+		return nil, err
+	}
+	// A project renamed or aliased may keep memories tagged with an old name
+	resolve := func(name string) string {
+		if key, ok := aliases[name]; ok {
+			return key
+		}
+		return name
+	}
+	counts := make(map[string]int)
+	for p, n := range projects {
+		counts[resolve(p)] += n
+	}
+	briefs, err := s.BriefProjects()
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(briefs)
+	for _, p := range briefs {
+		if counts[resolve(p)] == 0 {
+			d.add(ProblemOrphanBrief, p, "project has no memories")
+		}
+	}
+
+	return d, nil
+}
+
+func (d *Diagnosis) add(kind, id, detail string) {
+	d.Problems = append(d.Problems, Problem{Kind: kind, ID: id, Detail: detail})
+}
+
+// Removals lists what Repair would take out of the store, one line each
+func (d *Diagnosis) Removals() []string {
+	var lines []string
+	groups := d.ByKind()
+	for _, p := range groups[ProblemUnreadable] {
+		lines = append(lines, fmt.Sprintf("[%s] move to quarantine (unreadable)", p.ID))
+	}
+	for _, p := range groups[ProblemMalformed] {
+		if emptyDocument(d.docs[p.ID]) {
+			lines = append(lines, fmt.Sprintf("[%s] move to trash (no content)", p.ID))
+		}
+	}
+	for _, p := range groups[ProblemOrphanVector] {
+		lines = append(lines, fmt.Sprintf("[%s] delete vector (no memory)", p.ID))
+	}
+	for _, p := range groups[ProblemOrphanBrief] {
+		lines = append(lines, fmt.Sprintf("delete brief of %s (no memories)", p.ID))
+	}
+	return lines
+}
+
+// emptyDocument reports a document without content, which is not worth keeping
+func emptyDocument(doc map[string]interface{}) bool {
+	content, _ := doc["content"].(string)
+	return content == ""
+}
+
+// Repair fixes what Diagnose found. Schema problems are left to memo migrate.
+// It returns the number of problems fixed and the errors of the fixes that failed.
+func Repair(s Store, d *Diagnosis) (int, []error) {
+	fixed := 0
+	var errs []error
+	groups := d.ByKind()
+
+	for _, p := range groups[ProblemUnreadable] {
+		// There is no memory to put in the trash, so the raw data is kept aside
+		journal.irreversible("quarantined unreadable memories")
+		if _, err := s.Quarantine(p.ID); err != nil {
+			errs = append(errs, fmt.Errorf("quarantine %s: %w", p.ID, err))
+			continue
+		}
+		if err := auditMemory(s, "quarantine", p.ID, nil, nil); err != nil {
+			errs = append(errs, err)
+		}
+		fixed++
+	}
+
+	for _, p := range groups[ProblemMalformed] {
+		// Empty ones are repaired too before going to the trash, since the
+		// trash needs a memory it can read (and restore)
+		journal.snapshotDocument(p.ID, d.docs[p.ID])
+		if err := s.PutDocument(p.ID, normalizeDocument(p.ID, d.docs[p.ID])); err != nil {
+			errs = append(errs, fmt.Errorf("repair %s: %w", p.ID, err))
+			continue
+		}
+		if emptyDocument(d.docs[p.ID]) {
+			if err := TrashMemory(s, p.ID); err != nil {
+				errs = append(errs, fmt.Errorf("delete %s: %w", p.ID, err))
+				continue
+			}
+		}
+		fixed++
+	}
+
+	if len(groups[ProblemIndex]) > 0 {
+		if err := s.Init(); err != nil {
+			errs = append(errs, fmt.Errorf("rebuild index: %w", err))
+		} else {
+			fixed += len(groups[ProblemIndex])
+		}
+	}
+
+	for _, p := range groups[ProblemOrphanVector] {
+		journal.irreversible("deleted orphan vectors")
+		if err := s.DeleteEmbedding(p.ID); err != nil {
+			errs = append(errs, fmt.Errorf("delete vector %s: %w", p.ID, err))
+			continue
+		}
+		fixed++
+	}
+
+	// Vectors of the wrong size are dropped and re-embedded. When every stored
+	// vector is affected (e.g. the embedding model changed) start from scratch,
+	// since a vector set only accepts one dimension.
+	reembed := groups[ProblemMissingEmbedding]
+	mismatched := groups[ProblemDimension]
+	if len(mismatched) > 0 && len(mismatched)+len(groups[ProblemOrphanVector]) >= d.vectors {
+		if err := s.DeleteEmbeddings(); err != nil {
+			errs = append(errs, fmt.Errorf("clear embeddings: %w", err))
+			mismatched = nil
+		}
+	} else {
+		for i, p := range mismatched {
+			if err := s.DeleteEmbedding(p.ID); err != nil {
+				errs = append(errs, fmt.Errorf("delete vector %s: %w", p.ID, err))
+				mismatched[i].ID = ""
+			}
+		}
+	}
+	for _, p := range mismatched {
+		if p.ID != "" {
+			reembed = append(reembed, p)
+		}
+	}
+
+	for _, p := range reembed {
+		if emptyDocument(d.docs[p.ID]) {
+			continue // trashed above as malformed
+		}
+		memo, err := documentToMemory(normalizeDocument(p.ID, d.docs[p.ID]))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("embed %s: %w", p.ID, err))
+			continue
+		}
+		embedding, err := GetDocumentEmbedding(EmbeddingInput(memo))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("embed %s: %w", p.ID, err))
+			break // the service is down; the rest would fail the same way
+		}
+		if err := s.EmbedMemory(p.ID, embedding); err != nil {
+			errs = append(errs, fmt.Errorf("embed %s: %w", p.ID, err))
+			continue
+		}
+		fixed++
+	}
+
+	for _, p := range groups[ProblemOrphanBrief] {
+		journal.irreversible("deleted orphan briefs")
+		if err := s.DeleteBrief(p.ID); err != nil {
+			errs = append(errs, fmt.Errorf("delete brief %s: %w", p.ID, err))
+			continue
+		}
+		fixed++
+	}
+
+	return fixed, errs
+}
+
+// EmbeddingInput is the text embedded for a stored memory: its tags, then its content
+func EmbeddingInput(memo *Memory) string {
+	if len(memo.Tags) == 0 {
+		return memo.Content
+	}
+	return strings.Join(memo.Tags, " ") + " " + memo.Content
+}
+
+// validateDocument lists what is wrong with a memory document
+func validateDocument(id string, doc map[string]interface{}) []string {
+	var issues []string
+	if v, _ := doc["id"].(string); v != id {
+		issues = append(issues, fmt.Sprintf("id %q does not match key", v))
+	}
+	for _, field := range []string{"type", "content"} {
+		if v, _ := doc[field].(string); v == "" {
+			issues = append(issues, "missing "+field)
+		}
+	}
+	if tags, ok := doc["tags"].([]interface{}); !ok {
+		issues = append(issues, "tags is not a list")
+	} else {
+		for _, t := range tags {
+			if _, ok := t.(string); !ok {
+				issues = append(issues, "non-string tag")
+				break
+			}
+		}
+	}
+	for _, field := range []string{"created", "accessed"} {
+		v, _ := doc[field].(string)
+		if _, err := time.Parse("2006-01-02T15:04:05Z", v); err != nil {
+			issues = append(issues, "bad "+field+" timestamp")
+		}
+	}
+	if _, ok := doc["access_count"].(float64); !ok {
+		issues = append(issues, "access_count is not a number")
+	}
+	return issues
+}
+
+// normalizeDocument fills in whatever validateDocument complains about,
+// keeping every field that is already valid
+func normalizeDocument(id string, doc map[string]interface{}) map[string]interface{} {
+	out := copyDocument(doc)
+	out["id"] = id
+	if v, _ := out["type"].(string); v == "" {
+		out["type"] = "fact"
+	}
+
+	var tags []interface{}
+	if raw, ok := out["tags"].([]interface{}); ok {
+		for _, t := range raw {
+			if s, ok := t.(string); ok {
+				tags = append(tags, s)
+			}
+		}
+	}
+	if tags == nil {
+		tags = []interface{}{}
+	}
+	out["tags"] = tags
+
+	created, _ := out["created"].(string)
+	if _, err := time.Parse("2006-01-02T15:04:05Z", created); err != nil {
+		created = Now()
+		out["created"] = created
+	}
+	if v, _ := out["accessed"].(string); v == "" {
+		out["accessed"] = created
+	} else if _, err := time.Parse("2006-01-02T15:04:05Z", v); err != nil {
+		out["accessed"] = created
+	}
+	if _, ok := out["access_count"].(float64); !ok {
+		out["access_count"] = 0
+	}
+	return out
+}
+
+// commonDimension returns the most frequent embedding size
+func commonDimension(dims map[string]int) int {
+	counts := make(map[int]int)
+	best, bestCount := 0, 0
+	for _, n := range dims {
+		counts[n]++
+		if counts[n] > bestCount || (counts[n] == bestCount && n > best) {
+			best, bestCount = n, counts[n]
+		}
+	}
+	return best
+}
@@ -0,0 +1,55 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+)
+
+// repairUndoably runs memo doctor --fix as one undoable operation
+func repairUndoably(t *testing.T, s Store) {
+	t.Helper()
+	d, err := Diagnose(s, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	BeginUndo("doctor")
+	Repair(s, d) // re-embedding fails without an embedding service
+	if err := CommitUndo(s); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUndoRepair(t *testing.T) {
+	useDefaultSettings(t)
+	s, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	malformed := map[string]interface{}{
+		"id": "m1", "content": "Builds take five minutes", "tags": []interface{}{},
+		"created": "2026-01-01T00:00:00Z", "accessed": "2026-01-01T00:00:00Z",
+	}
+	if err := s.PutDocument("m1", malformed); err != nil {
+		t.Fatal(err)
+	}
+
+	repairUndoably(t, s)
+	if m, _ := s.Peek("m1"); m.Type != "fact" {
+		t.Fatalf("repair left type %q", m.Type)
+	}
+	if _, err := Undo(s); err != nil {
+		t.Fatal(err)
+	}
+	if m, _ := s.Peek("m1"); m.Type != "" || m.Content != "Builds take five minutes" {
+		t.Errorf("undo left %+v, want the document as it was", m)
+	}
+
+	// Deleted briefs cannot come back, so undo refuses rather than claim it did
+	if err := s.SetBrief("github.com/acme/gone", "A brief"); err != nil {
+		t.Fatal(err)
+	}
+	repairUndoably(t, s)
+	if _, err := Undo(s); err == nil || !strings.Contains(err.Error(), "cannot be undone") {
+		t.Errorf("undo after deleting an orphan brief: %v", err)
+	}
+}
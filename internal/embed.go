@@ -5,14 +5,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"os"
 )
 
 func getEmbeddingsURL() string {
-	if url := os.Getenv("EMBEDDINGS_URL"); url != "" {
-		return url
-	}
-	return "http://localhost:8080/embed"
+	return settings.Embeddings.URL
 }
 
 type teiRequest struct {
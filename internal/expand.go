@@ -0,0 +1,153 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxCachedExpansions bounds the expansion cache; the oldest entries go first
+const maxCachedExpansions = 500
+
+// Expansion is an LLM's rewrite of a search query: other ways a memory
+// might phrase it, and a hypothetical memory that answers it
+type Expansion struct {
+	Query     string   `json:"query"`
+	Phrasings []string `json:"phrasings"`
+	Answer    string   `json:"answer"`
+	Created   string   `json:"created"`
+}
+
+// Texts lists what gets embedded besides the query itself
+func (e *Expansion) Texts() []string {
+	texts := append([]string{}, e.Phrasings...)
+	if e.Answer != "" {
+		texts = append(texts, e.Answer)
+	}
+	return texts
+}
+
+// expansionCachePath keeps expansions across runs; they depend on the
+// query and the model, not on the store
+func expansionCachePath() string {
+	return dataPath(filepath.Join("cache", "expansions.json"))
+}
+
+func expansionKey(query string) string {
+	return settings.LLM.Model + "\n" + strings.ToLower(strings.Join(strings.Fields(query), " "))
+}
+
+// ExpandQuery asks the LLM for alternative phrasings of query and a
+// hypothetical answer, reusing the cached expansion of an earlier search
+func ExpandQuery(query string) (*Expansion, error) {
+	cache := make(map[string]*Expansion)
+	if data, err := os.ReadFile(expansionCachePath()); err == nil {
+		// A corrupt cache is only a slower search
+		json.Unmarshal(data, &cache)
+	}
+	key := expansionKey(query)
+	if e, ok := cache[key]; ok {
+		return e, nil
+	}
+
+	prompt := fmt.Sprintf(`You are helping search a store of short memories about software projects: facts, gotchas, preferences and project background.
+
+Query: %s
+
+Write 3 alternative phrasings of the query using the words such a memory might use instead, then one hypothetical memory (one or two sentences) that would answer it. Answer in exactly this format and nothing else:
+PHRASE: ...
+PHRASE: ...
+PHRASE: ...
+ANSWER: ...`, query)
+
+	result, err := CallLLM(prompt)
+	if err != nil {
+		return nil, err
+	}
+	e := &Expansion{Query: query, Created: Now()}
+	for _, line := range strings.Split(result, "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) > 7 && strings.EqualFold(line[:7], "PHRASE:") {
+			if p := strings.TrimSpace(line[7:]); p != "" {
+				e.Phrasings = append(e.Phrasings, p)
+			}
+		} else if len(line) > 7 && strings.EqualFold(line[:7], "ANSWER:") {
+			e.Answer = strings.TrimSpace(line[7:])
+		}
+	}
+	if len(e.Texts()) == 0 {
+		return nil, fmt.Errorf("LLM returned no expansions")
+	}
+
+	cache[key] = e
+	if len(cache) > maxCachedExpansions {
+		keys := make([]string, 0, len(cache))
+		for k := range cache {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			return cache[keys[i]].Created < cache[keys[j]].Created
+		})
+		for _, k := range keys[:len(cache)-maxCachedExpansions] {
+			delete(cache, k)
+		}
+	}
+	if data, err := json.MarshalIndent(cache, "", "  "); err == nil {
+		if os.MkdirAll(filepath.Dir(expansionCachePath()), 0o755) == nil {
+			writeFileAtomic(expansionCachePath(), data)
+		}
+	}
+	return e, nil
+}
+
+// EmbedExpansion embeds each expansion text as a query, skipping any the
+// embedding service fails on
+func EmbedExpansion(e *Expansion) [][]float64 {
+	var embeddings [][]float64
+	for _, text := range e.Texts() {
+		if embedding, err := GetEmbedding(text); err == nil {
+			embeddings = append(embeddings, embedding)
+		}
+	}
+	return embeddings
+}
+
+// SimilarMerged runs Similar for each embedding and merges the neighbor
+// lists, keeping each memory's best score
+func SimilarMerged(s Store, embeddings [][]float64, limit int, f Filter) ([]SimilarResult, error) {
+	best := make(map[string]SimilarResult)
+	scores := make(map[string]float64)
+	for _, embedding := range embeddings {
+		results, err := s.Similar(embedding, limit, f)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range results {
+			score, _ := strconv.ParseFloat(r.Score, 64)
+			if prev, ok := scores[r.Memory.ID]; !ok || score > prev {
+				scores[r.Memory.ID] = score
+				best[r.Memory.ID] = r
+			}
+		}
+	}
+
+	merged := make([]SimilarResult, 0, len(best))
+	for _, r := range best {
+		merged = append(merged, r)
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		a, b := scores[merged[i].Memory.ID], scores[merged[j].Memory.ID]
+		if a != b {
+			return a > b
+		}
+		return merged[i].Memory.ID < merged[j].Memory.ID
+	})
+	if len(merged) > limit {
+		merged = merged[:limit]
+	}
+	return merged, nil
+}
@@ -0,0 +1,399 @@
+package internal
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// exportFormat is the version of the JSONL export layout
+const exportFormat = 1
+
+// Import modes for memories whose ID already exists
+const (
+	ImportSkip      = "skip"
+	ImportOverwrite = "overwrite"
+	ImportMerge     = "merge"
+)
+
+// ExportHeader is the first line of an export
+type ExportHeader struct {
+	Type          string `json:"type"`
+	Format        int    `json:"format"`
+	SchemaVersion int    `json:"schema_version"`
+	// EmbeddingModel and Dims describe the vectors in the export
+	EmbeddingModel string `json:"embedding_model"`
+	Dims           int    `json:"dims,omitempty"`
+	Exported       string `json:"exported"`
+	// Project is set when only one project was exported
+	Project string `json:"project,omitempty"`
+	// Memories is how many memory records follow
+	Memories int `json:"memories"`
+	// Snapshot is the name given to memo snapshot create
+	Snapshot string `json:"snapshot,omitempty"`
+}
+
+// ExportRecord is every line after the header: a memory with its vector,
+// a project brief, or a project alias
+type ExportRecord struct {
+	Type      string                 `json:"type"`
+	Doc       map[string]interface{} `json:"doc,omitempty"`
+	Embedding []float64              `json:"embedding,omitempty"`
+	Project   string                 `json:"project,omitempty"`
+	Brief     string                 `json:"brief,omitempty"`
+	Stale     bool                   `json:"stale,omitempty"`
+	Alias     string                 `json:"alias,omitempty"`
+}
+
+// ExportReport counts what Export wrote
+type ExportReport struct {
+	Memories, Vectors, Briefs, Aliases int
+}
+
+// Export writes memories with their vectors, briefs with their stale flags
+// and aliases as JSONL, all of them or only project p's
+func Export(s Store, w io.Writer, p *Project) (*ExportReport, error) {
+	d, err := s.Dump()
+	if err != nil {
+		return nil, err
+	}
+	var header ExportHeader
+	if p != nil {
+		header.Project = p.Key
+	}
+	return writeDump(w, d, p, header)
+}
+
+// exportFile exports to a new file at path (all of the store, or project p)
+func exportFile(s Store, path string, p *Project) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := Export(s, f, p); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// writeDump writes a dump (only project p's part, unless p is nil) in the
+// export format, filling in the rest of the header
+func writeDump(w io.Writer, d *Dump, p *Project, header ExportHeader) (*ExportReport, error) {
+	ids, err := d.memoryIDs(p)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]ExportRecord, 0, len(ids))
+	report := &ExportReport{}
+	for _, id := range ids {
+		embedding := d.Embeddings[id]
+		if len(embedding) > 0 {
+			report.Vectors++
+			if header.Dims == 0 {
+				header.Dims = len(embedding)
+			}
+		}
+		records = append(records, ExportRecord{Type: "memory", Doc: d.Docs[id], Embedding: embedding})
+	}
+	report.Memories = len(ids)
+
+	for _, project := range slices.Sorted(maps.Keys(d.Briefs)) {
+		if p != nil && !slices.Contains(p.Names(), project) {
+			continue
+		}
+		records = append(records, ExportRecord{Type: "brief", Project: project, Brief: d.Briefs[project], Stale: d.StaleBriefs[project]})
+		report.Briefs++
+	}
+
+	for _, alias := range slices.Sorted(maps.Keys(d.Aliases)) {
+		key := d.Aliases[alias]
+		if p != nil && key != p.Key {
+			continue
+		}
+		records = append(records, ExportRecord{Type: "alias", Alias: alias, Project: key})
+		report.Aliases++
+	}
+
+	header.Type = "header"
+	header.Format = exportFormat
+	header.SchemaVersion = d.SchemaVersion
+	header.EmbeddingModel = settings.Embeddings.Model
+	header.Exported = Now()
+	header.Memories = report.Memories
+
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	if err := enc.Encode(header); err != nil {
+		return nil, err
+	}
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			return nil, err
+		}
+	}
+	return report, bw.Flush()
+}
+
+// readHeader reads and checks the first line of an export
+func readHeader(scanner *bufio.Scanner) (*ExportHeader, error) {
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("empty import")
+	}
+	var header ExportHeader
+	if err := json.Unmarshal(scanner.Bytes(), &header); err != nil || header.Type != "header" {
+		return nil, fmt.Errorf("not a memo export (missing header line)")
+	}
+	if header.SchemaVersion < 0 {
+		return nil, fmt.Errorf("export has an invalid schema version %d", header.SchemaVersion)
+	}
+	if header.Format > exportFormat {
+		return nil, fmt.Errorf("export format %d is newer than this memo understands (%d)", header.Format, exportFormat)
+	}
+	return &header, nil
+}
+
+// readDump loads a whole export back into a dump
+func readDump(r io.Reader) (*ExportHeader, *Dump, error) {
+	scanner := exportScanner(r)
+	header, err := readHeader(scanner)
+	if err != nil {
+		return nil, nil, err
+	}
+	d := newDump()
+	d.SchemaVersion = header.SchemaVersion
+	for line := 2; scanner.Scan(); line++ {
+		var rec ExportRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return nil, nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		switch rec.Type {
+		case "memory":
+			id, _ := rec.Doc["id"].(string)
+			if id == "" {
+				return nil, nil, fmt.Errorf("line %d: memory without an id", line)
+			}
+			d.Docs[id] = rec.Doc
+			if len(rec.Embedding) > 0 {
+				d.Embeddings[id] = rec.Embedding
+			}
+		case "brief":
+			d.Briefs[rec.Project] = rec.Brief
+			d.StaleBriefs[rec.Project] = rec.Stale
+		case "alias":
+			d.Aliases[rec.Alias] = rec.Project
+		}
+	}
+	return header, d, scanner.Err()
+}
+
+func exportScanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 1<<20), 64<<20)
+	return scanner
+}
+
+// ImportReport counts what Import did
+type ImportReport struct {
+	Added, Skipped, Overwritten, Merged int
+	// Reembedded counts memories embedded afresh because the export's model
+	// differs from ours or it had no vector for them
+	Reembedded      int
+	Briefs, Aliases int
+	// Unembedded lists memories that could not be embedded
+	Unembedded []string
+}
+
+// Import reads an export written by Export. Memories whose ID exists are
+// skipped, overwritten or merged according to mode. Vectors are reused when
+// the export was embedded with the same model, otherwise memories are
+// embedded again.
+func Import(s Store, r io.Reader, mode string) (*ImportReport, error) {
+	if mode != ImportSkip && mode != ImportOverwrite && mode != ImportMerge {
+		return nil, fmt.Errorf("unknown import mode: %s (use skip, overwrite or merge)", mode)
+	}
+
+	scanner := exportScanner(r)
+	header, err := readHeader(scanner)
+	if err != nil {
+		return nil, err
+	}
+	if header.SchemaVersion > SchemaVersion {
+		return nil, fmt.Errorf("export has schema version %d, newer than this binary (%d); upgrade memo first", header.SchemaVersion, SchemaVersion)
+	}
+	version, err := s.SchemaVersion()
+	if err != nil {
+		return nil, err
+	}
+	if header.SchemaVersion > version {
+		return nil, fmt.Errorf("export has schema version %d, this store %d (run memo migrate first)", header.SchemaVersion, version)
+	}
+	reuseVectors := header.EmbeddingModel == settings.Embeddings.Model
+	aliases, err := s.ProjectAliases()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ImportReport{}
+	for line := 2; scanner.Scan(); line++ {
+		var rec ExportRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return report, fmt.Errorf("line %d: %w", line, err)
+		}
+		var err error
+		switch rec.Type {
+		case "memory":
+			// Bring documents from an older export up to the schema we write
+			for _, m := range migrations[header.SchemaVersion:] {
+				if err = m.Apply(rec.Doc); err != nil {
+					return report, fmt.Errorf("line %d: migration v%d: %w", line, m.Version, err)
+				}
+			}
+			err = importMemory(s, rec, mode, reuseVectors, report)
+		case "brief":
+			err = importBrief(s, rec, mode, report)
+		case "alias":
+			if _, exists := aliases[rec.Alias]; !exists || mode == ImportOverwrite {
+				err = s.SetProjectAlias(rec.Alias, rec.Project)
+				aliases[rec.Alias] = rec.Project
+				report.Aliases++
+			}
+		}
+		if err != nil {
+			return report, fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+	return report, scanner.Err()
+}
+
+func importMemory(s Store, rec ExportRecord, mode string, reuseVectors bool, report *ImportReport) error {
+	incoming, err := documentToMemory(rec.Doc)
+	if err != nil {
+		return err
+	}
+	if incoming.ID == "" {
+		return fmt.Errorf("memory without an id")
+	}
+	if err := ValidateID(incoming.ID); err != nil {
+		return err
+	}
+	id := incoming.ID
+
+	existing, err := s.Peek(id)
+	switch {
+	case err != nil:
+		report.Added++
+		existing = nil
+		journal.created(id)
+	case mode == ImportSkip:
+		report.Skipped++
+		return nil
+	case mode == ImportOverwrite:
+		report.Overwritten++
+		journal.snapshot(s, existing, true)
+	default:
+		report.Merged++
+		merged, err := Revise(s, id, "import", "merge import", func(m *Memory) error {
+			mergeImported(m, incoming)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if merged.Content == existing.Content {
+			return nil
+		}
+		// The imported content won: its vector applies
+		return importEmbedding(s, merged, rec.Embedding, reuseVectors, report)
+	}
+
+	if err := s.PutDocument(id, rec.Doc); err != nil {
+		return err
+	}
+	if err := auditMemory(s, "import", id, existing, incoming); err != nil {
+		return err
+	}
+	return importEmbedding(s, incoming, rec.Embedding, reuseVectors, report)
+}
+
+// mergeImported folds an imported copy into a stored memory: the content
+// changed most recently wins, tags and anchors are combined and access
+// counts added up
+func mergeImported(m, incoming *Memory) {
+	if lastChanged(incoming) > lastChanged(m) {
+		m.Content = incoming.Content
+	}
+	for _, tag := range incoming.Tags {
+		if !hasTag(m.Tags, tag) {
+			m.Tags = append(m.Tags, tag)
+		}
+	}
+	for _, a := range incoming.Anchors {
+		if !hasAnchor(m.Anchors, a) {
+			m.Anchors = append(m.Anchors, a)
+		}
+	}
+	if incoming.Verified > m.Verified {
+		m.Verified, m.Commit = incoming.Verified, incoming.Commit
+	}
+	m.AccessCount += incoming.AccessCount
+	if incoming.Accessed > m.Accessed {
+		m.Accessed = incoming.Accessed
+	}
+}
+
+// lastChanged is when a memory's content was last written
+func lastChanged(m *Memory) string {
+	if n := len(m.Revisions); n > 0 {
+		return m.Revisions[n-1].Time
+	}
+	return m.Created
+}
+
+// importEmbedding stores the exported vector, or embeds the memory the way
+// memo remember and memo doctor do when the vector cannot be reused
+func importEmbedding(s Store, m *Memory, embedding []float64, reuse bool, report *ImportReport) error {
+	if !reuse || len(embedding) == 0 {
+		var err error
+		if embedding, err = GetDocumentEmbedding(EmbeddingInput(m)); err != nil {
+			report.Unembedded = append(report.Unembedded, m.ID)
+			return nil
+		}
+		report.Reembedded++
+	}
+	return s.EmbedMemory(m.ID, embedding)
+}
+
+func importBrief(s Store, rec ExportRecord, mode string, report *ImportReport) error {
+	if existing, _ := s.GetBrief(rec.Project); existing != "" {
+		switch mode {
+		case ImportSkip:
+			return nil
+		case ImportMerge:
+			// Both sides may know things the stored brief lacks
+			s.MarkBriefStale(rec.Project)
+			return nil
+		}
+	}
+	if err := s.SetBrief(rec.Project, rec.Brief); err != nil {
+		return err
+	}
+	if rec.Stale {
+		s.MarkBriefStale(rec.Project)
+	} else {
+		s.MarkBriefFresh(rec.Project)
+	}
+	report.Briefs++
+	return nil
+}
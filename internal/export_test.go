@@ -0,0 +1,80 @@
+package internal
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// exportOf writes an export with the given header schema version and
+// memory documents, without vectors
+func exportOf(t *testing.T, version int, docs ...map[string]interface{}) *strings.Reader {
+	t.Helper()
+	var b strings.Builder
+	enc := json.NewEncoder(&b)
+	enc.Encode(ExportHeader{Type: "header", Format: exportFormat, SchemaVersion: version, EmbeddingModel: "other-model", Memories: len(docs)})
+	for _, doc := range docs {
+		enc.Encode(ExportRecord{Type: "memory", Doc: doc})
+	}
+	return strings.NewReader(b.String())
+}
+
+func TestImportMigratesAndEmbedsLikeRemember(t *testing.T) {
+	useDefaultSettings(t)
+	var embedded []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req teiRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		embedded = append(embedded, req.Inputs)
+		json.NewEncoder(w).Encode([][]float64{{1, 0}})
+	}))
+	defer srv.Close()
+	settings.Embeddings.URL = srv.URL
+
+	s, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetSchemaVersion(SchemaVersion); err != nil {
+		t.Fatal(err)
+	}
+	old := map[string]interface{}{
+		"id": "old", "type": "fact", "content": "Written before projects had a field",
+		"tags": []interface{}{"project:github.com/acme/api"}, "created": "2025-01-01T00:00:00Z", "accessed": "2025-01-01T00:00:00Z",
+	}
+	report, err := Import(s, exportOf(t, 0, old), ImportSkip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := s.Peek("old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Project != "github.com/acme/api" {
+		t.Errorf("project = %q, want the v1 migration applied", m.Project)
+	}
+	want := "search_document: " + EmbeddingInput(m)
+	if report.Reembedded != 1 || len(embedded) != 1 || embedded[0] != want {
+		t.Errorf("embedded %q, want %q", embedded, want)
+	}
+}
+
+func TestImportRefusesNewerSchemas(t *testing.T) {
+	useDefaultSettings(t)
+	s, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// An unmigrated store is older than any current export
+	if _, err := Import(s, exportOf(t, SchemaVersion), ImportSkip); err == nil || !strings.Contains(err.Error(), "memo migrate") {
+		t.Errorf("import into a v0 store: %v", err)
+	}
+	if err := s.SetSchemaVersion(SchemaVersion); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Import(s, exportOf(t, SchemaVersion+1), ImportSkip); err == nil || !strings.Contains(err.Error(), "upgrade memo") {
+		t.Errorf("import of a newer export: %v", err)
+	}
+}
@@ -0,0 +1,1102 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// fileIndexName is the sidecar holding everything that can be rebuilt:
+// the term index (memo init) and embeddings (memo reindex)
+const fileIndexName = ".memo-index.json"
+
+// fileMetaName records the schema version; unlike the sidecar it belongs in git
+const fileMetaName = "meta.json"
+
+// fileAliasesName maps project aliases to project keys, also kept in git
+const fileAliasesName = "aliases.json"
+
+// fileTrashDir holds one JSON file per deleted memory
+const fileTrashDir = ".trash"
+
+// fileQuarantineDir holds memory files memo doctor could not read
+const fileQuarantineDir = ".quarantine"
+
+// fileUndoName is the undo stack, newest operation last
+const fileUndoName = ".memo-undo.json"
+
+// fileLogName is the audit log, one JSON entry per line
+const fileLogName = ".memo-log.jsonl"
+
+// fileIndexLock is held while the sidecar is merged and rewritten, while a
+// memory file changes together with its index entries, and during Dump
+const fileIndexLock = fileIndexName + ".lock"
+
+// A lock older than staleLockAge was left by a crashed process
+const staleLockAge = 30 * time.Second
+
+// FileStore is the Store backed by a directory of markdown files,
+// one per memory, with YAML frontmatter for everything but the content.
+// Briefs live under briefs/ so knowledge changes can be reviewed in git.
+type FileStore struct {
+	dir   string
+	index *fileIndex
+	// changed is what this process wrote to the index since the last save
+	changed indexChanges
+}
+
+var _ Store = (*FileStore)(nil)
+
+type fileIndex struct {
+	Terms       map[string][]string  `json:"terms"`
+	Vectors     map[string][]float64 `json:"vectors"`
+	StaleBriefs map[string]bool      `json:"stale_briefs"`
+}
+
+// indexChanges lists the index keys set or deleted since the last save;
+// the all* flags mean the whole map was replaced
+type indexChanges struct {
+	terms, vectors, briefs map[string]bool
+	allTerms, allVectors   bool
+}
+
+// NewFileStore opens (creating if needed) the memory directory at dir
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(filepath.Join(dir, "briefs"), 0o755); err != nil {
+		return nil, err
+	}
+
+	s := &FileStore{dir: dir}
+	index, err := s.readIndex()
+	if os.IsNotExist(err) {
+		s.index = index
+		if err := s.rebuildTerms(); err != nil {
+			return nil, err
+		}
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.index = index
+	return s, nil
+}
+
+// readIndex loads the sidecar as saved on disk (empty if there is none yet)
+func (s *FileStore) readIndex() (*fileIndex, error) {
+	index := &fileIndex{}
+	data, err := os.ReadFile(filepath.Join(s.dir, fileIndexName))
+	if err != nil {
+		index.ensureMaps()
+		return index, err
+	}
+	if err := json.Unmarshal(data, index); err != nil {
+		return nil, fmt.Errorf("corrupt %s (delete it and run memo init): %w", fileIndexName, err)
+	}
+	index.ensureMaps()
+	return index, nil
+}
+
+func (ix *fileIndex) ensureMaps() {
+	if ix.Terms == nil {
+		ix.Terms = make(map[string][]string)
+	}
+	if ix.Vectors == nil {
+		ix.Vectors = make(map[string][]float64)
+	}
+	if ix.StaleBriefs == nil {
+		ix.StaleBriefs = make(map[string]bool)
+	}
+}
+
+// Close is a no-op; every mutation is written through immediately
+func (s *FileStore) Close() error {
+	return nil
+}
+
+// fileIgnored lists what Init keeps out of git: everything local to this
+// machine rather than knowledge worth reviewing
+var fileIgnored = []string{fileIndexName, fileIndexLock, fileUndoName, fileLogName, fileTrashDir + "/", fileQuarantineDir + "/"}
+
+// Init rebuilds the term index from the memory files and keeps the
+// sidecar out of git
+func (s *FileStore) Init() error {
+	if err := s.updateGitignore(); err != nil {
+		return err
+	}
+	return s.rebuildTerms()
+}
+
+// updateGitignore appends whatever fileIgnored entries the store's
+// .gitignore lacks, so stores created by older versions catch up
+func (s *FileStore) updateGitignore() error {
+	path := filepath.Join(s.dir, ".gitignore")
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	present := strings.Fields(string(data))
+	var missing []string
+	for _, entry := range fileIgnored {
+		if !slices.Contains(present, entry) {
+			missing = append(missing, entry+"\n")
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	if len(data) > 0 && !bytes.HasSuffix(data, []byte("\n")) {
+		data = append(data, '\n')
+	}
+	data = append(data, strings.Join(missing, "")...)
+	return os.WriteFile(path, data, 0o644)
+}
+
+func (s *FileStore) rebuildTerms() error {
+	memos, err := s.allMemories()
+	if err != nil {
+		return err
+	}
+	s.index.Terms = make(map[string][]string)
+	for _, m := range memos {
+		s.index.Terms[m.ID] = uniqueTerms(m.Content)
+	}
+	s.changed.allTerms = true
+	return s.saveIndex()
+}
+
+// setTerms, setVector and setBriefStale change the index in memory and
+// note the change for saveIndex; a nil value (or !ok) deletes the entry
+func (s *FileStore) setTerms(id string, terms []string) {
+	if terms == nil {
+		delete(s.index.Terms, id)
+	} else {
+		s.index.Terms[id] = terms
+	}
+	s.changed.terms = noteChange(s.changed.terms, id)
+}
+
+func (s *FileStore) setVector(id string, vec []float64) {
+	if vec == nil {
+		delete(s.index.Vectors, id)
+	} else {
+		s.index.Vectors[id] = vec
+	}
+	s.changed.vectors = noteChange(s.changed.vectors, id)
+}
+
+func (s *FileStore) setBriefStale(project string, stale, ok bool) {
+	if ok {
+		s.index.StaleBriefs[project] = stale
+	} else {
+		delete(s.index.StaleBriefs, project)
+	}
+	s.changed.briefs = noteChange(s.changed.briefs, project)
+}
+
+func noteChange(keys map[string]bool, key string) map[string]bool {
+	if keys == nil {
+		keys = make(map[string]bool)
+	}
+	keys[key] = true
+	return keys
+}
+
+// locked runs fn holding the store's lock file, so concurrent memo
+// processes neither drop each other's index entries nor see a memory file
+// without them
+func (s *FileStore) locked(fn func() error) error {
+	unlock, err := lockFile(filepath.Join(s.dir, fileIndexLock))
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return fn()
+}
+
+// saveIndex writes this process's index changes on top of the sidecar as
+// currently saved
+func (s *FileStore) saveIndex() error {
+	return s.locked(s.writeIndex)
+}
+
+// mergedIndex is the sidecar as currently saved with this process's
+// unsaved changes applied; the caller holds the lock
+func (s *FileStore) mergedIndex() (*fileIndex, error) {
+	merged, err := s.readIndex()
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	merged.Terms = mergeIndex(merged.Terms, s.index.Terms, s.changed.terms, s.changed.allTerms)
+	merged.Vectors = mergeIndex(merged.Vectors, s.index.Vectors, s.changed.vectors, s.changed.allVectors)
+	merged.StaleBriefs = mergeIndex(merged.StaleBriefs, s.index.StaleBriefs, s.changed.briefs, false)
+	return merged, nil
+}
+
+// writeIndex is saveIndex for a caller that holds the lock
+func (s *FileStore) writeIndex() error {
+	merged, err := s.mergedIndex()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(merged)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(filepath.Join(s.dir, fileIndexName), data); err != nil {
+		return err
+	}
+	s.index, s.changed = merged, indexChanges{}
+	return nil
+}
+
+// mergeIndex applies ours to saved: the changed keys, or everything if all
+func mergeIndex[V any](saved, ours map[string]V, changed map[string]bool, all bool) map[string]V {
+	if all {
+		return ours
+	}
+	for key := range changed {
+		if v, ok := ours[key]; ok {
+			saved[key] = v
+		} else {
+			delete(saved, key)
+		}
+	}
+	return saved
+}
+
+// lockFile takes an exclusive lock by creating path, waiting for another
+// holder to finish; the returned func releases it
+func lockFile(path string) (func(), error) {
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > staleLockAge {
+			os.Remove(path)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for %s (remove it if no memo is running)", path)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// memoryPath is the file for a memory, refusing IDs that would escape the directory
+func (s *FileStore) memoryPath(id string) (string, error) {
+	if err := ValidateID(id); err != nil {
+		return "", err
+	}
+	return filepath.Join(s.dir, id+".md"), nil
+}
+
+func (s *FileStore) briefPath(project string) string {
+	return filepath.Join(s.dir, "briefs", url.PathEscape(project)+".md")
+}
+
+// Remember stores a new memory
+func (s *FileStore) Remember(memo *Memory) error {
+	initMemory(memo)
+	return s.put(memo)
+}
+
+// put writes a memory file and refreshes its terms in the index
+func (s *FileStore) put(memo *Memory) error {
+	path, err := s.memoryPath(memo.ID)
+	if err != nil {
+		return err
+	}
+	data, err := marshalMemoryFile(memo)
+	if err != nil {
+		return err
+	}
+	return s.locked(func() error {
+		if err := writeFileAtomic(path, data); err != nil {
+			return err
+		}
+		s.setTerms(memo.ID, uniqueTerms(memo.Content))
+		return s.writeIndex()
+	})
+}
+
+// getMemoryRaw reads a memory without updating access stats
+func (s *FileStore) getMemoryRaw(id string) (*Memory, error) {
+	path, err := s.memoryPath(id)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("memory not found: %s", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return unmarshalMemoryFile(data)
+}
+
+// Peek retrieves a memory without updating access stats
+func (s *FileStore) Peek(id string) (*Memory, error) {
+	return s.getMemoryRaw(id)
+}
+
+// Get retrieves a specific memory and updates access stats
+func (s *FileStore) Get(id string) (*Memory, error) {
+	memo, err := s.getMemoryRaw(id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Update access stats (the returned copy keeps the previous values, as in Redis)
+	updated := *memo
+	updated.AccessCount++
+	updated.Accessed = Now()
+	if data, err := marshalMemoryFile(&updated); err == nil {
+		path, _ := s.memoryPath(id)
+		writeFileAtomic(path, data)
+	}
+
+	return memo, nil
+}
+
+// Update modifies a memory's content
+func (s *FileStore) Update(id, content string) error {
+	return updateContent(s, id, content)
+}
+
+// AddTag adds a tag to an existing memory
+func (s *FileStore) AddTag(id, tag string) error {
+	return addTag(s, id, tag)
+}
+
+// Forget deletes a memory file and its index entries
+func (s *FileStore) Forget(id string) error {
+	path, err := s.memoryPath(id)
+	if err != nil {
+		return err
+	}
+	return s.locked(func() error {
+		err := os.Remove(path)
+		if os.IsNotExist(err) {
+			return fmt.Errorf("memory not found: %s", id)
+		}
+		if err != nil {
+			return err
+		}
+		s.setTerms(id, nil)
+		s.setVector(id, nil)
+		return s.writeIndex()
+	})
+}
+
+// Recall returns memories containing every query term ("term*" matches a prefix)
+func (s *FileStore) Recall(query string, limit int) ([]Memory, error) {
+	terms := queryTerms(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	var ids []string
+	for id, docTerms := range s.index.Terms {
+		if containsAllTerms(docTerms, terms) {
+			ids = append(ids, id)
+		}
+	}
+
+	memos := s.loadMemories(ids)
+	if len(memos) > limit {
+		memos = memos[:limit]
+	}
+	return memos, nil
+}
+
+// TextSearch matches every word of query as plain text
+func (s *FileStore) TextSearch(query string, limit int) ([]Memory, error) {
+	return s.Recall(strings.Join(tokenize(query), " "), limit)
+}
+
+// KeywordSearch ranks the term index with BM25
+func (s *FileStore) KeywordSearch(query string, limit int, f Filter) ([]Memory, error) {
+	var memos []Memory
+	for _, item := range bm25(s.index.Terms, tokenize(query)) {
+		if len(memos) >= limit {
+			break
+		}
+		memo, err := s.getMemoryRaw(item.id)
+		if err != nil || !f.Matches(*memo) {
+			continue
+		}
+		memos = append(memos, *memo)
+	}
+	return memos, nil
+}
+
+// Iterate yields the memories matching the filter, oldest first. Every file
+// is read up front, since ordering by creation time needs them all anyway.
+func (s *FileStore) Iterate(f Filter) MemoryIterator {
+	return &batchIterator{
+		filter: f.Matches,
+		fetch: func() ([]Memory, bool, error) {
+			memos, err := s.allMemories()
+			return memos, true, err
+		},
+	}
+}
+
+// Count returns the number of memories matching the filter
+func (s *FileStore) Count(f Filter) (int, error) {
+	return countMatching(s.Iterate(f))
+}
+
+// allMemories reads every memory file, oldest first
+func (s *FileStore) allMemories() ([]Memory, error) {
+	ids, err := s.GetAllMemoryIDs()
+	if err != nil {
+		return nil, err
+	}
+	return s.loadMemories(ids), nil
+}
+
+// loadMemories reads the given memories, skipping unreadable files,
+// and orders them by creation time
+func (s *FileStore) loadMemories(ids []string) []Memory {
+	var memos []Memory
+	for _, id := range ids {
+		memo, err := s.getMemoryRaw(id)
+		if err != nil {
+			continue
+		}
+		memos = append(memos, *memo)
+	}
+	sort.Slice(memos, func(i, j int) bool {
+		if memos[i].Created != memos[j].Created {
+			return memos[i].Created < memos[j].Created
+		}
+		return memos[i].ID < memos[j].ID
+	})
+	return memos
+}
+
+// GetAllMemoryIDs returns all memory IDs for reindexing. Markdown files
+// that do not start with memo's frontmatter (a README, say) are not memories.
+func (s *FileStore) GetAllMemoryIDs() ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(s.dir, "*.md"))
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(paths))
+	for _, p := range paths {
+		if isMemoryFile(p) {
+			ids = append(ids, strings.TrimSuffix(filepath.Base(p), ".md"))
+		}
+	}
+	return ids, nil
+}
+
+// isMemoryFile reports whether a file starts the way marshalMemoryFile writes one
+func isMemoryFile(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	head := make([]byte, len(memoryFileHead))
+	n, _ := io.ReadFull(f, head)
+	return string(head[:n]) == memoryFileHead
+}
+
+// Projects returns all projects with their memory counts
+func (s *FileStore) Projects() (map[string]int, error) {
+	memos, err := s.allMemories()
+	if err != nil {
+		return nil, err
+	}
+	projects := make(map[string]int)
+	for _, m := range memos {
+		for _, tag := range m.Tags {
+			if len(tag) > 8 && tag[:8] == "project:" {
+				projects[tag[8:]]++
+			}
+		}
+	}
+	return projects, nil
+}
+
+// ProjectAliases reads aliases.json
+func (s *FileStore) ProjectAliases() (map[string]string, error) {
+	aliases := make(map[string]string)
+	data, err := os.ReadFile(filepath.Join(s.dir, fileAliasesName))
+	if os.IsNotExist(err) {
+		return aliases, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &aliases); err != nil {
+		return nil, fmt.Errorf("corrupt %s: %w", fileAliasesName, err)
+	}
+	return aliases, nil
+}
+
+// SetProjectAlias makes alias resolve to a project key
+func (s *FileStore) SetProjectAlias(alias, key string) error {
+	aliases, err := s.ProjectAliases()
+	if err != nil {
+		return err
+	}
+	aliases[alias] = key
+	return s.saveAliases(aliases)
+}
+
+// DeleteProjectAlias removes an alias
+func (s *FileStore) DeleteProjectAlias(alias string) error {
+	aliases, err := s.ProjectAliases()
+	if err != nil {
+		return err
+	}
+	delete(aliases, alias)
+	return s.saveAliases(aliases)
+}
+
+func (s *FileStore) saveAliases(aliases map[string]string) error {
+	data, err := json.MarshalIndent(aliases, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(s.dir, fileAliasesName), append(data, '\n'))
+}
+
+// Stats returns memory statistics
+func (s *FileStore) Stats() (map[string]int, error) {
+	memos, err := s.allMemories()
+	if err != nil {
+		return nil, err
+	}
+	stats := map[string]int{"fact": 0, "context": 0, "learned": 0, "preference": 0}
+	for _, m := range memos {
+		if _, ok := stats[m.Type]; ok {
+			stats[m.Type]++
+		}
+	}
+	stats["total"] = len(memos)
+	return stats, nil
+}
+
+// EmbedMemory stores a memory's embedding in the sidecar index
+func (s *FileStore) EmbedMemory(id string, embedding []float64) error {
+	s.setVector(id, embedding)
+	return s.saveIndex()
+}
+
+// GetEmbeddingByID returns the stored embedding for a memory
+func (s *FileStore) GetEmbeddingByID(id string) ([]float64, error) {
+	embedding, ok := s.index.Vectors[id]
+	if !ok {
+		return nil, fmt.Errorf("no embedding for memory: %s", id)
+	}
+	return embedding, nil
+}
+
+// Similar finds semantically similar memories by scanning every embedding
+func (s *FileStore) Similar(embedding []float64, limit int, f Filter) ([]SimilarResult, error) {
+	var items []scoredID
+	for id, vec := range s.index.Vectors {
+		items = append(items, scoredID{id, cosineScore(embedding, vec)})
+	}
+
+	var results []SimilarResult
+	for _, item := range topScores(items, len(items)) {
+		if len(results) >= limit {
+			break
+		}
+		memo, err := s.getMemoryRaw(item.id)
+		if err != nil {
+			continue
+		}
+		if !f.Matches(*memo) {
+			continue
+		}
+		results = append(results, SimilarResult{
+			Memory: *memo,
+			Score:  fmt.Sprintf("%.2f", item.score),
+		})
+	}
+	return results, nil
+}
+
+// DeleteEmbeddings removes all vectors for reindexing
+func (s *FileStore) DeleteEmbeddings() error {
+	s.index.Vectors = make(map[string][]float64)
+	s.changed.allVectors = true
+	return s.saveIndex()
+}
+
+// DeleteEmbedding removes one memory's vector
+func (s *FileStore) DeleteEmbedding(id string) error {
+	s.setVector(id, nil)
+	return s.saveIndex()
+}
+
+// EmbeddingDims returns the dimension of every stored embedding
+func (s *FileStore) EmbeddingDims() (map[string]int, error) {
+	dims := make(map[string]int, len(s.index.Vectors))
+	for id, vec := range s.index.Vectors {
+		dims[id] = len(vec)
+	}
+	return dims, nil
+}
+
+// CheckIndex compares the sidecar term index against the memory files
+func (s *FileStore) CheckIndex() ([]string, error) {
+	ids, err := s.GetAllMemoryIDs()
+	if err != nil {
+		return nil, err
+	}
+	onDisk := make(map[string]bool, len(ids))
+	missing := 0
+	for _, id := range ids {
+		onDisk[id] = true
+		if _, ok := s.index.Terms[id]; !ok {
+			missing++
+		}
+	}
+	stale := 0
+	for id := range s.index.Terms {
+		if !onDisk[id] {
+			stale++
+		}
+	}
+
+	var issues []string
+	if missing > 0 {
+		issues = append(issues, fmt.Sprintf("%d memory files missing from %s", missing, fileIndexName))
+	}
+	if stale > 0 {
+		issues = append(issues, fmt.Sprintf("%d %s entries for deleted files", stale, fileIndexName))
+	}
+	return issues, nil
+}
+
+// GetBrief returns the stored brief for a project
+func (s *FileStore) GetBrief(project string) (string, error) {
+	data, err := os.ReadFile(s.briefPath(project))
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// SetBrief stores a brief for a project
+func (s *FileStore) SetBrief(project, brief string) error {
+	return writeFileAtomic(s.briefPath(project), []byte(brief))
+}
+
+// IsBriefStale checks if the brief needs regeneration
+func (s *FileStore) IsBriefStale(project string) bool {
+	stale, ok := s.index.StaleBriefs[project]
+	if !ok {
+		return true // No stale flag means never generated
+	}
+	return stale
+}
+
+// MarkBriefStale marks a project's brief as needing regeneration
+func (s *FileStore) MarkBriefStale(project string) {
+	s.setBriefStale(project, true, true)
+	s.saveIndex()
+}
+
+// MarkBriefFresh marks a project's brief as up to date
+func (s *FileStore) MarkBriefFresh(project string) {
+	s.setBriefStale(project, false, true)
+	s.saveIndex()
+}
+
+// BriefProjects returns every project with a brief file or stale flag
+func (s *FileStore) BriefProjects() ([]string, error) {
+	seen := make(map[string]bool)
+	var projects []string
+	paths, err := filepath.Glob(filepath.Join(s.dir, "briefs", "*.md"))
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range paths {
+		project, err := url.PathUnescape(strings.TrimSuffix(filepath.Base(p), ".md"))
+		if err == nil && !seen[project] {
+			seen[project] = true
+			projects = append(projects, project)
+		}
+	}
+	for project := range s.index.StaleBriefs {
+		if !seen[project] {
+			seen[project] = true
+			projects = append(projects, project)
+		}
+	}
+	return projects, nil
+}
+
+// DeleteBrief removes a project's brief file and stale flag
+func (s *FileStore) DeleteBrief(project string) error {
+	if err := os.Remove(s.briefPath(project)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	s.setBriefStale(project, false, false)
+	return s.saveIndex()
+}
+
+// SchemaVersion returns the schema version recorded in meta.json
+func (s *FileStore) SchemaVersion() (int, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, fileMetaName))
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	var meta struct {
+		SchemaVersion int `json:"schema_version"`
+	}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return 0, err
+	}
+	return meta.SchemaVersion, nil
+}
+
+// SetSchemaVersion records the schema version in meta.json
+func (s *FileStore) SetSchemaVersion(version int) error {
+	data, err := json.MarshalIndent(map[string]int{"schema_version": version}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(s.dir, fileMetaName), append(data, '\n'))
+}
+
+// Documents returns every memory file as a decoded JSON document
+func (s *FileStore) Documents() (map[string]map[string]interface{}, error) {
+	memos, err := s.allMemories()
+	if err != nil {
+		return nil, err
+	}
+	docs := make(map[string]map[string]interface{}, len(memos))
+	for i := range memos {
+		doc, err := memoryToDocument(&memos[i])
+		if err != nil {
+			return nil, err
+		}
+		docs[memos[i].ID] = doc
+	}
+	return docs, nil
+}
+
+// Dump reads every memory file in one pass under the store's lock, so no
+// other memo changes a memory meanwhile. Vectors and stale flags come from
+// the sidecar as saved, reloaded under the same lock.
+func (s *FileStore) Dump() (*Dump, error) {
+	var d *Dump
+	err := s.locked(func() error {
+		index, err := s.mergedIndex()
+		if err != nil {
+			return err
+		}
+		s.index = index
+		d, err = s.dump()
+		return err
+	})
+	return d, err
+}
+
+func (s *FileStore) dump() (*Dump, error) {
+	d := newDump()
+	var err error
+	if d.SchemaVersion, err = s.SchemaVersion(); err != nil {
+		return nil, err
+	}
+	if d.Docs, err = s.Documents(); err != nil {
+		return nil, err
+	}
+	for id := range d.Docs {
+		if v, ok := s.index.Vectors[id]; ok {
+			d.Embeddings[id] = v
+		}
+	}
+	projects, err := s.BriefProjects()
+	if err != nil {
+		return nil, err
+	}
+	for _, project := range projects {
+		d.Briefs[project], _ = s.GetBrief(project)
+		d.StaleBriefs[project] = s.IsBriefStale(project)
+	}
+	if d.Aliases, err = s.ProjectAliases(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// PutDocument rewrites a memory file from a document; keys the frontmatter
+// does not know about are dropped
+func (s *FileStore) PutDocument(id string, doc map[string]interface{}) error {
+	memo, err := documentToMemory(doc)
+	if err != nil {
+		return err
+	}
+	memo.ID = id
+	return s.put(memo)
+}
+
+// PutTrash writes a trash entry to .trash/ID.json
+func (s *FileStore) PutTrash(e TrashEntry) error {
+	if err := ValidateID(e.ID); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Join(s.dir, fileTrashDir), 0o755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(s.dir, fileTrashDir, e.ID+".json"), data)
+}
+
+// TrashEntries reads the trash, most recently deleted first
+func (s *FileStore) TrashEntries() ([]TrashEntry, error) {
+	paths, err := filepath.Glob(filepath.Join(s.dir, fileTrashDir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]TrashEntry, 0, len(paths))
+	for _, p := range paths {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		var e TrashEntry
+		if err := json.Unmarshal(data, &e); err != nil {
+			return nil, fmt.Errorf("corrupt trash entry %s: %w", filepath.Base(p), err)
+		}
+		entries = append(entries, e)
+	}
+	sortTrash(entries)
+	return entries, nil
+}
+
+// DeleteTrash removes a trash entry
+func (s *FileStore) DeleteTrash(id string) error {
+	if err := ValidateID(id); err != nil {
+		return err
+	}
+	err := os.Remove(filepath.Join(s.dir, fileTrashDir, id+".json"))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// Quarantine moves an unreadable memory file to .quarantine/
+func (s *FileStore) Quarantine(id string) (string, error) {
+	path, err := s.memoryPath(id)
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(s.dir, fileQuarantineDir)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	dest := filepath.Join(dir, id+".md")
+	return dest, s.locked(func() error {
+		if err := os.Rename(path, dest); err != nil {
+			return err
+		}
+		s.setTerms(id, nil)
+		s.setVector(id, nil)
+		return s.writeIndex()
+	})
+}
+
+// AppendLog appends an entry to the log file
+func (s *FileStore) AppendLog(e LogEntry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(s.dir, fileLogName), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ReadLog returns matching log file entries, oldest first
+func (s *FileStore) ReadLog(q LogQuery) ([]LogEntry, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, fileLogName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []LogEntry
+	for i, line := range strings.Split(string(data), "\n") {
+		if line == "" {
+			continue
+		}
+		var e LogEntry
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			return nil, fmt.Errorf("corrupt %s line %d: %w", fileLogName, i+1, err)
+		}
+		if q.Matches(e) {
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
+
+// PushUndo appends an operation to the undo file, keeping the newest undoDepth
+func (s *FileStore) PushUndo(op *Operation) error {
+	ops, err := s.undoStack()
+	if err != nil {
+		return err
+	}
+	ops = append(ops, op)
+	if len(ops) > undoDepth {
+		ops = ops[len(ops)-undoDepth:]
+	}
+	return s.saveUndoStack(ops)
+}
+
+// PopUndo removes and returns the newest operation
+func (s *FileStore) PopUndo() (*Operation, error) {
+	ops, err := s.undoStack()
+	if err != nil || len(ops) == 0 {
+		return nil, err
+	}
+	op := ops[len(ops)-1]
+	return op, s.saveUndoStack(ops[:len(ops)-1])
+}
+
+func (s *FileStore) undoStack() ([]*Operation, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, fileUndoName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var ops []*Operation
+	if err := json.Unmarshal(data, &ops); err != nil {
+		return nil, fmt.Errorf("corrupt %s: %w", fileUndoName, err)
+	}
+	return ops, nil
+}
+
+func (s *FileStore) saveUndoStack(ops []*Operation) error {
+	data, err := json.Marshal(ops)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(s.dir, fileUndoName), data)
+}
+
+// memoryFileHead is how every memory file begins: the frontmatter opens with the ID
+const memoryFileHead = "---\nid: "
+
+// marshalMemoryFile renders a memory as YAML frontmatter plus a markdown body
+func marshalMemoryFile(memo *Memory) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString("---\n")
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(memo); err != nil {
+		return nil, err
+	}
+	enc.Close()
+	buf.WriteString("---\n")
+	buf.WriteString(memo.Content)
+	buf.WriteString("\n")
+	return buf.Bytes(), nil
+}
+
+// unmarshalMemoryFile parses a file written by marshalMemoryFile
+func unmarshalMemoryFile(data []byte) (*Memory, error) {
+	text := string(data)
+	if !strings.HasPrefix(text, "---\n") {
+		return nil, fmt.Errorf("missing frontmatter")
+	}
+	end := strings.Index(text[4:], "\n---\n")
+	if end < 0 {
+		return nil, fmt.Errorf("unterminated frontmatter")
+	}
+
+	var memo Memory
+	if err := yaml.Unmarshal([]byte(text[4:4+end+1]), &memo); err != nil {
+		return nil, err
+	}
+	memo.Content = strings.TrimSuffix(text[4+end+5:], "\n")
+	return &memo, nil
+}
+
+// uniqueTerms returns the distinct words of text
+func uniqueTerms(text string) []string {
+	seen := make(map[string]bool)
+	var terms []string
+	for _, t := range tokenize(text) {
+		if !seen[t] {
+			seen[t] = true
+			terms = append(terms, t)
+		}
+	}
+	return terms
+}
+
+// containsAllTerms reports whether docTerms satisfies every query term
+func containsAllTerms(docTerms, query []string) bool {
+	for _, q := range query {
+		prefix := strings.HasSuffix(q, "*")
+		q = strings.TrimSuffix(q, "*")
+		found := false
+		for _, t := range docTerms {
+			if t == q || (prefix && strings.HasPrefix(t, q)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// writeFileAtomic replaces path via a temp file so readers never see a partial write
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,211 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMemoryFileRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		memo Memory
+	}{
+		{"plain", Memory{ID: "a1b2c3d4", Type: "fact", Content: "Use pnpm", Tags: []string{"project:x", "tooling"},
+			Created: "2026-01-02T03:04:05Z", Accessed: "2026-01-02T03:04:05Z", AccessCount: 3}},
+		{"multi-line body with a rule", Memory{ID: "b", Type: "context", Content: "First line\n\n---\n\nAfter a rule",
+			Tags: []string{}, Created: "2026-01-02T03:04:05Z", Accessed: "2026-01-02T03:04:05Z"}},
+		{"trailing newline kept", Memory{ID: "c", Type: "fact", Content: "ends with a newline\n", Tags: []string{}}},
+		{"yaml-looking content", Memory{ID: "d", Type: "fact", Content: "key: value\n- item", Tags: []string{}}},
+		{"anchors, revisions and git state", Memory{ID: "e", Type: "learned", Content: "Retry on 429", Tags: []string{"x"},
+			Project: "github.com/acme/api", Commit: "abc123", Verified: "2026-02-01T00:00:00Z",
+			Anchors: []Anchor{{Path: "api/client.go", Symbol: "Retry", Blob: "f00"}},
+			Revisions: []Revision{
+				{Rev: 1, Content: "Retry", Tags: []string{"x"}, Time: "2026-01-01T00:00:00Z", Reason: "created"},
+				{Rev: 2, Content: "Retry on 429", Tags: []string{"x"}, Time: "2026-01-02T00:00:00Z", Actor: "ana", Reason: "update"},
+			}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := marshalMemoryFile(&tt.memo)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := unmarshalMemoryFile(data)
+			if err != nil {
+				t.Fatalf("unmarshal: %v\n%s", err, data)
+			}
+			if !reflect.DeepEqual(*got, tt.memo) {
+				t.Errorf("round trip changed the memory\n got: %+v\nwant: %+v\nfile:\n%s", *got, tt.memo, data)
+			}
+		})
+	}
+}
+
+func TestUnmarshalMemoryFileRejects(t *testing.T) {
+	for name, data := range map[string]string{
+		"no frontmatter":   "just some notes\n",
+		"unterminated":     "---\nid: x\ntype: fact\n",
+		"broken yaml":      "---\nid: [x\n---\nbody\n",
+		"tags not a list":  "---\nid: x\ntags: {a: b}\n---\nbody\n",
+		"empty file":       "",
+		"only a separator": "---\n",
+	} {
+		if _, err := unmarshalMemoryFile([]byte(data)); err == nil {
+			t.Errorf("%s: parsed without error", name)
+		}
+	}
+}
+
+func TestValidateID(t *testing.T) {
+	for _, id := range []string{"a1b2c3d4", "imported-note", "x.y"} {
+		if err := ValidateID(id); err != nil {
+			t.Errorf("ValidateID(%q) = %v", id, err)
+		}
+	}
+	for _, id := range []string{"", "../evil", "a/b", `a\b`, "..", "a\x00b", "meta", "log"} {
+		if err := ValidateID(id); err == nil {
+			t.Errorf("ValidateID(%q) accepted it", id)
+		}
+	}
+}
+
+func TestFileStoreIgnoresOtherMarkdown(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	memo := &Memory{Type: "fact", Content: "The queue is FIFO"}
+	if err := s.Remember(memo); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte("# Our memories\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	ids, err := s.GetAllMemoryIDs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ids, []string{memo.ID}) {
+		t.Errorf("GetAllMemoryIDs() = %v, want only %s", ids, memo.ID)
+	}
+	if _, err := s.Peek("../" + filepath.Base(dir) + "/README"); err == nil {
+		t.Error("Peek followed a path outside the store")
+	}
+}
+
+func TestFileStoreMergesConcurrentIndexSaves(t *testing.T) {
+	dir := t.TempDir()
+	a, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each store loaded the index before the other saved
+	first := &Memory{Type: "fact", Content: "alpha"}
+	second := &Memory{Type: "fact", Content: "beta"}
+	if err := a.Remember(first); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Remember(second); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.EmbedMemory(first.ID, []float64{1, 0}); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{first.ID, second.ID} {
+		if _, ok := c.index.Terms[id]; !ok {
+			t.Errorf("terms of %s lost", id)
+		}
+	}
+	if _, err := c.GetEmbeddingByID(first.ID); err != nil {
+		t.Errorf("vector of %s lost: %v", first.ID, err)
+	}
+}
+
+func TestFileStoreSnapshotsWhatOtherProcessesSaved(t *testing.T) {
+	useDefaultSettings(t)
+	dir := t.TempDir()
+	a, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// b stands for another memo process, saving after a loaded the index
+	m := &Memory{Type: "fact", Content: "Builds take five minutes", Tags: []string{}}
+	if err := b.Remember(m); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.EmbedMemory(m.ID, []float64{1, 0}); err != nil {
+		t.Fatal(err)
+	}
+	d, err := a.Dump()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := d.Docs[m.ID]; !ok || !reflect.DeepEqual(d.Embeddings[m.ID], []float64{1, 0}) {
+		t.Fatalf("dump has %v with vector %v, want both from the other process", d.Docs[m.ID], d.Embeddings[m.ID])
+	}
+
+	if _, err := CreateSnapshot(a, "before"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Update(m.ID, "Builds take two minutes"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.EmbedMemory(m.ID, []float64{0, 1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RestoreSnapshot(a, "before", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored, _ := c.Peek(m.ID)
+	vec, _ := c.GetEmbeddingByID(m.ID)
+	if restored.Content != "Builds take five minutes" || !reflect.DeepEqual(vec, []float64{1, 0}) {
+		t.Errorf("restored %q with vector %v, want the snapshot's content and vector", restored.Content, vec)
+	}
+}
+
+func TestFileStoreInitUpdatesAnOldGitignore(t *testing.T) {
+	dir := t.TempDir()
+	ignore := filepath.Join(dir, ".gitignore")
+	// As written before the lock, log and quarantine existed, plus a line of the user's
+	if err := os.WriteFile(ignore, []byte(".memo-index.json\n.memo-undo.json\n.trash/\n*.swp"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := s.Init(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, _ := os.ReadFile(ignore)
+	want := ".memo-index.json\n.memo-undo.json\n.trash/\n*.swp\n.memo-index.json.lock\n.memo-log.jsonl\n.quarantine/\n"
+	if string(data) != want {
+		t.Errorf(".gitignore is\n%s\nwant\n%s", data, want)
+	}
+}
@@ -0,0 +1,106 @@
+package internal
+
+import (
+	"sort"
+	"strconv"
+)
+
+// rrfK damps how much the very top ranks dominate a fused score; 60 is the
+// constant from the original reciprocal rank fusion paper
+const rrfK = 60
+
+// SearchOptions configures a hybrid search
+type SearchOptions struct {
+	Limit  int
+	Filter Filter
+	// Embedding is the query's embedding; without one only full-text runs
+	Embedding []float64
+	// Expansions are embeddings of rewrites of the query (see ExpandQuery),
+	// whose neighbors join the vector ranking
+	Expansions [][]float64
+	// TextWeight and VectorWeight scale each ranking's share of the fused score
+	TextWeight, VectorWeight float64
+}
+
+// DefaultSearchOptions returns the configured weights and result count
+func DefaultSearchOptions() SearchOptions {
+	return SearchOptions{
+		Limit:        settings.Limits.Search,
+		TextWeight:   settings.Search.TextWeight,
+		VectorWeight: settings.Search.VectorWeight,
+	}
+}
+
+// SearchResult is a memory found by hybrid search
+type SearchResult struct {
+	Memory Memory
+	// Score is the fused reciprocal rank score
+	Score float64
+	// TextRank and VectorRank are 1-based positions in each ranking (0 if absent)
+	TextRank, VectorRank int
+	// Similarity is the cosine score against the query embedding (0 without one)
+	Similarity float64
+}
+
+// HybridSearch runs full-text and vector search and fuses the two rankings
+// with reciprocal rank fusion: each memory scores weight/(rrfK+rank) per
+// ranking it appears in. Exact identifiers surface through the text side,
+// paraphrases through the vector side.
+func HybridSearch(s Store, query string, opts SearchOptions) ([]SearchResult, error) {
+	// Each side looks deeper than the limit so fusion has something to reorder
+	fetch := max(opts.Limit*3, 20)
+
+	byID := make(map[string]*SearchResult)
+	var results []*SearchResult
+	add := func(m Memory) *SearchResult {
+		r, ok := byID[m.ID]
+		if !ok {
+			r = &SearchResult{Memory: m}
+			byID[m.ID] = r
+			results = append(results, r)
+		}
+		return r
+	}
+
+	texts, err := s.KeywordSearch(query, fetch, opts.Filter)
+	if err != nil {
+		return nil, err
+	}
+	for i, m := range texts {
+		r := add(m)
+		r.TextRank = i + 1
+		r.Score += opts.TextWeight / float64(rrfK+i+1)
+	}
+
+	if opts.Embedding != nil {
+		similar, err := SimilarMerged(s, append([][]float64{opts.Embedding}, opts.Expansions...), fetch, opts.Filter)
+		if err != nil {
+			return nil, err
+		}
+		for i, sr := range similar {
+			r := add(sr.Memory)
+			r.VectorRank = i + 1
+			r.Similarity, _ = strconv.ParseFloat(sr.Score, 64)
+			r.Score += opts.VectorWeight / float64(rrfK+i+1)
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	if opts.Limit > 0 && len(results) > opts.Limit {
+		results = results[:opts.Limit]
+	}
+
+	fused := make([]SearchResult, len(results))
+	for i, r := range results {
+		// Text-only hits get a similarity too, so callers can threshold every result
+		if r.VectorRank == 0 && opts.Embedding != nil {
+			if emb, err := s.GetEmbeddingByID(r.Memory.ID); err == nil {
+				r.Similarity = cosineScore(opts.Embedding, emb)
+			}
+		}
+		fused[i] = *r
+	}
+	return fused, nil
+}
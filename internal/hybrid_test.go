@@ -0,0 +1,74 @@
+package internal
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHybridSearchFusesRankings(t *testing.T) {
+	s, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The query "quota" embeds as (1, 0)
+	memos := []struct {
+		content string
+		vector  []float64
+	}{
+		{"quota exceeded", []float64{-1, 0}},          // text #1, vector #4
+		{"tenant limit reached", []float64{1, 0}},     // vector #1 only
+		{"quota for the tenant", []float64{0.6, 0.4}}, // text #2, vector #2
+		{"unrelated words", []float64{0.3, 0.7}},      // vector #3 only
+	}
+	ids := make(map[string]string)
+	for _, m := range memos {
+		memo := &Memory{Type: "fact", Content: m.content}
+		if err := s.Remember(memo); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.EmbedMemory(memo.ID, m.vector); err != nil {
+			t.Fatal(err)
+		}
+		ids[memo.ID] = m.content
+	}
+	contents := func(results []SearchResult) []string {
+		var out []string
+		for _, r := range results {
+			out = append(out, ids[r.Memory.ID])
+		}
+		return out
+	}
+
+	opts := SearchOptions{Limit: 10, TextWeight: 1, VectorWeight: 1, Embedding: []float64{1, 0}}
+	results, err := HybridSearch(s, "quota", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Found by both rankings beats first in one
+	want := []string{"quota for the tenant", "quota exceeded", "tenant limit reached", "unrelated words"}
+	if got := contents(results); !reflect.DeepEqual(got, want) {
+		t.Errorf("fused order %v, want %v", got, want)
+	}
+	if r := results[0]; r.TextRank != 2 || r.VectorRank != 2 || r.Score != 2.0/(rrfK+2) {
+		t.Errorf("top result %+v", r)
+	}
+
+	// Weights shift the balance: without the text ranking, vector order stands
+	opts.TextWeight = 0
+	if results, err = HybridSearch(s, "quota", opts); err != nil {
+		t.Fatal(err)
+	}
+	want = []string{"tenant limit reached", "quota for the tenant", "unrelated words", "quota exceeded"}
+	if got := contents(results); !reflect.DeepEqual(got, want) {
+		t.Errorf("vector-weighted order %v, want %v", got, want)
+	}
+
+	// Without an embedding only full-text runs
+	opts = SearchOptions{Limit: 1, TextWeight: 1, VectorWeight: 1}
+	if results, err = HybridSearch(s, "quota", opts); err != nil {
+		t.Fatal(err)
+	}
+	if got := contents(results); !reflect.DeepEqual(got, []string{"quota exceeded"}) || results[0].Similarity != 0 {
+		t.Errorf("text-only results %+v", results)
+	}
+}
@@ -0,0 +1,339 @@
+package internal
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// MemoryTypes lists the types a memory can have
+var MemoryTypes = []string{"fact", "learned", "preference", "context"}
+
+// Candidate is a piece of an existing note that memo ingest proposes to remember
+type Candidate struct {
+	Type    string
+	Content string
+	Tags    []string
+	// Source is path:line of where the candidate starts
+	Source string
+	// Section is the heading the candidate appeared under, a hint for classifying it
+	Section string
+}
+
+// ingestBatchSize is how many candidates are classified per LLM call
+const ingestBatchSize = 50
+
+// minCandidateWords drops fragments too short to stand alone as a memory
+const minCandidateWords = 3
+
+var (
+	listItem   = regexp.MustCompile(`^\s*(?:[-*+]|\d+[.)])\s+(?:\[[ xX]\]\s+)?`)
+	headingRe  = regexp.MustCompile(`^#{1,6}\s+`)
+	ingestExts = map[string]bool{".md": true, ".markdown": true, ".mdc": true, ".txt": true}
+)
+
+// ReadCandidates splits a file, or every note below a directory, into
+// candidate memories. Files with YAML frontmatter (memo's files backend,
+// agent memory directories) become one candidate each; other notes are
+// split into list items and paragraphs. Fenced code, headings and the block
+// memo sync-instructions manages are skipped. Walking a directory skips
+// hidden entries and MEMORY.md indexes.
+func ReadCandidates(root string) ([]Candidate, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return readNote(root)
+	}
+
+	var cands []Candidate
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name := d.Name()
+		if path != root && strings.HasPrefix(name, ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || !ingestExts[strings.ToLower(filepath.Ext(name))] || name == "MEMORY.md" {
+			return nil
+		}
+		found, err := readNote(path)
+		if err != nil {
+			return err
+		}
+		cands = append(cands, found...)
+		return nil
+	})
+	return cands, err
+}
+
+// readNote splits one file into candidates
+func readNote(path string) ([]Candidate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	source := sourcePath(path)
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+
+	if meta, body, ok := splitFrontmatter(text); ok {
+		if c, ok := frontmatterCandidate(meta, body); ok {
+			c.Source = source + ":1"
+			return []Candidate{c}, nil
+		}
+		// Frontmatter that doesn't describe a memory (e.g. a Cursor rule):
+		// split the body, keeping line numbers
+		offset := strings.Count(text[:len(text)-len(body)], "\n")
+		return splitNote(body, source, offset), nil
+	}
+	return splitNote(text, source, 0), nil
+}
+
+// sourcePath records files inside the repo relative to its root and
+// anything else as an absolute path
+func sourcePath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return RepoPath(path)
+}
+
+func splitFrontmatter(text string) (map[string]interface{}, string, bool) {
+	if !strings.HasPrefix(text, "---\n") {
+		return nil, "", false
+	}
+	end := strings.Index(text[4:], "\n---\n")
+	if end < 0 {
+		return nil, "", false
+	}
+	var meta map[string]interface{}
+	if err := yaml.Unmarshal([]byte(text[4:4+end+1]), &meta); err != nil {
+		return nil, "", false
+	}
+	return meta, text[4+end+5:], true
+}
+
+// frontmatterCandidate reads a one-memory-per-file note. The type comes
+// from a memo type in "type" (or "metadata.type"); agent memory types are
+// mapped onto memo's.
+func frontmatterCandidate(meta map[string]interface{}, body string) (Candidate, bool) {
+	var c Candidate
+	kind, _ := meta["type"].(string)
+	if nested, ok := meta["metadata"].(map[string]interface{}); ok && kind == "" {
+		kind, _ = nested["type"].(string)
+	}
+	if kind == "" {
+		return c, false
+	}
+	switch kind {
+	case "user", "feedback":
+		c.Type = "preference"
+	case "project":
+		c.Type = "context"
+	case "reference":
+		c.Type = "fact"
+	default:
+		if isMemoryType(kind) {
+			c.Type = kind
+		}
+	}
+
+	c.Content = strings.TrimSpace(body)
+	if c.Content == "" {
+		c.Content, _ = meta["description"].(string)
+	}
+	if c.Content == "" {
+		return c, false
+	}
+	if tags, ok := meta["tags"].([]interface{}); ok {
+		for _, t := range tags {
+			// Scope tags are re-derived from where the memory is ingested
+			if tag, ok := t.(string); ok && !isScopeTag(tag) {
+				c.Tags = append(c.Tags, tag)
+			}
+		}
+	}
+	return c, true
+}
+
+func isScopeTag(tag string) bool {
+	for _, prefix := range []string{"project:", "dir:", "branch:", "org:", "user:", "scope:"} {
+		if strings.HasPrefix(tag, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func isMemoryType(t string) bool {
+	for _, known := range MemoryTypes {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+// splitNote turns markdown into one candidate per list item or paragraph.
+// offset is the line number of text's first line minus one.
+func splitNote(text, source string, offset int) []Candidate {
+	var cands []Candidate
+	var section string
+	var cur []string
+	start := 0
+	flush := func() {
+		content := strings.Join(cur, " ")
+		cur = nil
+		if len(strings.Fields(content)) < minCandidateWords {
+			return
+		}
+		cands = append(cands, Candidate{
+			Content: content,
+			Source:  source + ":" + strconv.Itoa(start),
+			Section: section,
+		})
+	}
+
+	fenced, managed := false, false
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for n := offset + 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, "```"), strings.HasPrefix(trimmed, "~~~"):
+			flush()
+			fenced = !fenced
+		case fenced:
+		case strings.HasPrefix(trimmed, "<!-- memo:begin"):
+			flush()
+			managed = true
+		case trimmed == instructionsEnd:
+			managed = false
+		case managed:
+		case headingRe.MatchString(trimmed):
+			flush()
+			section = strings.TrimSpace(headingRe.ReplaceAllString(trimmed, ""))
+		case trimmed == "", strings.HasPrefix(trimmed, "<!--"), strings.HasPrefix(trimmed, "|"):
+			flush()
+		case listItem.MatchString(line):
+			flush()
+			start = n
+			cur = append(cur, strings.TrimSpace(listItem.ReplaceAllString(line, "")))
+		default:
+			if len(cur) == 0 {
+				start = n
+			}
+			cur = append(cur, trimmed)
+		}
+	}
+	flush()
+	return cands
+}
+
+// Rule-based classification: the section heading decides first, then
+// wording in the candidate itself
+var (
+	preferenceWords = regexp.MustCompile(`(?i)\b(prefer|prefers|always|never|don't|do not|avoid|instead of|should|must|please|style|convention)\b`)
+	learnedWords    = regexp.MustCompile(`(?i)\b(gotcha|turns out|beware|careful|caveat|workaround|bug|broke|breaks|fails|failed|because|note that|watch out|trick|learned)\b`)
+	contextWords    = regexp.MustCompile(`(?i)\b(we are|we're|goal|purpose|background|roadmap|migrating|currently|team|owner|deadline)\b`)
+
+	preferenceSections = regexp.MustCompile(`(?i)preference|style|convention|rule|guideline|do's|don'ts|workflow`)
+	learnedSections    = regexp.MustCompile(`(?i)gotcha|lesson|learn|pitfall|troubleshoot|caveat|known issue|debug`)
+	contextSections    = regexp.MustCompile(`(?i)overview|background|about|context|architecture|goal|history|status`)
+)
+
+// ClassifyRule picks a memory type for a candidate without the LLM
+func ClassifyRule(c Candidate) string {
+	switch {
+	case preferenceSections.MatchString(c.Section):
+		return "preference"
+	case learnedSections.MatchString(c.Section):
+		return "learned"
+	case contextSections.MatchString(c.Section):
+		return "context"
+	case preferenceWords.MatchString(c.Content):
+		return "preference"
+	case learnedWords.MatchString(c.Content):
+		return "learned"
+	case contextWords.MatchString(c.Content):
+		return "context"
+	}
+	return "fact"
+}
+
+// Classify fills in the type of every candidate that has none, through the
+// LLM when useLLM is set (falling back to the rules for any it doesn't
+// answer) and by rule otherwise
+func Classify(cands []Candidate, useLLM bool) error {
+	var err error
+	if useLLM {
+		for b := 0; b < len(cands) && err == nil; b += ingestBatchSize {
+			batch := cands[b:min(b+ingestBatchSize, len(cands))]
+			err = classifyLLM(batch)
+		}
+	}
+	for i := range cands {
+		if cands[i].Type == "" {
+			cands[i].Type = ClassifyRule(cands[i])
+		}
+	}
+	return err
+}
+
+var classifyLine = regexp.MustCompile(`(?m)^\s*(\d+)\s*[:.)-]\s*\**(fact|learned|preference|context)\b`)
+
+func classifyLLM(batch []Candidate) error {
+	var list strings.Builder
+	pending := 0
+	for i, c := range batch {
+		if c.Type != "" {
+			continue
+		}
+		pending++
+		fmt.Fprintf(&list, "%d. ", i+1)
+		if c.Section != "" {
+			fmt.Fprintf(&list, "[%s] ", c.Section)
+		}
+		list.WriteString(c.Content + "\n")
+	}
+	if pending == 0 {
+		return nil
+	}
+
+	prompt := fmt.Sprintf(`You are sorting notes into a memory system. Classify each numbered note as exactly one of:
+- fact: objective information (config locations, versions, commands, how things are set up)
+- learned: discoveries, gotchas, how things actually behave, lessons from debugging
+- preference: how the user wants work done (style, workflow, tools, do's and don'ts)
+- context: project background, goals, current state, who is involved
+
+A section heading in brackets, if any, is where the note came from.
+
+Notes:
+%s
+Answer with one line per note in this exact format and nothing else:
+N: type`, list.String())
+
+	result, err := CallLLM(prompt)
+	if err != nil {
+		return err
+	}
+	for _, m := range classifyLine.FindAllStringSubmatch(result, -1) {
+		if n, err := strconv.Atoi(m[1]); err == nil && n >= 1 && n <= len(batch) && batch[n-1].Type == "" {
+			batch[n-1].Type = m[2]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// PinnedTag marks memories that belong in agent instruction files
+const PinnedTag = "pinned"
+
+// Markers around the block memo sync-instructions manages
+const (
+	instructionsBegin = "<!-- memo:begin - managed by memo sync-instructions, edits inside are overwritten -->"
+	instructionsEnd   = "<!-- memo:end -->"
+)
+
+// InstructionTargets maps the --target names to files relative to the repo root
+var InstructionTargets = map[string]string{
+	"claude": "CLAUDE.md",
+	"agents": "AGENTS.md",
+	"cursor": ".cursor/rules/memo.mdc",
+}
+
+// cursorRuleHeader is the frontmatter for a new Cursor rule file
+const cursorRuleHeader = `---
+description: Project memory synced from memo
+alwaysApply: true
+---
+`
+
+// UnpinMemory removes the pinned tag from a memory
+func UnpinMemory(s Store, id string) error {
+	_, err := Revise(s, id, "unpin", "unpin", func(m *Memory) error {
+		var tags []string
+		for _, tag := range m.Tags {
+			if tag != PinnedTag {
+				tags = append(tags, tag)
+			}
+		}
+		if len(tags) == len(m.Tags) {
+			return fmt.Errorf("memory %s is not pinned", id)
+		}
+		m.Tags = tags
+		return nil
+	})
+	return err
+}
+
+// RenderInstructions renders a project's brief, pinned memories and
+// preferences as markdown. Branch memories are left out since instruction
+// files are shared by every branch.
+func RenderInstructions(s Store, p *Project) (string, error) {
+	pinned, err := instructionMemories(s, Filter{Projects: p.Names(), Tag: PinnedTag})
+	if err != nil {
+		return "", err
+	}
+	prefs, err := instructionMemories(s, Filter{Projects: p.Names(), Type: "preference"})
+	if err != nil {
+		return "", err
+	}
+	brief, _ := s.GetBrief(p.Key)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "## Project memory: %s\n", p.Name)
+	if brief = strings.TrimSpace(brief); brief != "" {
+		fmt.Fprintf(&b, "\n%s\n", brief)
+	}
+	writeSection := func(title string, memos []Memory, skip map[string]bool) {
+		var lines []string
+		for _, m := range memos {
+			if skip[m.ID] {
+				continue
+			}
+			content := strings.Join(strings.Fields(m.Content), " ")
+			for _, tag := range m.Tags {
+				if dir, ok := strings.CutPrefix(tag, "dir:"); ok {
+					content = fmt.Sprintf("(in %s/) %s", dir, content)
+				}
+			}
+			lines = append(lines, "- "+content)
+		}
+		if len(lines) > 0 {
+			fmt.Fprintf(&b, "\n### %s\n\n%s\n", title, strings.Join(lines, "\n"))
+		}
+	}
+	writeSection("Pinned", pinned, nil)
+	seen := make(map[string]bool)
+	for _, m := range pinned {
+		seen[m.ID] = true
+	}
+	writeSection("Preferences", prefs, seen)
+	return b.String(), nil
+}
+
+// instructionMemories collects memories in a stable order, so unchanged
+// memories render byte-for-byte the same
+func instructionMemories(s Store, f Filter) ([]Memory, error) {
+	memos, err := Collect(s.Iterate(f), 0)
+	if err != nil {
+		return nil, err
+	}
+	var kept []Memory
+	for _, m := range memos {
+		if BranchOf(m) == "" {
+			kept = append(kept, m)
+		}
+	}
+	sort.Slice(kept, func(i, j int) bool {
+		if kept[i].Created != kept[j].Created {
+			return kept[i].Created < kept[j].Created
+		}
+		return kept[i].ID < kept[j].ID
+	})
+	return kept, nil
+}
+
+// markerEscaper keeps memory text from closing or reopening the managed
+// block. Markdown renders the entity as the "<" it replaced.
+var markerEscaper = strings.NewReplacer("<!-- memo:", "&lt;!-- memo:")
+
+// ReplaceInstructionsBlock puts body between the memo markers in text,
+// appending the block if there is none. Text outside it is kept as is.
+func ReplaceInstructionsBlock(text, body string) (string, error) {
+	body = markerEscaper.Replace(strings.TrimRight(body, "\n"))
+	block := instructionsBegin + "\n" + body + "\n" + instructionsEnd
+
+	start := strings.Index(text, instructionsBegin)
+	end := strings.Index(text, instructionsEnd)
+	switch {
+	case start < 0 && end < 0:
+		if text != "" && !strings.HasSuffix(text, "\n") {
+			text += "\n"
+		}
+		if text != "" {
+			text += "\n"
+		}
+		return text + block + "\n", nil
+	case start < 0 || end < start:
+		return "", fmt.Errorf("unbalanced memo markers")
+	}
+	return text[:start] + block + text[end+len(instructionsEnd):], nil
+}
+
+// SyncInstructions brings the managed block in path up to date. It returns
+// whether the file changed (or, with dryRun, would change).
+func SyncInstructions(path, body string, dryRun bool) (bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+	old := string(data)
+	if os.IsNotExist(err) && strings.HasSuffix(path, ".mdc") {
+		old = cursorRuleHeader
+	}
+
+	updated, err := ReplaceInstructionsBlock(old, body)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", path, err)
+	}
+	if updated == string(data) {
+		return false, nil
+	}
+	if dryRun {
+		return true, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return false, err
+	}
+	return true, os.WriteFile(path, []byte(updated), 0o644)
+}
+
+// RepoRoot returns the top of the git work tree, or the working directory
+// outside git
+func RepoRoot() string {
+	if root, err := gitOutput("rev-parse", "--show-toplevel"); err == nil {
+		return root
+	}
+	cwd, _ := os.Getwd()
+	return cwd
+}
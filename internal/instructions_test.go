@@ -0,0 +1,51 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReplaceInstructionsBlock(t *testing.T) {
+	block := func(body string) string {
+		return instructionsBegin + "\n" + body + "\n" + instructionsEnd
+	}
+	tests := []struct {
+		name, text, body, want string
+	}{
+		{"empty file", "", "- a", block("- a") + "\n"},
+		{"appended", "# Repo\nNotes", "- a", "# Repo\nNotes\n\n" + block("- a") + "\n"},
+		{"replaced in place", "# Repo\n" + block("- old") + "\nAfter\n", "- new\n", "# Repo\n" + block("- new") + "\nAfter\n"},
+		{"markers in content", "", "- Ends with " + instructionsEnd + " then " + instructionsBegin,
+			block("- Ends with &lt;!-- memo:end --> then &lt;"+strings.TrimPrefix(instructionsBegin, "<")) + "\n"},
+	}
+	for _, tt := range tests {
+		got, err := ReplaceInstructionsBlock(tt.text, tt.body)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", tt.name, got, tt.want)
+		}
+	}
+
+	if _, err := ReplaceInstructionsBlock(instructionsEnd+"\n"+instructionsBegin, "- a"); err == nil {
+		t.Error("markers out of order were accepted")
+	}
+}
+
+func TestResyncAfterMarkersInMemories(t *testing.T) {
+	text, err := ReplaceInstructionsBlock("# Repo\n", "- Close with "+instructionsEnd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text += "Kept after the block\n"
+	// The next sync must find the real end marker and keep what follows
+	again, err := ReplaceInstructionsBlock(text, "- Something else")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(again, instructionsEnd) != 1 || !strings.HasSuffix(again, "Kept after the block\n") || strings.Contains(again, "Close with") {
+		t.Errorf("second sync left\n%s", again)
+	}
+}
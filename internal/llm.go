@@ -5,7 +5,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"os"
 )
 
 type chatRequest struct {
@@ -26,21 +25,22 @@ type chatResponse struct {
 	} `json:"choices"`
 }
 
+// LLMConfigured reports whether CallLLM has the settings it needs
+func LLMConfigured() bool {
+	return settings.LLM.APIKey != "" && settings.LLM.Model != ""
+}
+
 // CallLLM sends a prompt to the LLM and returns the response
 func CallLLM(prompt string) (string, error) {
-	llmURL := "https://api.fireworks.ai/inference/v1/chat/completions"
-	llmModel := "accounts/fireworks/models/kimi-k2p5"
-	llmKey := os.Getenv("FIREWORKS_API_KEY")
-
-	if u := os.Getenv("LLM_URL"); u != "" {
-		llmURL = u
-	}
-	if m := os.Getenv("LLM_MODEL"); m != "" {
-		llmModel = m
-	}
+	llmURL := settings.LLM.URL
+	llmModel := settings.LLM.Model
+	llmKey := settings.LLM.APIKey
 
 	if llmKey == "" {
-		return "", fmt.Errorf("FIREWORKS_API_KEY not set")
+		return "", fmt.Errorf("LLM API key not set (llm.api_key or $%s)", settings.LLM.APIKeyEnv)
+	}
+	if llmModel == "" {
+		return "", fmt.Errorf("LLM model not set (llm.model in config)")
 	}
 
 	reqBody := chatRequest{
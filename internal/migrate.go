@@ -0,0 +1,210 @@
+package internal
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Migration upgrades one stored memory document to the next schema version.
+// Apply edits doc in place and must be idempotent, since documents written
+// by a newer binary may already carry the fields it adds.
+type Migration struct {
+	Version     int
+	Description string
+	Apply       func(doc map[string]interface{}) error
+}
+
+// migrations are applied in order; a store at version N runs migrations[N:]
+var migrations = []Migration{
+	{1, "add project field derived from the project: tag", migrateProjectField},
+}
+
+// SchemaVersion is the document schema this binary writes
+var SchemaVersion = len(migrations)
+
+// MigrationReport describes what memo migrate did (or would do)
+type MigrationReport struct {
+	From    int
+	To      int
+	Pending []Migration
+	Total   int
+	// Changes maps each changed document ID to its added/removed/changed keys
+	Changes map[string][]string
+	Backup  string
+}
+
+// Migrate upgrades every document from the store's schema version to
+// SchemaVersion and rebuilds the search index. Unless dryRun is set, the
+// original documents are written to backupPath first (skipped when empty).
+func Migrate(s Store, dryRun bool, backupPath string) (*MigrationReport, error) {
+	from, err := s.SchemaVersion()
+	if err != nil {
+		return nil, fmt.Errorf("read schema version: %w", err)
+	}
+	report := &MigrationReport{From: from, To: SchemaVersion, Changes: make(map[string][]string)}
+	if from > SchemaVersion {
+		return nil, fmt.Errorf("store is at schema version %d, newer than this binary (%d)", from, SchemaVersion)
+	}
+	if from == SchemaVersion {
+		return report, nil
+	}
+	report.Pending = migrations[from:]
+
+	docs, err := s.Documents()
+	if err != nil {
+		return nil, err
+	}
+	report.Total = len(docs)
+
+	migrated := make(map[string]map[string]interface{})
+	for id, doc := range docs {
+		updated := copyDocument(doc)
+		for _, m := range report.Pending {
+			if err := m.Apply(updated); err != nil {
+				return nil, fmt.Errorf("migration v%d on %s: %w", m.Version, id, err)
+			}
+		}
+		if changes := diffDocuments(doc, updated); len(changes) > 0 {
+			report.Changes[id] = changes
+			migrated[id] = updated
+		}
+	}
+
+	if dryRun {
+		return report, nil
+	}
+
+	if backupPath != "" {
+		if err := writeBackup(backupPath, from, docs); err != nil {
+			return nil, fmt.Errorf("backup: %w", err)
+		}
+		report.Backup = backupPath
+	}
+
+	for id, doc := range migrated {
+		if err := s.PutDocument(id, doc); err != nil {
+			return report, fmt.Errorf("write %s: %w", id, err)
+		}
+	}
+	if err := s.Init(); err != nil {
+		return report, fmt.Errorf("rebuild index: %w", err)
+	}
+	if err := s.SetSchemaVersion(SchemaVersion); err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+// DefaultBackupPath returns a timestamped backup file under ~/.local/share/memo/backups
+func DefaultBackupPath(name string) string {
+	stamp := time.Now().UTC().Format("20060102T150405Z")
+	return dataPath(filepath.Join("backups", fmt.Sprintf("%s-%s.jsonl", name, stamp)))
+}
+
+// writeBackup dumps documents as JSONL, preceded by a header line
+// recording the schema version they were written with
+func writeBackup(path string, version int, docs map[string]map[string]interface{}) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	enc.Encode(map[string]interface{}{"schema_version": version, "created": Now()})
+
+	ids := make([]string, 0, len(docs))
+	for id := range docs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		if err := enc.Encode(docs[id]); err != nil {
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// copyDocument deep-copies a decoded JSON document
+func copyDocument(doc map[string]interface{}) map[string]interface{} {
+	data, _ := json.Marshal(doc)
+	var out map[string]interface{}
+	json.Unmarshal(data, &out)
+	return out
+}
+
+// diffDocuments lists top-level keys that differ, as "+key", "-key" or "~key"
+func diffDocuments(before, after map[string]interface{}) []string {
+	var changes []string
+	for k, v := range after {
+		old, ok := before[k]
+		if !ok {
+			changes = append(changes, "+"+k)
+		} else if !reflect.DeepEqual(old, v) {
+			changes = append(changes, "~"+k)
+		}
+	}
+	for k := range before {
+		if _, ok := after[k]; !ok {
+			changes = append(changes, "-"+k)
+		}
+	}
+	sort.Strings(changes)
+	return changes
+}
+
+// documentToMemory converts a decoded document back into a Memory
+func documentToMemory(doc map[string]interface{}) (*Memory, error) {
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	var memo Memory
+	if err := json.Unmarshal(data, &memo); err != nil {
+		return nil, err
+	}
+	return &memo, nil
+}
+
+// memoryToDocument converts a Memory into a decoded JSON document
+func memoryToDocument(memo *Memory) (map[string]interface{}, error) {
+	data, err := json.Marshal(memo)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// v1: memories used to record their project only as a "project:" tag
+func migrateProjectField(doc map[string]interface{}) error {
+	if p, ok := doc["project"].(string); ok && p != "" {
+		return nil
+	}
+	tags, _ := doc["tags"].([]interface{})
+	for _, t := range tags {
+		if tag, ok := t.(string); ok && strings.HasPrefix(tag, "project:") && len(tag) > 8 {
+			doc["project"] = tag[8:]
+			return nil
+		}
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package internal
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMigrationsAreNumberedInOrder(t *testing.T) {
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Errorf("migrations[%d] is version %d, want %d", i, m.Version, i+1)
+		}
+	}
+	if SchemaVersion != len(migrations) {
+		t.Errorf("SchemaVersion = %d with %d migrations", SchemaVersion, len(migrations))
+	}
+}
+
+func TestMigrateProjectField(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  map[string]interface{}
+		want interface{} // the project afterwards; nil for none
+	}{
+		{"from the project tag", map[string]interface{}{"tags": []interface{}{"api", "project:github.com/acme/api"}}, "github.com/acme/api"},
+		{"first project tag wins", map[string]interface{}{"tags": []interface{}{"project:a", "project:b"}}, "a"},
+		{"existing project kept", map[string]interface{}{"project": "kept", "tags": []interface{}{"project:other"}}, "kept"},
+		{"empty project refilled", map[string]interface{}{"project": "", "tags": []interface{}{"project:x"}}, "x"},
+		{"bare project: tag ignored", map[string]interface{}{"tags": []interface{}{"project:"}}, nil},
+		{"no tags", map[string]interface{}{}, nil},
+		{"tags not a list", map[string]interface{}{"tags": "project:x"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := migrateProjectField(tt.doc); err != nil {
+				t.Fatal(err)
+			}
+			if got := tt.doc["project"]; got != tt.want {
+				t.Errorf("project = %v, want %v", got, tt.want)
+			}
+			// Migrations must be idempotent
+			once := copyDocument(tt.doc)
+			if err := migrateProjectField(tt.doc); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(once, tt.doc) {
+				t.Errorf("second run changed the document: %v -> %v", once, tt.doc)
+			}
+		})
+	}
+}
+
+func TestDiffDocuments(t *testing.T) {
+	before := map[string]interface{}{"a": 1.0, "b": "x", "c": []interface{}{"t"}}
+	after := map[string]interface{}{"a": 1.0, "b": "y", "d": true}
+	want := []string{"+d", "-c", "~b"}
+	if got := diffDocuments(before, after); !reflect.DeepEqual(got, want) {
+		t.Errorf("diffDocuments() = %v, want %v", got, want)
+	}
+	if got := diffDocuments(before, copyDocument(before)); len(got) != 0 {
+		t.Errorf("a copy differs: %v", got)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewFileStore(filepath.Join(dir, "memories"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := map[string]interface{}{
+		"id": "old", "type": "fact", "content": "Written before projects had a field",
+		"tags": []interface{}{"project:github.com/acme/api"}, "created": "2025-01-01T00:00:00Z", "accessed": "2025-01-01T00:00:00Z",
+	}
+	if err := s.PutDocument("old", old); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := Migrate(s, true, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.From != 0 || report.To != SchemaVersion || !reflect.DeepEqual(report.Changes["old"], []string{"+project"}) {
+		t.Errorf("dry run report = %+v", report)
+	}
+	if m, _ := s.Peek("old"); m.Project != "" {
+		t.Error("dry run changed the store")
+	}
+
+	backup := filepath.Join(dir, "backup.jsonl")
+	if _, err := Migrate(s, false, backup); err != nil {
+		t.Fatal(err)
+	}
+	if m, _ := s.Peek("old"); m.Project != "github.com/acme/api" {
+		t.Errorf("project = %q after migrating", m.Project)
+	}
+	if v, _ := s.SchemaVersion(); v != SchemaVersion {
+		t.Errorf("schema version = %d, want %d", v, SchemaVersion)
+	}
+	f, err := os.Open(backup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	lines := 0
+	for sc := bufio.NewScanner(f); sc.Scan(); lines++ {
+	}
+	if lines != 2 {
+		t.Errorf("backup has %d lines, want a header and one document", lines)
+	}
+
+	// A store written by a newer binary is left alone
+	if err := s.SetSchemaVersion(SchemaVersion + 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Migrate(s, false, ""); err == nil {
+		t.Error("migrated a store from a newer schema")
+	}
+}
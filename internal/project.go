@@ -1,26 +1,330 @@
 package internal
 
 import (
+	"fmt"
+	"net/url"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
 )
 
-// GetProject returns the current project name from git or directory
+// Where a project key came from, most stable first
+const (
+	ProjectSourceRemote = "origin remote"
+	ProjectSourceRoot   = "root commit"
+	ProjectSourcePath   = "path"
+)
+
+// Project identifies the project memories are filed under. Key is what
+// project: tags store; it survives renaming or re-cloning the repo and is
+// shared by its worktrees. Name is only for display.
+type Project struct {
+	Key    string
+	Name   string
+	Source string
+	// Aliases are other names (typically pre-identity directory names)
+	// whose memories belong to this project
+	Aliases []string
+}
+
+// Names returns the key followed by every alias, for filtering
+func (p *Project) Names() []string {
+	return append([]string{p.Key}, p.Aliases...)
+}
+
+// GetProject returns the key of the project in the current directory
 func GetProject() string {
-	// Try git first
-	cmd := exec.Command("git", "rev-parse", "--show-toplevel")
-	out, err := cmd.Output()
-	if err == nil {
-		toplevel := strings.TrimSpace(string(out))
-		return filepath.Base(toplevel)
+	return DetectProject().Key
+}
+
+// DetectProject identifies the project in the current directory by its
+// normalized origin URL, else its root commit, else its path
+func DetectProject() *Project {
+	toplevel, err := gitOutput("rev-parse", "--show-toplevel")
+	if err != nil {
+		// Not a git repo: the directory is all we have
+		cwd, err := os.Getwd()
+		if err != nil {
+			return &Project{Key: "unknown", Name: "unknown", Source: ProjectSourcePath}
+		}
+		return &Project{Key: "path:" + cwd, Name: filepath.Base(cwd), Source: ProjectSourcePath}
+	}
+
+	p := &Project{Name: filepath.Base(toplevel)}
+	if remote, err := gitOutput("remote", "get-url", "origin"); err == nil && remote != "" {
+		p.Key, p.Source = NormalizeRemote(remote, toplevel), ProjectSourceRemote
+	} else if roots, err := gitOutput("rev-list", "--max-parents=0", "HEAD"); err == nil && roots != "" {
+		// A repo with merged histories has several roots; take the lowest for stability
+		hashes := strings.Fields(roots)
+		sort.Strings(hashes)
+		p.Key, p.Source = "root:"+hashes[0][:12], ProjectSourceRoot
+	} else {
+		p.Key, p.Source = "path:"+toplevel, ProjectSourcePath
+	}
+	return p
+}
+
+// ResolveProject looks up a project by key or alias, or detects the current
+// one when name is empty, and fills in its aliases from the store
+func ResolveProject(s Store, name string) (*Project, error) {
+	aliases, err := s.ProjectAliases()
+	if err != nil {
+		return nil, err
+	}
+
+	var p *Project
+	if name == "" {
+		p = DetectProject()
+		// A renamed project leaves its old key behind as an alias
+		if target, ok := aliases[p.Key]; ok {
+			p.Key = target
+		}
+	} else {
+		key := name
+		if target, ok := aliases[name]; ok {
+			key = target
+		}
+		p = &Project{Key: key, Name: ProjectName(key)}
+	}
+
+	for alias, key := range aliases {
+		if key == p.Key && alias != p.Key {
+			p.Aliases = append(p.Aliases, alias)
+		}
+	}
+	sort.Strings(p.Aliases)
+	return p, nil
+}
+
+// MarkBriefsStale marks the brief of each project the memories are filed
+// under as stale, under the key an alias resolves to (the one memo brief reads)
+func MarkBriefsStale(s Store, memos ...*Memory) {
+	aliases, _ := s.ProjectAliases()
+	marked := make(map[string]bool)
+	for _, m := range memos {
+		if m == nil {
+			continue
+		}
+		key := memoryProject(*m)
+		if target, ok := aliases[key]; ok {
+			key = target
+		}
+		if key != "" && !marked[key] {
+			marked[key] = true
+			s.MarkBriefStale(key)
+		}
+	}
+}
+
+// ProjectName returns a readable name for a project key
+func ProjectName(key string) string {
+	switch {
+	case strings.HasPrefix(key, "path:"):
+		return filepath.Base(key[5:])
+	case strings.HasPrefix(key, "root:"):
+		return key
+	}
+	return path.Base(key)
+}
+
+// caseInsensitiveHosts are forges that treat owner and repo names without
+// regard to case, so their paths are lowercased too
+var caseInsensitiveHosts = []string{"github.com", "gitlab.com", "bitbucket.org"}
+
+// NormalizeRemote turns the many spellings of a remote URL into one key:
+// https://github.com/Org/Repo.git, git@github.com:org/repo and
+// ssh://git@github.com/org/repo/ all become github.com/org/repo. Hosts are
+// lowercased, paths only on the forges above. A local remote keeps its case
+// and, when relative, is resolved against dir (the repo's toplevel), so
+// "../api" names the same repo wherever it is cloned from.
+func NormalizeRemote(remote, dir string) string {
+	remote = strings.TrimSpace(remote)
+
+	var host, repoPath string
+	local := false
+	if p, ok := strings.CutPrefix(remote, "file://"); ok {
+		repoPath, local = p, true
+	} else if u, err := url.Parse(remote); err == nil && u.Scheme != "" && u.Host != "" {
+		host, repoPath = u.Hostname(), u.Path
+	} else if at := strings.Index(remote, ":"); at > 0 && !strings.Contains(remote[:at], "/") {
+		// scp-like syntax: [user@]host:path
+		host, repoPath = remote[:at], remote[at+1:]
+		if i := strings.LastIndex(host, "@"); i >= 0 {
+			host = host[i+1:]
+		}
+	} else {
+		repoPath, local = remote, true
+	}
+
+	if local && dir != "" && !filepath.IsAbs(repoPath) {
+		repoPath = filepath.ToSlash(filepath.Join(dir, repoPath))
+	}
+	repoPath = strings.Trim(repoPath, "/")
+	repoPath = strings.TrimSuffix(repoPath, ".git")
+	if host == "" {
+		return repoPath
+	}
+	host = strings.ToLower(host)
+	if slices.Contains(caseInsensitiveHosts, host) {
+		repoPath = strings.ToLower(repoPath)
+	}
+	return host + "/" + repoPath
+}
+
+// RenameProject refiles every memory of from under the key to, moves its
+// brief and repoints its aliases. The old key becomes an alias, so clones
+// that still detect it keep finding their memories. If to already has
+// memories this merges the two projects. Returns the number of memories moved.
+func RenameProject(s Store, from *Project, to string) (int, error) {
+	memos, err := Collect(s.Iterate(Filter{Projects: from.Names()}), 0)
+	if err != nil {
+		return 0, err
+	}
+	if err := retag(s, memos, to); err != nil {
+		return 0, err
+	}
+
+	for _, name := range from.Names() {
+		moveBrief(s, name, to)
+	}
+
+	aliases, err := s.ProjectAliases()
+	if err != nil {
+		return len(memos), err
+	}
+	for alias, key := range aliases {
+		if key != from.Key {
+			continue
+		}
+		if alias == to {
+			err = s.DeleteProjectAlias(alias)
+		} else {
+			err = s.SetProjectAlias(alias, to)
+		}
+		if err != nil {
+			return len(memos), err
+		}
+	}
+	if from.Key != to {
+		if err := s.SetProjectAlias(from.Key, to); err != nil {
+			return len(memos), err
+		}
+	}
+	return len(memos), nil
+}
+
+// DeleteProject moves every memory of p to the trash and deletes its brief
+// and aliases. Unless backupPath is empty the project is first exported
+// there, in the format memo import reads.
+func DeleteProject(s Store, p *Project, backupPath string) (int, error) {
+	memos, err := Collect(s.Iterate(Filter{Projects: p.Names()}), 0)
+	if err != nil {
+		return 0, err
+	}
+
+	if backupPath != "" {
+		if err := exportFile(s, backupPath, p); err != nil {
+			return 0, fmt.Errorf("export: %w", err)
+		}
+	}
+
+	for _, m := range memos {
+		if err := TrashMemory(s, m.ID); err != nil {
+			return 0, fmt.Errorf("delete %s: %w", m.ID, err)
+		}
 	}
+	for _, name := range p.Names() {
+		if err := s.DeleteBrief(name); err != nil {
+			return len(memos), err
+		}
+	}
+	aliases, err := s.ProjectAliases()
+	if err != nil {
+		return len(memos), err
+	}
+	for alias, key := range aliases {
+		if key == p.Key {
+			if err := s.DeleteProjectAlias(alias); err != nil {
+				return len(memos), err
+			}
+		}
+	}
+	return len(memos), nil
+}
+
+// MoveMemories refiles individual memories under the project key to and
+// marks the briefs of both the old and new projects stale
+func MoveMemories(s Store, ids []string, to string) error {
+	var memos []Memory
+	for _, id := range ids {
+		memo, err := s.Peek(id)
+		if err != nil {
+			return err
+		}
+		memos = append(memos, *memo)
+	}
+
+	for _, m := range memos {
+		if m.Project != "" {
+			s.MarkBriefStale(m.Project)
+		}
+		for _, tag := range m.Tags {
+			if strings.HasPrefix(tag, "project:") {
+				s.MarkBriefStale(tag[8:])
+			}
+		}
+	}
+	if err := retag(s, memos, to); err != nil {
+		return err
+	}
+	s.MarkBriefStale(to)
+	return nil
+}
+
+// retag replaces the project of each memory, keeping its other tags
+func retag(s Store, memos []Memory, key string) error {
+	for _, m := range memos {
+		_, err := Revise(s, m.ID, "move", "move to "+key, func(m *Memory) error {
+			tags := []string{"project:" + key}
+			for _, tag := range m.Tags {
+				if !strings.HasPrefix(tag, "project:") {
+					tags = append(tags, tag)
+				}
+			}
+			m.Tags = tags
+			m.Project = key
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("move %s: %w", m.ID, err)
+		}
+	}
+	return nil
+}
+
+// moveBrief hands a project's brief to another project unless that one
+// already has its own, then marks the destination brief stale
+func moveBrief(s Store, from, to string) {
+	if from == to {
+		return
+	}
+	if brief, err := s.GetBrief(from); err == nil && brief != "" {
+		if existing, _ := s.GetBrief(to); existing == "" {
+			s.SetBrief(to, brief)
+		}
+	}
+	s.DeleteBrief(from)
+	s.MarkBriefStale(to)
+}
 
-	// Fallback to current directory name
-	cwd, err := os.Getwd()
+func gitOutput(args ...string) (string, error) {
+	out, err := exec.Command("git", args...).Output()
 	if err != nil {
-		return "unknown"
+		return "", err
 	}
-	return filepath.Base(cwd)
+	return strings.TrimSpace(string(out)), nil
 }
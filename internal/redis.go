@@ -2,12 +2,13 @@ package internal
 
 import (
 	"context"
-	"crypto/rand"
-	"encoding/hex"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
+	"unicode"
 
 	"github.com/redis/go-redis/v9"
 )
@@ -19,133 +20,231 @@ const (
 
 var ctx = context.Background()
 
-// Memory represents a stored memory
-type Memory struct {
-	ID          string   `json:"id"`
-	Type        string   `json:"type"`
-	Content     string   `json:"content"`
-	Tags        []string `json:"tags"`
-	Created     string   `json:"created"`
-	Accessed    string   `json:"accessed"`
-	AccessCount int      `json:"access_count"`
+var _ Store = (*RedisStore)(nil)
+
+// RedisStore is the Store backed by Redis JSON, RediSearch and vector sets
+type RedisStore struct {
+	rdb    *redis.Client
+	prefix string
 }
 
-// Client wraps Redis connection
-type Client struct {
-	rdb *redis.Client
+// NewRedisStore creates a new Redis-backed store
+func NewRedisStore(cfg RedisConfig) (*RedisStore, error) {
+	opts, err := redis.ParseURL(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("redis.url: %w", err)
+	}
+	if cfg.Password != "" {
+		opts.Password = cfg.Password
+	}
+	if cfg.DB != 0 {
+		opts.DB = cfg.DB
+	}
+	if cfg.TLS && opts.TLSConfig == nil {
+		opts.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	return &RedisStore{rdb: redis.NewClient(opts), prefix: cfg.KeyPrefix}, nil
 }
 
-// NewClient creates a new Redis client
-func NewClient() *Client {
-	rdb := redis.NewClient(&redis.Options{
-		Addr: "localhost:6379",
-	})
-	return &Client{rdb: rdb}
+// Key names, namespaced by the configured key prefix
+
+func (c *RedisStore) memoKey(id string) string {
+	return c.prefix + "memo:" + id
 }
 
-// Close closes the Redis connection
-func (c *Client) Close() error {
-	return c.rdb.Close()
+// metaKey holds store-wide metadata as a hash, so the JSON index skips it
+func (c *RedisStore) metaKey() string {
+	return c.memoKey("meta")
+}
+
+func (c *RedisStore) aliasKey() string {
+	return c.memoKey("aliases")
+}
+
+// trashKey is a hash of trash entries by memory ID
+func (c *RedisStore) trashKey() string {
+	return c.memoKey("trash")
+}
+
+// undoKey is a list of operations, newest first
+func (c *RedisStore) undoKey() string {
+	return c.memoKey("undo")
+}
+
+// quarantineKey is a hash of unreadable memory documents by ID
+func (c *RedisStore) quarantineKey() string {
+	return c.memoKey("quarantine")
+}
+
+// logKey is the audit log stream
+func (c *RedisStore) logKey() string {
+	return c.memoKey("log")
 }
 
-// GenID generates a short unique ID
-func GenID() string {
-	b := make([]byte, 4)
-	rand.Read(b)
-	return hex.EncodeToString(b)
+func (c *RedisStore) briefKey(project string) string {
+	return c.prefix + "brief:" + project
 }
 
-// Now returns current ISO timestamp
-func Now() string {
-	return time.Now().UTC().Format("2006-01-02T15:04:05Z")
+func (c *RedisStore) indexName() string {
+	return c.prefix + IndexName
+}
+
+func (c *RedisStore) vectorSet() string {
+	return c.prefix + VectorSet
+}
+
+// Close closes the Redis connection
+func (c *RedisStore) Close() error {
+	return c.rdb.Close()
 }
 
 // Init creates the search index
-func (c *Client) Init() error {
+func (c *RedisStore) Init() error {
 	// Drop existing index (keep documents)
-	c.rdb.Do(ctx, "FT.DROPINDEX", IndexName).Err()
+	c.rdb.Do(ctx, "FT.DROPINDEX", c.indexName()).Err()
 
 	// Create the search index
-	_, err := c.rdb.Do(ctx, "FT.CREATE", IndexName,
+	args := []interface{}{"FT.CREATE", c.indexName(),
 		"ON", "JSON",
-		"PREFIX", "1", "memo:",
+		"PREFIX", "1", c.memoKey(""),
 		"SCHEMA",
-		"$.content", "AS", "content", "TEXT",
-		"$.type", "AS", "type", "TAG",
-		"$.tags[*]", "AS", "tags", "TAG",
-	).Result()
+	}
+	for _, field := range redisIndexSchema {
+		args = append(args, field[0], "AS", field[1], field[2])
+	}
+	if _, err := c.rdb.Do(ctx, args...).Result(); err != nil {
+		return err
+	}
 
-	return err
+	// Vectors stored before filter attributes existed get them now
+	return c.syncVectorAttrs()
 }
 
-// Remember stores a new memory
-func (c *Client) Remember(memType, content string, tags []string, project string) (*Memory, error) {
-	id := GenID()
-	ts := Now()
-
-	// Always include project tag
-	allTags := append([]string{"project:" + project}, tags...)
+// redisIndexSchema lists the indexed JSON paths as {path, alias, type}
+var redisIndexSchema = [][3]string{
+	{"$.content", "content", "TEXT"},
+	{"$.type", "type", "TAG"},
+	{"$.project", "project", "TAG"},
+	{"$.tags[*]", "tags", "TAG"},
+	{"$.anchors[*].path", "files", "TAG"},
+}
 
-	memo := Memory{
-		ID:          id,
-		Type:        memType,
-		Content:     content,
-		Tags:        allTags,
-		Created:     ts,
-		Accessed:    ts,
-		AccessCount: 0,
-	}
+// Remember stores a new memory
+func (c *RedisStore) Remember(memo *Memory) error {
+	initMemory(memo)
 
 	jsonData, err := json.Marshal(memo)
 	if err != nil {
-		return nil, err
-	}
-
-	_, err = c.rdb.Do(ctx, "JSON.SET", "memo:"+id, "$", string(jsonData)).Result()
-	if err != nil {
-		return nil, err
+		return err
 	}
 
-	return &memo, nil
+	_, err = c.rdb.Do(ctx, "JSON.SET", c.memoKey(memo.ID), "$", string(jsonData)).Result()
+	return err
 }
 
-// EmbedMemory adds a memory's embedding to the vector set
-func (c *Client) EmbedMemory(id string, embedding []float64) error {
-	args := []interface{}{"VADD", VectorSet, "VALUES", len(embedding)}
+// EmbedMemory adds a memory's embedding to the vector set, with its
+// filter attributes
+func (c *RedisStore) EmbedMemory(id string, embedding []float64) error {
+	args := []interface{}{"VADD", c.vectorSet(), "VALUES", len(embedding)}
 	for _, v := range embedding {
 		args = append(args, v)
 	}
 	args = append(args, id)
+	if memo, err := c.getMemoryRaw(id); err == nil {
+		args = append(args, "SETATTR", vectorAttrs(memo))
+	}
 
 	_, err := c.rdb.Do(ctx, args...).Result()
 	return err
 }
 
-// Recall searches memories using full-text search
-func (c *Client) Recall(query string, limit int) ([]Memory, error) {
-	result, err := c.rdb.Do(ctx, "FT.SEARCH", IndexName, query,
-		"LIMIT", "0", fmt.Sprint(limit),
-		"RETURN", "1", "$",
-	).Result()
-	if err != nil {
-		return nil, err
+// vectorAttrs is the JSON kept on each vector set element, so VSIM FILTER
+// can narrow by project, type, tags and creation time (Unix seconds)
+func vectorAttrs(m *Memory) string {
+	var created int64
+	if t, err := time.Parse(timeLayout, m.Created); err == nil {
+		created = t.Unix()
 	}
+	data, _ := json.Marshal(map[string]interface{}{
+		"project": m.Project,
+		"type":    m.Type,
+		"tags":    append([]string{}, m.Tags...),
+		"created": created,
+	})
+	return string(data)
+}
 
-	return parseSearchResults(result)
+// setVectorAttrs refreshes a memory's vector attributes after its document
+// changed. VSETATTR does nothing for a memory that has no vector yet.
+func (c *RedisStore) setVectorAttrs(m *Memory) error {
+	return c.rdb.Do(ctx, "VSETATTR", c.vectorSet(), m.ID, vectorAttrs(m)).Err()
 }
 
-// List returns memories with optional filters
-func (c *Client) List(typeFilter, tagFilter string, limit int) ([]Memory, error) {
-	query := "*"
-	if typeFilter != "" && tagFilter != "" {
-		query = fmt.Sprintf("@type:{%s} @tags:{%s}", typeFilter, tagFilter)
-	} else if typeFilter != "" {
-		query = fmt.Sprintf("@type:{%s}", typeFilter)
-	} else if tagFilter != "" {
-		query = fmt.Sprintf("@tags:{%s}", tagFilter)
+// syncVectorAttrs sets the attributes of every vector from its document
+func (c *RedisStore) syncVectorAttrs() error {
+	dims, err := c.EmbeddingDims()
+	if err != nil {
+		return err
+	}
+	var ids []string
+	for id := range dims {
+		ids = append(ids, id)
+	}
+	memos, err := c.getMemories(ids)
+	if err != nil {
+		return err
+	}
+	pipe := c.rdb.Pipeline()
+	for _, memo := range memos {
+		if memo != nil {
+			pipe.Do(ctx, "VSETATTR", c.vectorSet(), memo.ID, vectorAttrs(memo))
+		}
+	}
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// vectorFilter translates what it can of a filter into a VSIM FILTER
+// expression. exact is false when Matches must still drop results the
+// expression lets through (prefix tags, directories, branches, files).
+func vectorFilter(f Filter) (expr string, exact bool) {
+	var parts []string
+	exact = !f.clientSide()
+	if f.Type != "" {
+		parts = append(parts, fmt.Sprintf(".type == %q", f.Type))
+	}
+	if len(f.Projects) > 0 {
+		// The project tag too, like filterQuery, for unmigrated documents
+		var alts []string
+		for _, p := range f.Projects {
+			alts = append(alts, fmt.Sprintf(".project == %q", p), fmt.Sprintf("%q in .tags", "project:"+p))
+		}
+		parts = append(parts, "("+strings.Join(alts, " or ")+")")
+	}
+	if f.Tag != "" {
+		var alts []string
+		for _, alt := range strings.Split(f.Tag, "|") {
+			alt = strings.TrimSpace(alt)
+			if alt == "" {
+				continue
+			}
+			if strings.HasSuffix(alt, "*") {
+				// No prefix match in filter expressions; leave tags to Matches
+				alts, exact = nil, false
+				break
+			}
+			alts = append(alts, fmt.Sprintf("%q in .tags", alt))
+		}
+		if len(alts) > 0 {
+			parts = append(parts, "("+strings.Join(alts, " or ")+")")
+		}
 	}
+	return strings.Join(parts, " and "), exact
+}
 
-	result, err := c.rdb.Do(ctx, "FT.SEARCH", IndexName, query,
+// Recall searches memories using full-text search
+func (c *RedisStore) Recall(query string, limit int) ([]Memory, error) {
+	result, err := c.rdb.Do(ctx, "FT.SEARCH", c.indexName(), query,
 		"LIMIT", "0", fmt.Sprint(limit),
 		"RETURN", "1", "$",
 	).Result()
@@ -156,43 +255,102 @@ func (c *Client) List(typeFilter, tagFilter string, limit int) ([]Memory, error)
 	return parseSearchResults(result)
 }
 
-// Context returns memories for the current project
-func (c *Client) Context(project string, limit int) ([]Memory, error) {
-	// Use wildcard search and filter client-side (colon escaping is problematic)
-	result, err := c.rdb.Do(ctx, "FT.SEARCH", IndexName, "@tags:{project*}",
-		"LIMIT", "0", "100",
-		"RETURN", "1", "$",
-	).Result()
-	if err != nil {
-		return nil, fmt.Errorf("search error: %w", err)
+// Iterate streams memories matching the filter through an FT.AGGREGATE cursor
+func (c *RedisStore) Iterate(f Filter) MemoryIterator {
+	query := c.filterQuery(f)
+	var cursor int64 = -1 // -1 until the first page is read, 0 once exhausted
+
+	return &batchIterator{
+		filter: f.Matches,
+		fetch: func() ([]Memory, bool, error) {
+			var result interface{}
+			var err error
+			if cursor == -1 {
+				result, err = c.rdb.Do(ctx, "FT.AGGREGATE", c.indexName(), query,
+					"LOAD", "1", "$",
+					"WITHCURSOR", "COUNT", iterBatchSize,
+				).Result()
+			} else {
+				result, err = c.rdb.Do(ctx, "FT.CURSOR", "READ", c.indexName(), cursor,
+					"COUNT", iterBatchSize,
+				).Result()
+			}
+			if err != nil {
+				return nil, true, err
+			}
+
+			memos, next, err := parseCursorReply(result)
+			cursor = next
+			return memos, next == 0, err
+		},
+		close: func() error {
+			if cursor <= 0 {
+				return nil
+			}
+			id := cursor
+			cursor = 0
+			return c.rdb.Do(ctx, "FT.CURSOR", "DEL", c.indexName(), id).Err()
+		},
 	}
+}
 
-	memos, err := parseSearchResults(result)
+// Count returns the number of memories matching the filter
+func (c *RedisStore) Count(f Filter) (int, error) {
+	if f.clientSide() {
+		return countMatching(c.Iterate(f))
+	}
+	result, err := c.rdb.Do(ctx, "FT.SEARCH", c.indexName(), c.filterQuery(f),
+		"LIMIT", "0", "0",
+	).Result()
 	if err != nil {
-		return nil, fmt.Errorf("parse error: %w", err)
+		return 0, err
 	}
+	return parseSearchCount(result), nil
+}
 
-	// Filter by project
-	projectTag := "project:" + project
-	var filtered []Memory
-	for _, m := range memos {
-		for _, tag := range m.Tags {
-			if tag == projectTag {
-				filtered = append(filtered, m)
-				break
-			}
+// filterQuery translates a Filter into a RediSearch query
+func (c *RedisStore) filterQuery(f Filter) string {
+	var parts []string
+	if f.Type != "" {
+		parts = append(parts, fmt.Sprintf("@type:{%s}", escapeTagValue(f.Type)))
+	}
+	if len(f.Projects) > 0 {
+		// Match the tag rather than $.project so unmigrated documents are found too
+		var alts []string
+		for _, p := range f.Projects {
+			alts = append(alts, escapeTagValue("project:"+p))
 		}
-		if len(filtered) >= limit {
-			break
+		parts = append(parts, fmt.Sprintf("@tags:{%s}", strings.Join(alts, "|")))
+	}
+	if f.Tag != "" {
+		var alts []string
+		for _, alt := range strings.Split(f.Tag, "|") {
+			if strings.HasSuffix(alt, "*") {
+				alts = append(alts, escapeTagValue(strings.TrimSuffix(alt, "*"))+"*")
+			} else {
+				alts = append(alts, escapeTagValue(alt))
+			}
 		}
+		parts = append(parts, fmt.Sprintf("@tags:{%s}", strings.Join(alts, "|")))
 	}
+	if prefix := globPrefix(f.File); prefix != "" {
+		// Narrow by the literal prefix; Matches applies the exact glob
+		parts = append(parts, fmt.Sprintf("@files:{%s*}", escapeTagValue(prefix)))
+	}
+	if len(parts) == 0 {
+		return "*"
+	}
+	return strings.Join(parts, " ")
+}
 
-	return filtered, nil
+// Peek retrieves a memory without updating access stats
+func (c *RedisStore) Peek(id string) (*Memory, error) {
+	return c.getMemoryRaw(id)
 }
 
 // Get retrieves a specific memory and updates access stats
-func (c *Client) Get(id string) (*Memory, error) {
-	result, err := c.rdb.Do(ctx, "JSON.GET", "memo:"+id).Result()
+func (c *RedisStore) Get(id string) (*Memory, error) {
+	result, err := c.rdb.Do(ctx, "JSON.GET", c.memoKey(id)).Result()
 	if err != nil {
 		return nil, err
 	}
@@ -206,55 +364,34 @@ func (c *Client) Get(id string) (*Memory, error) {
 	}
 
 	// Update access stats
-	c.rdb.Do(ctx, "JSON.NUMINCRBY", "memo:"+id, "$.access_count", 1)
-	c.rdb.Do(ctx, "JSON.SET", "memo:"+id, "$.accessed", fmt.Sprintf("\"%s\"", Now()))
+	c.rdb.Do(ctx, "JSON.NUMINCRBY", c.memoKey(id), "$.access_count", 1)
+	c.rdb.Do(ctx, "JSON.SET", c.memoKey(id), "$.accessed", fmt.Sprintf("\"%s\"", Now()))
 
 	return &memo, nil
 }
 
 // AddTag adds a tag to an existing memory
-func (c *Client) AddTag(id, tag string) error {
-	// Get current memory
-	memo, err := c.getMemoryRaw(id)
-	if err != nil {
-		return err
-	}
-
-	// Check if tag already exists
-	for _, t := range memo.Tags {
-		if t == tag {
-			return fmt.Errorf("tag already exists: %s", tag)
-		}
-	}
-
-	// Append new tag
-	memo.Tags = append(memo.Tags, tag)
-	tagsJSON, _ := json.Marshal(memo.Tags)
-
-	_, err = c.rdb.Do(ctx, "JSON.SET", "memo:"+id, "$.tags", string(tagsJSON)).Result()
-	return err
+func (c *RedisStore) AddTag(id, tag string) error {
+	return addTag(c, id, tag)
 }
 
-// Update modifies a memory's content and re-embeds it
-func (c *Client) Update(id, content string) error {
-	// Check memory exists
-	_, err := c.Get(id)
-	if err != nil {
-		return err
-	}
-
-	// Update content
-	_, err = c.rdb.Do(ctx, "JSON.SET", "memo:"+id, "$.content", fmt.Sprintf("\"%s\"", content)).Result()
-	return err
+// Update replaces a memory's content (the caller re-embeds it)
+func (c *RedisStore) Update(id, content string) error {
+	return updateContent(c, id, content)
 }
 
-// GetEmbedding returns the embedding for a memory ID from the vector set
-func (c *Client) GetEmbeddingByID(id string) ([]float64, error) {
-	result, err := c.rdb.Do(ctx, "VEMB", VectorSet, id).Result()
+// GetEmbeddingByID returns the embedding for a memory ID from the vector set
+func (c *RedisStore) GetEmbeddingByID(id string) ([]float64, error) {
+	result, err := c.rdb.Do(ctx, "VEMB", c.vectorSet(), id).Result()
 	if err != nil {
 		return nil, err
 	}
 
+	return parseVector(result)
+}
+
+// parseVector decodes a VEMB reply
+func parseVector(result interface{}) ([]float64, error) {
 	arr, ok := result.([]interface{})
 	if !ok {
 		return nil, fmt.Errorf("unexpected VEMB result type: %T", result)
@@ -273,90 +410,57 @@ func (c *Client) GetEmbeddingByID(id string) ([]float64, error) {
 }
 
 // Forget deletes a memory
-func (c *Client) Forget(id string) error {
-	result, err := c.rdb.Do(ctx, "JSON.DEL", "memo:"+id).Result()
+func (c *RedisStore) Forget(id string) error {
+	result, err := c.rdb.Do(ctx, "JSON.DEL", c.memoKey(id)).Result()
 	if err != nil {
 		return err
 	}
 	if result.(int64) == 0 {
 		return fmt.Errorf("memory not found: %s", id)
 	}
+
+	// Drop the vector too, or VSIM keeps returning the deleted ID
+	c.DeleteEmbedding(id)
 	return nil
 }
 
 // Projects returns all projects with their memory counts
-func (c *Client) Projects() (map[string]int, error) {
-	// Get all memories with project tags
-	result, err := c.rdb.Do(ctx, "FT.SEARCH", IndexName, "@tags:{project*}",
-		"LIMIT", "0", "1000",
-		"RETURN", "1", "$.tags",
-	).Result()
-	if err != nil {
-		return nil, err
-	}
-
+func (c *RedisStore) Projects() (map[string]int, error) {
 	projects := make(map[string]int)
-
-	// Parse results to extract project tags
-	switch res := result.(type) {
-	case map[interface{}]interface{}:
-		results, ok := res["results"]
-		if !ok {
-			return projects, nil
-		}
-		resultsArr, ok := results.([]interface{})
-		if !ok {
-			return projects, nil
-		}
-		for _, item := range resultsArr {
-			itemMap, ok := item.(map[interface{}]interface{})
-			if !ok {
-				continue
-			}
-			extraAttrs, ok := itemMap["extra_attributes"]
-			if !ok {
-				continue
-			}
-			attrsMap, ok := extraAttrs.(map[interface{}]interface{})
-			if !ok {
-				continue
-			}
-			tagsStr, ok := attrsMap["$.tags"].(string)
-			if !ok {
-				continue
-			}
-			var tags []string
-			json.Unmarshal([]byte(tagsStr), &tags)
-			for _, tag := range tags {
-				if len(tag) > 8 && tag[:8] == "project:" {
-					projects[tag[8:]]++
-				}
+	it := c.Iterate(Filter{Tag: "project:*"})
+	defer it.Close()
+	for it.Next() {
+		for _, tag := range it.Val().Tags {
+			if len(tag) > 8 && tag[:8] == "project:" {
+				projects[tag[8:]]++
 			}
 		}
 	}
+	return projects, it.Err()
+}
 
-	return projects, nil
+// ProjectAliases returns the alias hash
+func (c *RedisStore) ProjectAliases() (map[string]string, error) {
+	return c.rdb.HGetAll(ctx, c.aliasKey()).Result()
 }
 
-// AllMemories returns all memories (for pruning/bulk operations)
-func (c *Client) AllMemories() ([]Memory, error) {
-	result, err := c.rdb.Do(ctx, "FT.SEARCH", IndexName, "*",
-		"LIMIT", "0", "1000",
-		"RETURN", "1", "$",
-	).Result()
-	if err != nil {
-		return nil, err
-	}
-	return parseSearchResults(result)
+// SetProjectAlias makes alias resolve to a project key
+func (c *RedisStore) SetProjectAlias(alias, key string) error {
+	return c.rdb.HSet(ctx, c.aliasKey(), alias, key).Err()
+}
+
+// DeleteProjectAlias removes an alias
+func (c *RedisStore) DeleteProjectAlias(alias string) error {
+	return c.rdb.HDel(ctx, c.aliasKey(), alias).Err()
 }
 
 // Stats returns memory statistics
-func (c *Client) Stats() (map[string]int, error) {
+func (c *RedisStore) Stats() (map[string]int, error) {
 	stats := make(map[string]int)
 	types := []string{"fact", "context", "learned", "preference"}
 
 	for _, t := range types {
-		result, err := c.rdb.Do(ctx, "FT.SEARCH", IndexName,
+		result, err := c.rdb.Do(ctx, "FT.SEARCH", c.indexName(),
 			fmt.Sprintf("@type:{%s}", t),
 			"LIMIT", "0", "0",
 		).Result()
@@ -367,7 +471,7 @@ func (c *Client) Stats() (map[string]int, error) {
 	}
 
 	// Total
-	result, err := c.rdb.Do(ctx, "FT.SEARCH", IndexName, "*", "LIMIT", "0", "0").Result()
+	result, err := c.rdb.Do(ctx, "FT.SEARCH", c.indexName(), "*", "LIMIT", "0", "0").Result()
 	if err == nil {
 		stats["total"] = parseSearchCount(result)
 	}
@@ -399,24 +503,28 @@ func parseSearchCount(result interface{}) int {
 }
 
 // Similar finds semantically similar memories
-func (c *Client) Similar(embedding []float64, limit int, project string) ([]SimilarResult, error) {
+func (c *RedisStore) Similar(embedding []float64, limit int, f Filter) ([]SimilarResult, error) {
 	// Check if vector set exists
-	_, err := c.rdb.Do(ctx, "VCARD", VectorSet).Result()
+	_, err := c.rdb.Do(ctx, "VCARD", c.vectorSet()).Result()
 	if err != nil {
 		return nil, fmt.Errorf("no embeddings found - run 'memo reindex' first")
 	}
 
-	// Build VSIM command
+	// Build VSIM command; the server filters on vector attributes
+	expr, exact := vectorFilter(f)
 	fetchLimit := limit
-	if project != "" {
+	if !exact {
 		fetchLimit = limit * 3 // Fetch more to filter
 	}
 
-	args := []interface{}{"VSIM", VectorSet, "VALUES", len(embedding)}
+	args := []interface{}{"VSIM", c.vectorSet(), "VALUES", len(embedding)}
 	for _, v := range embedding {
 		args = append(args, v)
 	}
 	args = append(args, "COUNT", fetchLimit, "WITHSCORES")
+	if expr != "" {
+		args = append(args, "FILTER", expr)
+	}
 
 	result, err := c.rdb.Do(ctx, args...).Result()
 	if err != nil {
@@ -473,33 +581,26 @@ func (c *Client) Similar(embedding []float64, limit int, project string) ([]Simi
 		return nil, fmt.Errorf("unexpected VSIM result type: %T", result)
 	}
 
-	projectTag := "project:" + project
+	ids := make([]string, len(items))
+	for i, item := range items {
+		ids[i] = item.id
+	}
+	memos, err := c.getMemories(ids)
+	if err != nil {
+		return nil, err
+	}
+
 	var results []SimilarResult
-	for _, item := range items {
+	for i, item := range items {
 		if len(results) >= limit {
 			break
 		}
 
-		// Get memory details
-		memo, err := c.getMemoryRaw(item.id)
-		if err != nil {
+		memo := memos[i]
+		if memo == nil || !f.Matches(*memo) {
 			continue
 		}
 
-		// Filter by project if specified
-		if project != "" {
-			found := false
-			for _, tag := range memo.Tags {
-				if tag == projectTag {
-					found = true
-					break
-				}
-			}
-			if !found {
-				continue
-			}
-		}
-
 		results = append(results, SimilarResult{
 			Memory: *memo,
 			Score:  item.score,
@@ -509,15 +610,9 @@ func (c *Client) Similar(embedding []float64, limit int, project string) ([]Simi
 	return results, nil
 }
 
-// SimilarResult holds a memory with its similarity score
-type SimilarResult struct {
-	Memory Memory
-	Score  string
-}
-
 // getMemoryRaw retrieves a memory without updating access stats
-func (c *Client) getMemoryRaw(id string) (*Memory, error) {
-	result, err := c.rdb.Do(ctx, "JSON.GET", "memo:"+id).Result()
+func (c *RedisStore) getMemoryRaw(id string) (*Memory, error) {
+	result, err := c.rdb.Do(ctx, "JSON.GET", c.memoKey(id)).Result()
 	if err != nil {
 		return nil, err
 	}
@@ -532,25 +627,434 @@ func (c *Client) getMemoryRaw(id string) (*Memory, error) {
 	return &memo, nil
 }
 
+// getMemories reads many memories in one JSON.MGET, in the order of ids
+// (nil for those that no longer exist)
+func (c *RedisStore) getMemories(ids []string) ([]*Memory, error) {
+	memos := make([]*Memory, len(ids))
+	if len(ids) == 0 {
+		return memos, nil
+	}
+	args := []interface{}{"JSON.MGET"}
+	for _, id := range ids {
+		args = append(args, c.memoKey(id))
+	}
+	args = append(args, "$")
+	result, err := c.rdb.Do(ctx, args...).Slice()
+	if err != nil {
+		return nil, err
+	}
+	for i, r := range result {
+		data, ok := r.(string)
+		if !ok || i >= len(ids) {
+			continue
+		}
+		var found []Memory
+		if err := json.Unmarshal([]byte(data), &found); err != nil || len(found) == 0 {
+			continue
+		}
+		memos[i] = &found[0]
+	}
+	return memos, nil
+}
+
 // GetAllMemoryIDs returns all memory IDs for reindexing
-func (c *Client) GetAllMemoryIDs() ([]string, error) {
+func (c *RedisStore) GetAllMemoryIDs() ([]string, error) {
 	var ids []string
-	iter := c.rdb.Scan(ctx, 0, "memo:*", 0).Iterator()
+	keyPrefix := c.memoKey("")
+	// Only JSON keys are memories; memo:meta is a hash
+	iter := c.rdb.ScanType(ctx, 0, keyPrefix+"*", 0, "ReJSON-RL").Iterator()
 	for iter.Next(ctx) {
-		key := iter.Val()
-		ids = append(ids, key[5:]) // Remove "memo:" prefix
+		ids = append(ids, strings.TrimPrefix(iter.Val(), keyPrefix))
 	}
 	return ids, iter.Err()
 }
 
-// DeleteVectorSet removes all vectors for reindexing
-func (c *Client) DeleteVectorSet() error {
-	return c.rdb.Del(ctx, VectorSet).Err()
+// SchemaVersion returns the schema version stored in memo:meta
+func (c *RedisStore) SchemaVersion() (int, error) {
+	v, err := c.rdb.HGet(ctx, c.metaKey(), "schema_version").Int()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return v, err
+}
+
+// SetSchemaVersion records the schema version in memo:meta
+func (c *RedisStore) SetSchemaVersion(version int) error {
+	return c.rdb.HSet(ctx, c.metaKey(), "schema_version", version, "migrated", Now()).Err()
+}
+
+// Documents returns every memory document, fetched in JSON.MGET batches
+func (c *RedisStore) Documents() (map[string]map[string]interface{}, error) {
+	ids, err := c.GetAllMemoryIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	docs := make(map[string]map[string]interface{}, len(ids))
+	for start := 0; start < len(ids); start += 100 {
+		batch := ids[start:min(start+100, len(ids))]
+		args := []interface{}{"JSON.MGET"}
+		for _, id := range batch {
+			args = append(args, c.memoKey(id))
+		}
+		args = append(args, "$")
+
+		result, err := c.rdb.Do(ctx, args...).Result()
+		if err != nil {
+			return nil, err
+		}
+		values, ok := result.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("unexpected JSON.MGET result type: %T", result)
+		}
+		for i, v := range values {
+			str, ok := v.(string)
+			if !ok {
+				continue // deleted since SCAN
+			}
+			// The $ path wraps each document in an array
+			var wrapped []map[string]interface{}
+			if err := json.Unmarshal([]byte(str), &wrapped); err != nil || len(wrapped) == 0 {
+				continue
+			}
+			docs[batch[i]] = wrapped[0]
+		}
+	}
+	return docs, nil
+}
+
+// Dump reads the store in one MULTI/EXEC transaction, so no write lands
+// halfway through. Keys are listed by SCAN beforehand: a memory stored
+// between the scan and the transaction is left out.
+func (c *RedisStore) Dump() (*Dump, error) {
+	ids, err := c.GetAllMemoryIDs()
+	if err != nil {
+		return nil, err
+	}
+	projects, err := c.BriefProjects()
+	if err != nil {
+		return nil, err
+	}
+
+	var docCmds, vecCmds []*redis.Cmd
+	var briefCmds, staleCmds []*redis.StringCmd
+	var aliasCmd *redis.MapStringStringCmd
+	var versionCmd *redis.StringCmd
+	_, err = c.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, id := range ids {
+			docCmds = append(docCmds, pipe.Do(ctx, "JSON.GET", c.memoKey(id)))
+			vecCmds = append(vecCmds, pipe.Do(ctx, "VEMB", c.vectorSet(), id))
+		}
+		for _, project := range projects {
+			briefCmds = append(briefCmds, pipe.Get(ctx, c.briefKey(project)))
+			staleCmds = append(staleCmds, pipe.Get(ctx, c.briefKey(project)+":stale"))
+		}
+		aliasCmd = pipe.HGetAll(ctx, c.aliasKey())
+		versionCmd = pipe.HGet(ctx, c.metaKey(), "schema_version")
+		return nil
+	})
+	// Missing vectors, briefs and the version come back as redis.Nil
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	d := newDump()
+	d.SchemaVersion, _ = versionCmd.Int()
+	for i, id := range ids {
+		str, err := docCmds[i].Text()
+		if err != nil {
+			continue // deleted since SCAN
+		}
+		var doc map[string]interface{}
+		if err := json.Unmarshal([]byte(str), &doc); err != nil {
+			continue
+		}
+		d.Docs[id] = doc
+		if result, err := vecCmds[i].Result(); err == nil {
+			if embedding, err := parseVector(result); err == nil {
+				d.Embeddings[id] = embedding
+			}
+		}
+	}
+	for i, project := range projects {
+		d.Briefs[project] = briefCmds[i].Val()
+		d.StaleBriefs[project] = staleCmds[i].Val() != "0"
+	}
+	if aliases, err := aliasCmd.Result(); err == nil {
+		d.Aliases = aliases
+	}
+	return d, nil
+}
+
+// PutDocument overwrites a memory document
+func (c *RedisStore) PutDocument(id string, doc map[string]interface{}) error {
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	if err := c.rdb.Do(ctx, "JSON.SET", c.memoKey(id), "$", string(data)).Err(); err != nil {
+		return err
+	}
+	// Keep VSIM FILTER in step with updates, tags, merges and moves
+	memo, err := documentToMemory(doc)
+	if err != nil {
+		return nil
+	}
+	memo.ID = id
+	return c.setVectorAttrs(memo)
+}
+
+// PutTrash stores a trash entry in the memo:trash hash
+func (c *RedisStore) PutTrash(e TrashEntry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return c.rdb.HSet(ctx, c.trashKey(), e.ID, data).Err()
+}
+
+// TrashEntries returns the trash, most recently deleted first
+func (c *RedisStore) TrashEntries() ([]TrashEntry, error) {
+	fields, err := c.rdb.HGetAll(ctx, c.trashKey()).Result()
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]TrashEntry, 0, len(fields))
+	for id, data := range fields {
+		var e TrashEntry
+		if err := json.Unmarshal([]byte(data), &e); err != nil {
+			return nil, fmt.Errorf("corrupt trash entry %s: %w", id, err)
+		}
+		entries = append(entries, e)
+	}
+	sortTrash(entries)
+	return entries, nil
+}
+
+// DeleteTrash removes a trash entry
+func (c *RedisStore) DeleteTrash(id string) error {
+	return c.rdb.HDel(ctx, c.trashKey(), id).Err()
+}
+
+// Quarantine moves a memory's raw JSON into the memo:quarantine hash
+func (c *RedisStore) Quarantine(id string) (string, error) {
+	raw, err := c.rdb.Do(ctx, "JSON.GET", c.memoKey(id)).Text()
+	if err != nil {
+		return "", err
+	}
+	if err := c.rdb.HSet(ctx, c.quarantineKey(), id, raw).Err(); err != nil {
+		return "", err
+	}
+	if err := c.rdb.Del(ctx, c.memoKey(id)).Err(); err != nil {
+		return "", err
+	}
+	c.DeleteEmbedding(id)
+	return c.quarantineKey(), nil
+}
+
+// AppendLog adds an entry to the memo:log stream
+func (c *RedisStore) AppendLog(e LogEntry) error {
+	return c.rdb.XAdd(ctx, &redis.XAddArgs{
+		Stream: c.logKey(),
+		Values: map[string]interface{}{
+			"time":    e.Time,
+			"actor":   e.Actor,
+			"project": e.Project,
+			"command": e.Command,
+			"action":  e.Action,
+			"id":      e.ID,
+			"before":  e.Before,
+			"after":   e.After,
+		},
+	}).Err()
+}
+
+// ReadLog reads the memo:log stream. The time range narrows the XRANGE by
+// stream ID (entries are added within moments of their timestamp); the
+// rest is filtered client-side.
+func (c *RedisStore) ReadLog(q LogQuery) ([]LogEntry, error) {
+	start, end := "-", "+"
+	if t, err := time.Parse(timeLayout, q.Since); err == nil {
+		start = strconv.FormatInt(t.UnixMilli(), 10)
+	}
+	if t, err := time.Parse(timeLayout, q.Until); err == nil {
+		end = strconv.FormatInt(t.Add(time.Minute).UnixMilli(), 10)
+	}
+	msgs, err := c.rdb.XRange(ctx, c.logKey(), start, end).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []LogEntry
+	for _, msg := range msgs {
+		field := func(name string) string {
+			v, _ := msg.Values[name].(string)
+			return v
+		}
+		e := LogEntry{
+			Time:    field("time"),
+			Actor:   field("actor"),
+			Project: field("project"),
+			Command: field("command"),
+			Action:  field("action"),
+			ID:      field("id"),
+			Before:  field("before"),
+			After:   field("after"),
+		}
+		if q.Matches(e) {
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
+
+// PushUndo prepends an operation to the memo:undo list
+func (c *RedisStore) PushUndo(op *Operation) error {
+	data, err := json.Marshal(op)
+	if err != nil {
+		return err
+	}
+	pipe := c.rdb.TxPipeline()
+	pipe.LPush(ctx, c.undoKey(), data)
+	pipe.LTrim(ctx, c.undoKey(), 0, undoDepth-1)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// PopUndo takes the newest operation off the memo:undo list
+func (c *RedisStore) PopUndo() (*Operation, error) {
+	data, err := c.rdb.LPop(ctx, c.undoKey()).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var op Operation
+	if err := json.Unmarshal([]byte(data), &op); err != nil {
+		return nil, err
+	}
+	return &op, nil
+}
+
+// DeleteEmbedding removes one memory's vector
+func (c *RedisStore) DeleteEmbedding(id string) error {
+	return c.rdb.Do(ctx, "VREM", c.vectorSet(), id).Err()
+}
+
+// EmbeddingDims returns every element of the vector set with the set's
+// dimension (a vector set holds a single dimension)
+func (c *RedisStore) EmbeddingDims() (map[string]int, error) {
+	dims := make(map[string]int)
+	card, err := c.rdb.Do(ctx, "VCARD", c.vectorSet()).Int()
+	if err == redis.Nil || card == 0 {
+		return dims, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	dim, err := c.rdb.Do(ctx, "VDIM", c.vectorSet()).Int()
+	if err != nil {
+		return nil, err
+	}
+
+	// A positive count returns distinct elements, so this lists the whole set
+	members, err := c.rdb.Do(ctx, "VRANDMEMBER", c.vectorSet(), card).StringSlice()
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range members {
+		dims[id] = dim
+	}
+	return dims, nil
+}
+
+// CheckIndex compares memo_idx against the schema Init creates and the
+// number of JSON documents stored, and checks vectors carry attributes
+func (c *RedisStore) CheckIndex() ([]string, error) {
+	result, err := c.rdb.Do(ctx, "FT.INFO", c.indexName()).Result()
+	if err != nil {
+		if strings.Contains(strings.ToLower(err.Error()), "unknown index") ||
+			strings.Contains(strings.ToLower(err.Error()), "no such index") {
+			return []string{fmt.Sprintf("%s does not exist", c.indexName())}, nil
+		}
+		return nil, err
+	}
+
+	var issues []string
+	found := make(map[string]bool)
+	collectStrings(result, found)
+	for _, field := range redisIndexSchema {
+		if !found[field[0]] {
+			issues = append(issues, fmt.Sprintf("%s is missing field %s (index predates this version)", c.indexName(), field[0]))
+		}
+	}
+
+	ids, err := c.GetAllMemoryIDs()
+	if err != nil {
+		return nil, err
+	}
+	if n, ok := ftInfoInt(result, "num_docs"); ok && n != len(ids) {
+		issues = append(issues, fmt.Sprintf("%s covers %d documents, %d stored", c.indexName(), n, len(ids)))
+	}
+	if n, ok := ftInfoInt(result, "hash_indexing_failures"); ok && n > 0 {
+		issues = append(issues, fmt.Sprintf("%s failed to index %d documents", c.indexName(), n))
+	}
+
+	// Filtered similarity searches skip vectors without attributes
+	dims, err := c.EmbeddingDims()
+	if err != nil {
+		return nil, err
+	}
+	pipe := c.rdb.Pipeline()
+	attrs := make([]*redis.Cmd, 0, len(dims))
+	for id := range dims {
+		attrs = append(attrs, pipe.Do(ctx, "VGETATTR", c.vectorSet(), id))
+	}
+	if len(attrs) > 0 {
+		pipe.Exec(ctx)
+	}
+	missing := 0
+	for _, cmd := range attrs {
+		if s, err := cmd.Text(); err != nil || s == "" {
+			missing++
+		}
+	}
+	if missing > 0 {
+		issues = append(issues, fmt.Sprintf("%s has %d vectors without filter attributes", c.vectorSet(), missing))
+	}
+	return issues, nil
+}
+
+// BriefProjects returns every project with a brief or stale flag
+func (c *RedisStore) BriefProjects() ([]string, error) {
+	keyPrefix := c.briefKey("")
+	seen := make(map[string]bool)
+	var projects []string
+	iter := c.rdb.Scan(ctx, 0, keyPrefix+"*", 0).Iterator()
+	for iter.Next(ctx) {
+		project := strings.TrimSuffix(strings.TrimPrefix(iter.Val(), keyPrefix), ":stale")
+		if !seen[project] {
+			seen[project] = true
+			projects = append(projects, project)
+		}
+	}
+	return projects, iter.Err()
+}
+
+// DeleteBrief removes a project's brief and stale flag
+func (c *RedisStore) DeleteBrief(project string) error {
+	return c.rdb.Del(ctx, c.briefKey(project), c.briefKey(project)+":stale").Err()
+}
+
+// DeleteEmbeddings removes all vectors for reindexing
+func (c *RedisStore) DeleteEmbeddings() error {
+	return c.rdb.Del(ctx, c.vectorSet()).Err()
 }
 
 // GetBrief returns the stored brief for a project
-func (c *Client) GetBrief(project string) (string, error) {
-	result, err := c.rdb.Get(ctx, "brief:"+project).Result()
+func (c *RedisStore) GetBrief(project string) (string, error) {
+	result, err := c.rdb.Get(ctx, c.briefKey(project)).Result()
 	if err != nil {
 		return "", err
 	}
@@ -558,13 +1062,13 @@ func (c *Client) GetBrief(project string) (string, error) {
 }
 
 // SetBrief stores a brief for a project
-func (c *Client) SetBrief(project, brief string) error {
-	return c.rdb.Set(ctx, "brief:"+project, brief, 0).Err()
+func (c *RedisStore) SetBrief(project, brief string) error {
+	return c.rdb.Set(ctx, c.briefKey(project), brief, 0).Err()
 }
 
 // IsBriefStale checks if the brief needs regeneration
-func (c *Client) IsBriefStale(project string) bool {
-	result, err := c.rdb.Get(ctx, "brief:"+project+":stale").Result()
+func (c *RedisStore) IsBriefStale(project string) bool {
+	result, err := c.rdb.Get(ctx, c.briefKey(project)+":stale").Result()
 	if err != nil {
 		return true // No stale flag means never generated
 	}
@@ -572,20 +1076,20 @@ func (c *Client) IsBriefStale(project string) bool {
 }
 
 // MarkBriefStale marks a project's brief as needing regeneration
-func (c *Client) MarkBriefStale(project string) {
-	c.rdb.Set(ctx, "brief:"+project+":stale", "1", 0)
+func (c *RedisStore) MarkBriefStale(project string) {
+	c.rdb.Set(ctx, c.briefKey(project)+":stale", "1", 0)
 }
 
 // MarkBriefFresh marks a project's brief as up to date
-func (c *Client) MarkBriefFresh(project string) {
-	c.rdb.Set(ctx, "brief:"+project+":stale", "0", 0)
+func (c *RedisStore) MarkBriefFresh(project string) {
+	c.rdb.Set(ctx, c.briefKey(project)+":stale", "0", 0)
 }
 
 // TextSearch performs full-text search using FT.SEARCH for dedup fallback
-func (c *Client) TextSearch(query string, limit int) ([]Memory, error) {
+func (c *RedisStore) TextSearch(query string, limit int) ([]Memory, error) {
 	// Escape special RediSearch characters
 	escaped := escapeRedisQuery(query)
-	result, err := c.rdb.Do(ctx, "FT.SEARCH", IndexName, escaped,
+	result, err := c.rdb.Do(ctx, "FT.SEARCH", c.indexName(), escaped,
 		"LIMIT", "0", fmt.Sprint(limit),
 		"RETURN", "1", "$",
 	).Result()
@@ -595,6 +1099,45 @@ func (c *Client) TextSearch(query string, limit int) ([]Memory, error) {
 	return parseSearchResults(result)
 }
 
+// KeywordSearch ORs the query's words so a single exact identifier still
+// matches; Redis 8 orders FT.SEARCH results by BM25 by default
+func (c *RedisStore) KeywordSearch(query string, limit int, f Filter) ([]Memory, error) {
+	var words []string
+	for _, w := range tokenize(query) {
+		words = append(words, escapeRedisQuery(w))
+	}
+	if len(words) == 0 {
+		return nil, nil
+	}
+	q := "(" + strings.Join(words, " | ") + ")"
+	if fq := c.filterQuery(f); fq != "*" {
+		q += " " + fq
+	}
+	fetchLimit := limit
+	if f.clientSide() {
+		fetchLimit = limit * 3 // Fetch more to filter
+	}
+
+	result, err := c.rdb.Do(ctx, "FT.SEARCH", c.indexName(), q,
+		"LIMIT", "0", fmt.Sprint(fetchLimit),
+		"RETURN", "1", "$",
+	).Result()
+	if err != nil {
+		return nil, err
+	}
+	memos, err := parseSearchResults(result)
+	if err != nil {
+		return nil, err
+	}
+	var matched []Memory
+	for _, m := range memos {
+		if f.Matches(m) && len(matched) < limit {
+			matched = append(matched, m)
+		}
+	}
+	return matched, nil
+}
+
 // escapeRedisQuery escapes special characters for RediSearch queries
 func escapeRedisQuery(q string) string {
 	special := []string{",", ".", "<", ">", "{", "}", "[", "]", "\"", "'", ":", ";", "!", "@", "#", "$", "%", "^", "&", "*", "(", ")", "-", "+", "=", "~"}
@@ -604,6 +1147,106 @@ func escapeRedisQuery(q string) string {
 	return q
 }
 
+// parseCursorReply parses an FT.AGGREGATE WITHCURSOR or FT.CURSOR READ reply:
+// a page of rows and the next cursor ID (0 when exhausted)
+func parseCursorReply(result interface{}) ([]Memory, int64, error) {
+	reply, ok := result.([]interface{})
+	if !ok || len(reply) != 2 {
+		return nil, 0, fmt.Errorf("unexpected cursor reply: %T", result)
+	}
+	cursor, _ := reply[1].(int64)
+
+	switch page := reply[0].(type) {
+	case map[interface{}]interface{}:
+		// RESP3 rows have the same shape as FT.SEARCH results
+		memos, err := parseSearchResults(page)
+		return memos, cursor, err
+
+	case []interface{}:
+		// RESP2 format: [count, [field1, value1, ...], [field1, value1, ...], ...]
+		var memos []Memory
+		for i := 1; i < len(page); i++ {
+			fields, ok := page[i].([]interface{})
+			if !ok {
+				continue
+			}
+			for j := 0; j+1 < len(fields); j += 2 {
+				if name, _ := fields[j].(string); name != "$" {
+					continue
+				}
+				jsonStr, _ := fields[j+1].(string)
+				var memo Memory
+				if err := json.Unmarshal([]byte(jsonStr), &memo); err == nil {
+					memos = append(memos, memo)
+				}
+				break
+			}
+		}
+		return memos, cursor, nil
+	}
+	return nil, cursor, fmt.Errorf("unexpected cursor page: %T", reply[0])
+}
+
+// escapeTagValue escapes everything but letters, digits and underscores so
+// a value can be matched literally inside @field:{...}
+func escapeTagValue(v string) string {
+	var b strings.Builder
+	for _, r := range v {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// collectStrings records every string found in a nested RESP2/RESP3 reply
+func collectStrings(v interface{}, found map[string]bool) {
+	switch val := v.(type) {
+	case string:
+		found[val] = true
+	case []interface{}:
+		for _, item := range val {
+			collectStrings(item, found)
+		}
+	case map[interface{}]interface{}:
+		for k, item := range val {
+			collectStrings(k, found)
+			collectStrings(item, found)
+		}
+	}
+}
+
+// ftInfoInt reads a top-level numeric field from an FT.INFO reply
+func ftInfoInt(result interface{}, name string) (int, bool) {
+	var raw interface{}
+	switch res := result.(type) {
+	case map[interface{}]interface{}:
+		// RESP3 format
+		raw = res[name]
+	case []interface{}:
+		// RESP2 format: [key1, value1, key2, value2, ...]
+		for i := 0; i+1 < len(res); i += 2 {
+			if k, ok := res[i].(string); ok && k == name {
+				raw = res[i+1]
+				break
+			}
+		}
+	}
+	switch n := raw.(type) {
+	case int64:
+		return int(n), true
+	case float64:
+		return int(n), true
+	case string:
+		var i int
+		if _, err := fmt.Sscanf(n, "%d", &i); err == nil {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
 // parseSearchResults parses FT.SEARCH results into Memory structs
 // Handles both RESP2 (array) and RESP3 (map) formats
 func parseSearchResults(result interface{}) ([]Memory, error) {
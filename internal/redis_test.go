@@ -0,0 +1,84 @@
+package internal
+
+import "testing"
+
+func TestVectorFilter(t *testing.T) {
+	tests := []struct {
+		name  string
+		f     Filter
+		want  string
+		exact bool
+	}{
+		{"nothing", Filter{}, "", true},
+		{"type", Filter{Type: "fact"}, `.type == "fact"`, true},
+		{"projects", Filter{Projects: []string{"github.com/acme/api", "old"}},
+			`(.project == "github.com/acme/api" or "project:github.com/acme/api" in .tags or .project == "old" or "project:old" in .tags)`, true},
+		{"tag alternatives", Filter{Tag: "ci | build|"}, `("ci" in .tags or "build" in .tags)`, true},
+		{"prefix tag left to Matches", Filter{Type: "fact", Tag: "ci|lang:*"}, `.type == "fact"`, false},
+		{"quotes escaped", Filter{Tag: `say "hi"`}, `("say \"hi\"" in .tags)`, true},
+		{"directory", Filter{Type: "fact", InDir: "cmd"}, `.type == "fact"`, false},
+		{"branch", Filter{OnBranch: "main"}, "", false},
+		{"file", Filter{File: "internal/**"}, "", false},
+	}
+	for _, tt := range tests {
+		expr, exact := vectorFilter(tt.f)
+		if expr != tt.want || exact != tt.exact {
+			t.Errorf("%s: vectorFilter() = %s, %v, want %s, %v", tt.name, expr, exact, tt.want, tt.exact)
+		}
+	}
+}
+
+func TestFilterQuery(t *testing.T) {
+	tests := []struct {
+		f    Filter
+		want string
+	}{
+		{Filter{}, "*"},
+		{Filter{InDir: "cmd", OnBranch: "main"}, "*"},
+		{Filter{Type: "fact"}, `@type:{fact}`},
+		{Filter{Projects: []string{"github.com/acme/api", "a-b"}}, `@tags:{project\:github\.com\/acme\/api|project\:a\-b}`},
+		{Filter{Tag: "ci|lang:go*"}, `@tags:{ci|lang\:go*}`},
+		{Filter{Type: "fact", Tag: "ci"}, `@type:{fact} @tags:{ci}`},
+		{Filter{File: "internal/**/*.go"}, `@files:{internal\/*}`},
+		{Filter{File: "*.go"}, "*"},
+	}
+	c := &RedisStore{}
+	for _, tt := range tests {
+		if got := c.filterQuery(tt.f); got != tt.want {
+			t.Errorf("filterQuery(%+v) = %s, want %s", tt.f, got, tt.want)
+		}
+	}
+}
+
+func TestEscapeTagValue(t *testing.T) {
+	tests := []struct {
+		v, want string
+	}{
+		{"plain_tag9", "plain_tag9"},
+		{"project:github.com/acme/api", `project\:github\.com\/acme\/api`},
+		{"two words", `two\ words`},
+		{"café", "café"},
+		{`a|b{c}\`, `a\|b\{c\}\\`},
+	}
+	for _, tt := range tests {
+		if got := escapeTagValue(tt.v); got != tt.want {
+			t.Errorf("escapeTagValue(%q) = %s, want %s", tt.v, got, tt.want)
+		}
+	}
+}
+
+func TestEscapeRedisQuery(t *testing.T) {
+	tests := []struct {
+		q, want string
+	}{
+		{"plain words", "plain words"},
+		{"redis-cli", `redis\-cli`},
+		{"@type:{fact}", `\@type\:\{fact\}`},
+		{"v1.2 (beta)*", `v1\.2 \(beta\)\*`},
+	}
+	for _, tt := range tests {
+		if got := escapeRedisQuery(tt.q); got != tt.want {
+			t.Errorf("escapeRedisQuery(%q) = %s, want %s", tt.q, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Reranked is a memory placed by the reranker
+type Reranked struct {
+	Memory Memory
+	// Score is the reranker's relevance, 0 to 1
+	Score float64
+	// Reason says why, when the LLM was asked to explain
+	Reason string
+	// Rank is the 1-based position before reranking
+	Rank int
+}
+
+// RerankCandidates is how many top results the reranker reorders
+func RerankCandidates() int {
+	return settings.Rerank.Candidates
+}
+
+// Rerank reorders memories by relevance to query with the configured
+// reranker, best first. Memories the reranker leaves out keep their
+// relative order after the ones it scored.
+func Rerank(query string, memos []Memory, explain bool) ([]Reranked, error) {
+	ranked := make([]Reranked, len(memos))
+	for i, m := range memos {
+		ranked[i] = Reranked{Memory: m, Rank: i + 1, Score: -1}
+	}
+	if len(memos) == 0 {
+		return ranked, nil
+	}
+
+	var err error
+	if settings.Rerank.Provider == "tei" {
+		err = rerankTEI(query, ranked)
+	} else {
+		err = rerankLLM(query, ranked, explain)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].Score > ranked[j].Score
+	})
+	for i := range ranked {
+		ranked[i].Score = max(ranked[i].Score, 0)
+	}
+	return ranked, nil
+}
+
+type teiRerankRequest struct {
+	Query string   `json:"query"`
+	Texts []string `json:"texts"`
+}
+
+type teiRerankResult struct {
+	Index int     `json:"index"`
+	Score float64 `json:"score"`
+}
+
+// rerankTEI scores each memory with a cross-encoder served in TEI's /rerank format
+func rerankTEI(query string, ranked []Reranked) error {
+	reqBody := teiRerankRequest{Query: query}
+	for _, r := range ranked {
+		reqBody.Texts = append(reqBody.Texts, r.Memory.Content)
+	}
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", settings.Rerank.URL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("rerank service unavailable: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("rerank service error: %d", resp.StatusCode)
+	}
+
+	var results []teiRerankResult
+	if err := json.NewDecoder(resp.Body).Decode(&results); err != nil {
+		return err
+	}
+	for _, r := range results {
+		if r.Index >= 0 && r.Index < len(ranked) {
+			ranked[r.Index].Score = r.Score
+		}
+	}
+	return nil
+}
+
+// rerankLine matches "N: score" with an optional " - reason"
+var rerankLine = regexp.MustCompile(`(?m)^\s*(\d+)\s*[:.)]\s*(\d+(?:\.\d+)?)(?:\s*(?:/\s*10)?\s*[-:–—]\s*(.+))?\s*$`)
+
+// rerankLLM asks the LLM to rate each memory from 0 to 10
+func rerankLLM(query string, ranked []Reranked, explain bool) error {
+	var list strings.Builder
+	for i, r := range ranked {
+		fmt.Fprintf(&list, "%d. [%s] %s\n", i+1, r.Memory.Type, r.Memory.Content)
+	}
+	format := "N: score"
+	if explain {
+		format = "N: score - one short sentence on why"
+	}
+
+	prompt := fmt.Sprintf(`You are ranking stored memories by how useful they are for a search query.
+
+Query: %s
+
+Memories:
+%s
+Rate each numbered memory's relevance to the query from 0 (unrelated) to 10 (answers it directly). Judge what the memory says, not shared words. Answer with one line per memory in this exact format and nothing else:
+%s`, query, list.String(), format)
+
+	result, err := CallLLM(prompt)
+	if err != nil {
+		return err
+	}
+	for _, m := range rerankLine.FindAllStringSubmatch(result, -1) {
+		n, err := strconv.Atoi(m[1])
+		if err != nil || n < 1 || n > len(ranked) {
+			continue
+		}
+		score, _ := strconv.ParseFloat(m[2], 64)
+		ranked[n-1].Score = min(score, 10) / 10
+		ranked[n-1].Reason = strings.TrimSpace(m[3])
+	}
+	return nil
+}
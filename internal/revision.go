@@ -0,0 +1,201 @@
+package internal
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Revision is one saved state of a memory's content and tags
+type Revision struct {
+	Rev     int      `json:"rev" yaml:"rev"`
+	Content string   `json:"content" yaml:"content"`
+	Tags    []string `json:"tags" yaml:"tags"`
+	Time    string   `json:"time" yaml:"time"`
+	Actor   string   `json:"actor,omitempty" yaml:"actor,omitempty"`
+	Reason  string   `json:"reason,omitempty" yaml:"reason,omitempty"`
+}
+
+// Revise applies a change to a stored memory and records it in the audit
+// log under action, and as a new revision when the content or tags changed.
+// Memories get their first revision (the state they were created in) the
+// first time they change, so unchanged ones carry no history.
+func Revise(s Store, id, action, reason string, change func(m *Memory) error) (*Memory, error) {
+	m, err := s.Peek(id)
+	if err != nil {
+		return nil, err
+	}
+	before := *m
+	before.Tags = slices.Clone(m.Tags)
+	before.Anchors = slices.Clone(m.Anchors)
+	before.Revisions = slices.Clone(m.Revisions)
+	if err := change(m); err != nil {
+		return nil, err
+	}
+	journal.snapshot(s, &before, m.Content != before.Content)
+	if m.Content != before.Content || !slices.Equal(m.Tags, before.Tags) {
+		if len(m.Revisions) == 0 {
+			m.Revisions = []Revision{{
+				Rev:     1,
+				Content: before.Content,
+				Tags:    slices.Clone(before.Tags),
+				Time:    before.Created,
+				Reason:  "created",
+			}}
+		}
+		m.Revisions = append(m.Revisions, Revision{
+			Rev:     m.Revisions[len(m.Revisions)-1].Rev + 1,
+			Content: m.Content,
+			Tags:    slices.Clone(m.Tags),
+			Time:    Now(),
+			Actor:   Actor(),
+			Reason:  reason,
+		})
+	}
+
+	doc, err := memoryToDocument(m)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.PutDocument(id, doc); err != nil {
+		return nil, err
+	}
+	if err := auditMemory(s, action, id, &before, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// updateContent and addTag implement Store.Update and Store.AddTag for
+// every backend, so both leave a revision behind
+func updateContent(s Store, id, content string) error {
+	_, err := Revise(s, id, "update", "update", func(m *Memory) error {
+		m.Content = content
+		return nil
+	})
+	return err
+}
+
+func addTag(s Store, id, tag string) error {
+	_, err := Revise(s, id, "tag", "tag "+tag, func(m *Memory) error {
+		if hasTag(m.Tags, tag) {
+			return fmt.Errorf("tag already exists: %s", tag)
+		}
+		m.Tags = append(m.Tags, tag)
+		return nil
+	})
+	return err
+}
+
+// MergeMemories folds other into the memory id as one revision: id takes
+// the merged content plus any tags and anchors only other had. The caller
+// forgets other.
+func MergeMemories(s Store, id string, other *Memory, content string) (*Memory, error) {
+	return Revise(s, id, "merge", "merge "+other.ID, func(m *Memory) error {
+		m.Content = content
+		for _, tag := range other.Tags {
+			if !hasTag(m.Tags, tag) {
+				m.Tags = append(m.Tags, tag)
+			}
+		}
+		for _, a := range other.Anchors {
+			if !hasAnchor(m.Anchors, a) {
+				m.Anchors = append(m.Anchors, a)
+			}
+		}
+		return nil
+	})
+}
+
+// FindRevision returns a memory's revision by number
+func FindRevision(m *Memory, rev int) (*Revision, error) {
+	for i := range m.Revisions {
+		if m.Revisions[i].Rev == rev {
+			return &m.Revisions[i], nil
+		}
+	}
+	if len(m.Revisions) == 0 {
+		return nil, fmt.Errorf("memory %s has no revisions (it has not changed since it was created)", m.ID)
+	}
+	return nil, fmt.Errorf("memory %s has no revision %d (1-%d)", m.ID, rev, m.Revisions[len(m.Revisions)-1].Rev)
+}
+
+// RevertMemory restores the content and tags of an earlier revision,
+// recorded as a new revision
+func RevertMemory(s Store, id string, rev int) (*Memory, error) {
+	return Revise(s, id, "revert", fmt.Sprintf("revert to %d", rev), func(m *Memory) error {
+		r, err := FindRevision(m, rev)
+		if err != nil {
+			return err
+		}
+		m.Content = r.Content
+		m.Tags = slices.Clone(r.Tags)
+		return nil
+	})
+}
+
+// WordDiff marks the words removed from a as [-...-] and those added in b
+// as {+...+}, like git diff --word-diff
+func WordDiff(a, b string) string {
+	x, y := strings.Fields(a), strings.Fields(b)
+
+	// lcs[i][j] is the longest common subsequence of x[i:] and y[j:]
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var out, removed, added []string
+	flush := func() {
+		if len(removed) > 0 {
+			out = append(out, "[-"+strings.Join(removed, " ")+"-]")
+		}
+		if len(added) > 0 {
+			out = append(out, "{+"+strings.Join(added, " ")+"+}")
+		}
+		removed, added = nil, nil
+	}
+	i, j := 0, 0
+	for i < len(x) || j < len(y) {
+		switch {
+		case i < len(x) && j < len(y) && x[i] == y[j]:
+			flush()
+			out = append(out, x[i])
+			i++
+			j++
+		case j == len(y) || (i < len(x) && lcs[i+1][j] >= lcs[i][j+1]):
+			removed = append(removed, x[i])
+			i++
+		default:
+			added = append(added, y[j])
+			j++
+		}
+	}
+	flush()
+	return strings.Join(out, " ")
+}
+
+// TagDiff lists tags dropped (-tag) and gained (+tag) between two revisions
+func TagDiff(a, b []string) []string {
+	var diff []string
+	for _, tag := range a {
+		if !hasTag(b, tag) {
+			diff = append(diff, "-"+tag)
+		}
+	}
+	for _, tag := range b {
+		if !hasTag(a, tag) {
+			diff = append(diff, "+"+tag)
+		}
+	}
+	return diff
+}
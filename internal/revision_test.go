@@ -0,0 +1,98 @@
+package internal
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWordDiff(t *testing.T) {
+	tests := []struct {
+		a, b, want string
+	}{
+		{"same words", "same words", "same words"},
+		{"builds take five minutes", "builds take two minutes", "builds take [-five-] {+two+} minutes"},
+		{"use npm", "use npm and pnpm", "use npm {+and pnpm+}"},
+		{"drop these words here", "here", "[-drop these words-] here"},
+		{"", "all new", "{+all new+}"},
+		{"all gone", "", "[-all gone-]"},
+		{"a  b\nc", "a b c", "a b c"},
+		{"x y z", "z y x", "[-x y-] z {+y x+}"},
+	}
+	for _, tt := range tests {
+		if got := WordDiff(tt.a, tt.b); got != tt.want {
+			t.Errorf("WordDiff(%q, %q) = %q, want %q", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestTagDiff(t *testing.T) {
+	got := TagDiff([]string{"project:x", "old", "kept"}, []string{"project:x", "kept", "new"})
+	if want := []string{"-old", "+new"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("TagDiff() = %v, want %v", got, want)
+	}
+	if got := TagDiff([]string{"a"}, []string{"a"}); len(got) != 0 {
+		t.Errorf("TagDiff of equal tags = %v", got)
+	}
+}
+
+func TestRevise(t *testing.T) {
+	chdir(t, t.TempDir()) // verify records no git state outside a repo
+	s, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	memo := &Memory{Type: "fact", Content: "Builds take five minutes", Tags: []string{"ci"}}
+	if err := s.Remember(memo); err != nil {
+		t.Fatal(err)
+	}
+	revs := func() []int {
+		t.Helper()
+		m, err := s.Peek(memo.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var revs []int
+		for _, r := range m.Revisions {
+			revs = append(revs, r.Rev)
+		}
+		return revs
+	}
+
+	if err := VerifyMemory(s, memo.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got := revs(); got != nil {
+		t.Errorf("verify on an unchanged memory left revisions %v", got)
+	}
+	if m, _ := s.Peek(memo.ID); m.Verified == "" {
+		t.Error("verify did not record when")
+	}
+
+	if err := s.Update(memo.ID, "Builds take two minutes"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddTag(memo.ID, "speed"); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyMemory(s, memo.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := revs(), []int{1, 2, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("revisions %v, want %v (created, update, tag; not verify)", got, want)
+	}
+
+	m, err := RevertMemory(s, memo.ID, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Content != "Builds take five minutes" || !reflect.DeepEqual(m.Tags, []string{"ci"}) {
+		t.Errorf("revert to 1 gave %q %v", m.Content, m.Tags)
+	}
+	last := m.Revisions[len(m.Revisions)-1]
+	if last.Rev != 4 || last.Reason != "revert to 1" || last.Content != m.Content {
+		t.Errorf("revert recorded %+v", last)
+	}
+	if _, err := FindRevision(m, 9); err == nil {
+		t.Error("FindRevision found a revision that does not exist")
+	}
+}
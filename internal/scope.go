@@ -0,0 +1,170 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Scope kinds, nearest first. A memory's scope is recorded in its tags:
+// dir memories carry project:KEY and dir:PATH, branch memories project:KEY
+// and branch:NAME (possibly with dir:PATH too), project memories project:KEY,
+// org memories org:ORG, user memories user:NAME and global ones scope:global.
+const (
+	ScopeDir     = "dir"
+	ScopeBranch  = "branch"
+	ScopeProject = "project"
+	ScopeOrg     = "org"
+	ScopeUser    = "user"
+	ScopeGlobal  = "global"
+)
+
+// ScopeKinds lists the scope kinds nearest first
+var ScopeKinds = []string{ScopeDir, ScopeBranch, ScopeProject, ScopeOrg, ScopeUser, ScopeGlobal}
+
+// scopeBoost is added to a similarity score per scope level nearer than
+// global, so a close match from further out still beats a weak nearby one
+const scopeBoost = 0.02
+
+// ScopeChain holds every scope visible from the working directory
+type ScopeChain struct {
+	Project *Project
+	// Dir is the working directory relative to the repo root ("." at the root)
+	Dir string
+	// Org is the owner part of a remote-derived project key (e.g. github.com/acme)
+	Org string
+	// User identifies the person running memo
+	User string
+	// Branch is the checked-out git branch ("HEAD" when detached, "" outside git)
+	Branch string
+}
+
+// CurrentScopes resolves the scope chain for the working directory
+func CurrentScopes(s Store) (*ScopeChain, error) {
+	p, err := ResolveProject(s, "")
+	if err != nil {
+		return nil, err
+	}
+
+	c := &ScopeChain{Project: p, Dir: ".", User: currentUser()}
+	if p.Source == ProjectSourceRemote {
+		if i := strings.LastIndex(p.Key, "/"); i > 0 {
+			c.Org = p.Key[:i]
+		}
+	}
+	if prefix, err := gitOutput("rev-parse", "--show-prefix"); err == nil && prefix != "" {
+		c.Dir = strings.TrimSuffix(prefix, "/")
+	}
+	if p.Source != ProjectSourcePath {
+		c.Branch = CurrentBranch()
+	}
+	return c, nil
+}
+
+// currentUser returns the git email, else $USER
+func currentUser() string {
+	if email, err := gitOutput("config", "user.email"); err == nil && email != "" {
+		return email
+	}
+	return os.Getenv("USER")
+}
+
+// Filter matches every memory visible through the chain
+func (c *ScopeChain) Filter() Filter {
+	var tags []string
+	for _, name := range c.Project.Names() {
+		tags = append(tags, "project:"+name)
+	}
+	if c.Org != "" {
+		tags = append(tags, "org:"+c.Org)
+	}
+	if c.User != "" {
+		tags = append(tags, "user:"+c.User)
+	}
+	tags = append(tags, "scope:global")
+	return Filter{Tag: strings.Join(tags, "|"), InDir: c.Dir, OnBranch: c.Branch}
+}
+
+// Placement returns the project and extra tags a new memory needs to be
+// stored at the given scope
+func (c *ScopeChain) Placement(kind string) (string, []string, error) {
+	switch kind {
+	case ScopeDir:
+		if c.Dir == "." {
+			return c.Project.Key, nil, nil
+		}
+		return c.Project.Key, []string{"dir:" + c.Dir}, nil
+	case ScopeBranch:
+		if c.Branch == "" || c.Branch == "HEAD" {
+			return "", nil, fmt.Errorf("no branch scope: not on a git branch")
+		}
+		return c.Project.Key, []string{"branch:" + c.Branch}, nil
+	case ScopeProject, "":
+		return c.Project.Key, nil, nil
+	case ScopeOrg:
+		if c.Org == "" {
+			return "", nil, fmt.Errorf("no org scope: project %s has no origin remote", c.Project.Name)
+		}
+		return "", []string{"org:" + c.Org}, nil
+	case ScopeUser:
+		if c.User == "" {
+			return "", nil, fmt.Errorf("no user scope: set git user.email or $USER")
+		}
+		return "", []string{"user:" + c.User}, nil
+	case ScopeGlobal:
+		return "", []string{"scope:global"}, nil
+	}
+	return "", nil, fmt.Errorf("unknown scope: %s (use %s)", kind, strings.Join(ScopeKinds, ", "))
+}
+
+// ScopeOf returns the nearest scope a memory was stored at. Memories
+// without any scope tag predate scopes and count as global.
+func ScopeOf(m Memory) string {
+	has := make(map[string]bool)
+	if m.Project != "" {
+		has[ScopeProject] = true
+	}
+	for _, tag := range m.Tags {
+		if kind, _, ok := strings.Cut(tag, ":"); ok {
+			has[kind] = true
+		}
+	}
+	for _, kind := range ScopeKinds[:len(ScopeKinds)-1] {
+		if has[kind] {
+			return kind
+		}
+	}
+	return ScopeGlobal
+}
+
+// scopeDistance is 0 for dir memories and grows towards global
+func scopeDistance(m Memory) int {
+	kind := ScopeOf(m)
+	for i, k := range ScopeKinds {
+		if k == kind {
+			return i
+		}
+	}
+	return len(ScopeKinds) - 1
+}
+
+// SortByScope orders memories nearest scope first, keeping the existing
+// order within a scope
+func SortByScope(memos []Memory) {
+	sort.SliceStable(memos, func(i, j int) bool {
+		return scopeDistance(memos[i]) < scopeDistance(memos[j])
+	})
+}
+
+// RankByScope boosts similarity scores of nearer scopes and re-sorts
+func RankByScope(results []SimilarResult) {
+	boost := func(r SimilarResult) float64 {
+		var score float64
+		fmt.Sscanf(r.Score, "%f", &score)
+		return score + scopeBoost*float64(len(ScopeKinds)-1-scopeDistance(r.Memory))
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return boost(results[i]) > boost(results[j])
+	})
+}
@@ -0,0 +1,147 @@
+package internal
+
+import (
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Helpers for backends that filter and rank memories in-process
+// instead of delegating to RediSearch and vector sets.
+
+// hasTag reports whether tags contains tag exactly
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesTagFilter applies a RediSearch-style tag filter ("a|b*") to tags.
+// Alternatives are separated by "|" and a trailing "*" matches as a prefix.
+func matchesTagFilter(tags []string, filter string) bool {
+	for _, alt := range strings.Split(filter, "|") {
+		alt = strings.TrimSpace(alt)
+		if alt == "" {
+			continue
+		}
+		for _, t := range tags {
+			if strings.HasSuffix(alt, "*") {
+				if strings.HasPrefix(t, strings.TrimSuffix(alt, "*")) {
+					return true
+				}
+			} else if t == alt {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// cosineScore returns the similarity of two vectors on the same 0..1 scale
+// VSIM uses, so dedup thresholds mean the same thing on every backend
+func cosineScore(a, b []float64) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += a[i] * b[i]
+		na += a[i] * a[i]
+		nb += b[i] * b[i]
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return (1 + dot/(math.Sqrt(na)*math.Sqrt(nb))) / 2
+}
+
+// scoredID is a candidate from a brute-force vector scan
+type scoredID struct {
+	id    string
+	score float64
+}
+
+// topScores sorts candidates by descending score and keeps at most n
+func topScores(items []scoredID, n int) []scoredID {
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].score > items[j].score
+	})
+	if len(items) > n {
+		items = items[:n]
+	}
+	return items
+}
+
+// bm25 ranks documents (ID to terms) containing any query term, best
+// first. The term index keeps each word once per memory, so every term
+// frequency is 1 and only rarity and document length tell matches apart.
+func bm25(docs map[string][]string, query []string) []scoredID {
+	const k1, b = 1.2, 0.75
+	if len(docs) == 0 {
+		return nil
+	}
+	query = slices.Compact(slices.Sorted(slices.Values(query)))
+	total := 0
+	df := make(map[string]int)
+	for _, terms := range docs {
+		total += len(terms)
+		for _, q := range query {
+			if slices.Contains(terms, q) {
+				df[q]++
+			}
+		}
+	}
+	avgLen := float64(total) / float64(len(docs))
+	n := float64(len(docs))
+
+	var items []scoredID
+	for id, terms := range docs {
+		var score float64
+		for q, count := range df {
+			if !slices.Contains(terms, q) {
+				continue
+			}
+			idf := math.Log(1 + (n-float64(count)+0.5)/(float64(count)+0.5))
+			score += idf * (k1 + 1) / (1 + k1*(1-b+b*float64(len(terms))/avgLen))
+		}
+		if score > 0 {
+			items = append(items, scoredID{id, score})
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].score != items[j].score {
+			return items[i].score > items[j].score
+		}
+		return items[i].id < items[j].id
+	})
+	return items
+}
+
+// tokenize lowercases text and splits it into words for the term index
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// queryTerms splits a search query into lowercase terms, keeping a
+// trailing "*" on terms meant as prefixes
+func queryTerms(query string) []string {
+	var terms []string
+	for _, field := range strings.Fields(query) {
+		words := tokenize(field)
+		if len(words) == 0 {
+			continue
+		}
+		if strings.HasSuffix(field, "*") {
+			words[len(words)-1] += "*"
+		}
+		terms = append(terms, words...)
+	}
+	return terms
+}
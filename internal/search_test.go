@@ -0,0 +1,84 @@
+package internal
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBM25(t *testing.T) {
+	docs := map[string][]string{
+		"short": {"redis", "port"},
+		"long":  {"redis", "port", "is", "set", "in", "the", "compose", "file"},
+		"rare":  {"sentinel", "failover", "config"},
+		"none":  {"unrelated"},
+	}
+	ids := func(items []scoredID) []string {
+		var ids []string
+		for _, it := range items {
+			ids = append(ids, it.id)
+		}
+		return ids
+	}
+
+	tests := []struct {
+		name  string
+		query []string
+		want  []string
+	}{
+		{"shorter documents rank first", []string{"redis"}, []string{"short", "long"}},
+		{"rarer terms weigh more", []string{"port", "failover"}, []string{"rare", "short", "long"}},
+		{"repeated query terms count once", []string{"redis", "redis", "redis", "sentinel"}, []string{"rare", "short", "long"}},
+		{"no match", []string{"postgres"}, nil},
+		{"empty query", nil, nil},
+	}
+	for _, tt := range tests {
+		if got := ids(bm25(docs, tt.query)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: bm25(%v) = %v, want %v", tt.name, tt.query, got, tt.want)
+		}
+	}
+	if got := bm25(nil, []string{"redis"}); got != nil {
+		t.Errorf("bm25 over no documents = %v", got)
+	}
+
+	// Ties go by ID, so results are stable
+	tied := map[string][]string{"b": {"x"}, "a": {"x"}, "c": {"x"}}
+	if got := ids(bm25(tied, []string{"x"})); !reflect.DeepEqual(got, []string{"a", "b", "c"}) {
+		t.Errorf("tied documents in order %v", got)
+	}
+}
+
+func TestQueryTerms(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"Redis PORT", []string{"redis", "port"}},
+		{"ERR_QUOTA_7", []string{"err", "quota", "7"}},
+		{"conf* file", []string{"conf*", "file"}},
+		{"foo.bar*", []string{"foo", "bar*"}},
+		{"  -- ", nil},
+	}
+	for _, tt := range tests {
+		if got := queryTerms(tt.query); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("queryTerms(%q) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestCosineScore(t *testing.T) {
+	tests := []struct {
+		a, b []float64
+		want float64
+	}{
+		{[]float64{1, 0}, []float64{2, 0}, 1},
+		{[]float64{1, 0}, []float64{0, 1}, 0.5},
+		{[]float64{1, 0}, []float64{-1, 0}, 0},
+		{[]float64{1, 0}, []float64{1, 0, 0}, 0},
+		{[]float64{0, 0}, []float64{1, 0}, 0},
+	}
+	for _, tt := range tests {
+		if got := cosineScore(tt.a, tt.b); got != tt.want {
+			t.Errorf("cosineScore(%v, %v) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
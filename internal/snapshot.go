@@ -0,0 +1,435 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Dump is everything a snapshot keeps: memory documents, their vectors,
+// briefs with their stale flags, aliases and the schema version
+type Dump struct {
+	SchemaVersion int
+	Docs          map[string]map[string]interface{}
+	Embeddings    map[string][]float64
+	// Briefs and StaleBriefs are keyed by project; a project may have a
+	// stale flag and an empty brief
+	Briefs      map[string]string
+	StaleBriefs map[string]bool
+	Aliases     map[string]string
+}
+
+func newDump() *Dump {
+	return &Dump{
+		Docs:        make(map[string]map[string]interface{}),
+		Embeddings:  make(map[string][]float64),
+		Briefs:      make(map[string]string),
+		StaleBriefs: make(map[string]bool),
+		Aliases:     make(map[string]string),
+	}
+}
+
+// memoryIDs returns the sorted IDs of the dump's memories, only those
+// filed under project p unless p is nil
+func (d *Dump) memoryIDs(p *Project) ([]string, error) {
+	ids := slices.Sorted(maps.Keys(d.Docs))
+	if p == nil {
+		return ids, nil
+	}
+	filter := Filter{Projects: p.Names()}
+	var found []string
+	for _, id := range ids {
+		m, err := d.memory(id)
+		if err != nil {
+			return nil, err
+		}
+		if filter.Matches(*m) {
+			found = append(found, id)
+		}
+	}
+	return found, nil
+}
+
+func (d *Dump) memory(id string) (*Memory, error) {
+	m, err := documentToMemory(d.Docs[id])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", id, err)
+	}
+	m.ID = id
+	return m, nil
+}
+
+// upgrade applies the migrations a dump from an older schema is missing
+func (d *Dump) upgrade() error {
+	if d.SchemaVersion > SchemaVersion {
+		return fmt.Errorf("snapshot has schema version %d, newer than this binary (%d)", d.SchemaVersion, SchemaVersion)
+	}
+	for id, doc := range d.Docs {
+		for _, m := range migrations[d.SchemaVersion:] {
+			if err := m.Apply(doc); err != nil {
+				return fmt.Errorf("migration v%d on %s: %w", m.Version, id, err)
+			}
+		}
+	}
+	d.SchemaVersion = SchemaVersion
+	return nil
+}
+
+// inScope reports whether a brief's project belongs to p (every project when p is nil)
+func inScope(p *Project, project string) bool {
+	return p == nil || slices.Contains(p.Names(), project)
+}
+
+// SnapshotInfo describes a stored snapshot
+type SnapshotInfo struct {
+	Name     string
+	Created  string
+	Memories int
+	Size     int64
+	Path     string
+}
+
+var snapshotName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// snapshotDir holds the current profile's snapshots, one export file each
+func snapshotDir() string {
+	return dataPath(filepath.Join("snapshots", settings.Profile))
+}
+
+func snapshotPath(name string) (string, error) {
+	if !snapshotName.MatchString(name) {
+		return "", fmt.Errorf("invalid snapshot name %q (letters, digits, '.', '_' and '-')", name)
+	}
+	return filepath.Join(snapshotDir(), name+".jsonl"), nil
+}
+
+// CreateSnapshot copies the whole store into a new snapshot. It is
+// written in the export format, so memo import can also read it.
+func CreateSnapshot(s Store, name string) (*SnapshotInfo, error) {
+	path, err := snapshotPath(name)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(path); err == nil {
+		return nil, fmt.Errorf("snapshot %s already exists (memo snapshot delete %s first)", name, name)
+	}
+
+	d, err := s.Dump()
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	report, err := writeDump(&buf, d, nil, ExportHeader{Snapshot: name})
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	if err := writeFileAtomic(path, buf.Bytes()); err != nil {
+		return nil, err
+	}
+	return &SnapshotInfo{Name: name, Created: Now(), Memories: report.Memories, Size: int64(buf.Len()), Path: path}, nil
+}
+
+// ListSnapshots returns the stored snapshots, oldest first
+func ListSnapshots() ([]SnapshotInfo, error) {
+	entries, err := os.ReadDir(snapshotDir())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshots []SnapshotInfo
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), ".jsonl")
+		if !ok || e.IsDir() {
+			continue
+		}
+		path := filepath.Join(snapshotDir(), e.Name())
+		header, err := readSnapshotHeader(path)
+		if err != nil {
+			continue
+		}
+		info := SnapshotInfo{Name: name, Created: header.Exported, Memories: header.Memories, Path: path}
+		if fi, err := e.Info(); err == nil {
+			info.Size = fi.Size()
+		}
+		snapshots = append(snapshots, info)
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].Created < snapshots[j].Created
+	})
+	return snapshots, nil
+}
+
+func readSnapshotHeader(path string) (*ExportHeader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return readHeader(exportScanner(f))
+}
+
+// LoadSnapshot reads a snapshot, upgraded to the current schema
+func LoadSnapshot(name string) (*Dump, error) {
+	path, err := snapshotPath(name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("no snapshot named %s (see memo snapshot list)", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	_, d, err := readDump(bufio.NewReader(f))
+	if err != nil {
+		return nil, fmt.Errorf("snapshot %s: %w", name, err)
+	}
+	return d, d.upgrade()
+}
+
+// DeleteSnapshot removes a snapshot
+func DeleteSnapshot(name string) error {
+	path, err := snapshotPath(name)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); os.IsNotExist(err) {
+		return fmt.Errorf("no snapshot named %s", name)
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+// MemoryChange is a memory as a snapshot has it and as it is now
+type MemoryChange struct {
+	Before, After Memory
+}
+
+// SnapshotDiff is what changed between a snapshot and the store. Only
+// what a memory says (type, content, tags, anchors) counts as a change,
+// not its access stats.
+type SnapshotDiff struct {
+	// Added were stored after the snapshot, Removed deleted since
+	Added, Removed []Memory
+	Changed        []MemoryChange
+	// Briefs lists the projects whose brief changed
+	Briefs []string
+}
+
+// Empty reports whether the store still matches the snapshot
+func (d *SnapshotDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0 && len(d.Briefs) == 0
+}
+
+// DiffSnapshot compares a snapshot with the store, all of it or only
+// project p's memories and briefs
+func DiffSnapshot(s Store, name string, p *Project) (*SnapshotDiff, error) {
+	snap, err := LoadSnapshot(name)
+	if err != nil {
+		return nil, err
+	}
+	cur, err := s.Dump()
+	if err != nil {
+		return nil, err
+	}
+	return diffDumps(snap, cur, p)
+}
+
+func diffDumps(snap, cur *Dump, p *Project) (*SnapshotDiff, error) {
+	diff := &SnapshotDiff{}
+	then, err := snap.memoryIDs(p)
+	if err != nil {
+		return nil, err
+	}
+	now, err := cur.memoryIDs(p)
+	if err != nil {
+		return nil, err
+	}
+
+	// A memory moved into or out of p since counts as changed, not added or removed
+	ids := make(map[string]bool)
+	for _, id := range append(then, now...) {
+		ids[id] = true
+	}
+	for _, id := range slices.Sorted(maps.Keys(ids)) {
+		_, inSnap := snap.Docs[id]
+		_, inCur := cur.Docs[id]
+		switch {
+		case !inCur:
+			before, err := snap.memory(id)
+			if err != nil {
+				return nil, err
+			}
+			diff.Removed = append(diff.Removed, *before)
+		case !inSnap:
+			after, err := cur.memory(id)
+			if err != nil {
+				return nil, err
+			}
+			diff.Added = append(diff.Added, *after)
+		default:
+			before, err := snap.memory(id)
+			if err != nil {
+				return nil, err
+			}
+			after, err := cur.memory(id)
+			if err != nil {
+				return nil, err
+			}
+			if hashMemory(before) != hashMemory(after) {
+				diff.Changed = append(diff.Changed, MemoryChange{Before: *before, After: *after})
+			}
+		}
+	}
+
+	projects := make(map[string]bool)
+	for project := range snap.Briefs {
+		projects[project] = true
+	}
+	for project := range cur.Briefs {
+		projects[project] = true
+	}
+	for _, project := range slices.Sorted(maps.Keys(projects)) {
+		if inScope(p, project) && snap.Briefs[project] != cur.Briefs[project] {
+			diff.Briefs = append(diff.Briefs, project)
+		}
+	}
+	return diff, nil
+}
+
+// RestoreReport counts what RestoreSnapshot changed
+type RestoreReport struct {
+	// Recreated were deleted since the snapshot, Reverted changed since,
+	// and Trashed stored since (they go to the trash)
+	Recreated, Reverted, Trashed int
+	Briefs, Aliases              int
+}
+
+// RestoreSnapshot rolls the store, or only project p, back to a snapshot.
+// Memory changes are journaled for memo undo and audited as "rollback";
+// memories stored since the snapshot are moved to the trash.
+func RestoreSnapshot(s Store, name string, p *Project) (*RestoreReport, error) {
+	snap, err := LoadSnapshot(name)
+	if err != nil {
+		return nil, err
+	}
+	cur, err := s.Dump()
+	if err != nil {
+		return nil, err
+	}
+	diff, err := diffDumps(snap, cur, p)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &RestoreReport{}
+	for _, m := range diff.Removed {
+		if err := s.PutDocument(m.ID, snap.Docs[m.ID]); err != nil {
+			return report, err
+		}
+		journal.created(m.ID)
+		if err := auditMemory(s, "rollback", m.ID, nil, &m); err != nil {
+			return report, err
+		}
+		// A trashed copy would otherwise come back a second time
+		s.DeleteTrash(m.ID)
+		if err := restoreEmbedding(s, m.ID, snap.Embeddings[m.ID]); err != nil {
+			return report, err
+		}
+		report.Recreated++
+	}
+	for _, c := range diff.Changed {
+		contentChanged := c.Before.Content != c.After.Content
+		journal.snapshot(s, &c.After, contentChanged)
+		if err := s.PutDocument(c.Before.ID, snap.Docs[c.Before.ID]); err != nil {
+			return report, err
+		}
+		if err := auditMemory(s, "rollback", c.Before.ID, &c.After, &c.Before); err != nil {
+			return report, err
+		}
+		if contentChanged {
+			if err := restoreEmbedding(s, c.Before.ID, snap.Embeddings[c.Before.ID]); err != nil {
+				return report, err
+			}
+		}
+		report.Reverted++
+	}
+	for _, m := range diff.Added {
+		if err := TrashMemory(s, m.ID); err != nil {
+			return report, err
+		}
+		report.Trashed++
+	}
+
+	for _, project := range diff.Briefs {
+		brief, ok := snap.Briefs[project]
+		if !ok {
+			if err := s.DeleteBrief(project); err != nil {
+				return report, err
+			}
+		} else if err := s.SetBrief(project, brief); err != nil {
+			return report, err
+		}
+		if err := AuditBrief(s, project, cur.Briefs[project], brief); err != nil {
+			return report, err
+		}
+		report.Briefs++
+	}
+	for project, stale := range snap.StaleBriefs {
+		if !inScope(p, project) {
+			continue
+		}
+		if stale {
+			s.MarkBriefStale(project)
+		} else {
+			s.MarkBriefFresh(project)
+		}
+	}
+
+	for _, alias := range slices.Sorted(maps.Keys(cur.Aliases)) {
+		if _, kept := snap.Aliases[alias]; !kept && (p == nil || cur.Aliases[alias] == p.Key) {
+			if err := s.DeleteProjectAlias(alias); err != nil {
+				return report, err
+			}
+			report.Aliases++
+		}
+	}
+	for _, alias := range slices.Sorted(maps.Keys(snap.Aliases)) {
+		key := snap.Aliases[alias]
+		if cur.Aliases[alias] != key && (p == nil || key == p.Key) {
+			if err := s.SetProjectAlias(alias, key); err != nil {
+				return report, err
+			}
+			report.Aliases++
+		}
+	}
+	return report, nil
+}
+
+// restoreEmbedding puts a snapshot's vector back; without one the current
+// vector no longer matches the content, so it is dropped for memo doctor
+// --fix to recompute
+func restoreEmbedding(s Store, id string, embedding []float64) error {
+	if len(embedding) == 0 {
+		s.DeleteEmbedding(id)
+		return nil
+	}
+	return s.EmbedMemory(id, embedding)
+}
@@ -0,0 +1,949 @@
+package internal
+
+import (
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// sqliteDriver is the database/sql driver name registered by modernc.org/sqlite
+const sqliteDriver = "sqlite"
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS memories (
+	id  TEXT PRIMARY KEY,
+	doc TEXT NOT NULL
+);
+CREATE VIRTUAL TABLE IF NOT EXISTS memories_fts USING fts5(id UNINDEXED, content);
+CREATE TABLE IF NOT EXISTS embeddings (
+	id  TEXT PRIMARY KEY,
+	vec BLOB NOT NULL
+);
+CREATE TABLE IF NOT EXISTS meta (
+	key   TEXT PRIMARY KEY,
+	value TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS project_aliases (
+	alias   TEXT PRIMARY KEY,
+	project TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS trash (
+	id      TEXT PRIMARY KEY,
+	deleted TEXT NOT NULL,
+	entry   TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS quarantine (
+	id          TEXT PRIMARY KEY,
+	quarantined TEXT NOT NULL,
+	doc         TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS audit_log (
+	seq   INTEGER PRIMARY KEY AUTOINCREMENT,
+	time  TEXT NOT NULL,
+	entry TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS audit_log_time ON audit_log (time);
+CREATE TABLE IF NOT EXISTS undo (
+	seq INTEGER PRIMARY KEY AUTOINCREMENT,
+	op  TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS briefs (
+	project TEXT PRIMARY KEY,
+	brief   TEXT NOT NULL DEFAULT '',
+	stale   INTEGER NOT NULL DEFAULT 1
+);
+`
+
+// validDoc is a memory's document, or NULL if it is not valid JSON, so one
+// damaged row does not fail every query (memo doctor reports it instead)
+const validDoc = `(CASE WHEN json_valid(memories.doc) THEN memories.doc END)`
+
+// SQLiteStore is the Store backed by a single SQLite file.
+// Memories are kept as the same JSON documents RedisStore writes,
+// FTS5 serves full-text search and embeddings are scanned brute-force.
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+var _ Store = (*SQLiteStore)(nil)
+
+// NewSQLiteStore opens (creating if needed) the database at path
+func NewSQLiteStore(path string) (*SQLiteStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open(sqliteDriver, path)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite backend unavailable (build with -tags sqlite): %w", err)
+	}
+	// SQLite serializes writers anyway; one connection avoids "database is locked"
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("sqlite schema: %w", err)
+	}
+	return &SQLiteStore{db: db}, nil
+}
+
+// Close closes the database
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+// Init rebuilds the full-text index from the stored documents
+func (s *SQLiteStore) Init() error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM memories_fts`); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`INSERT INTO memories_fts (id, content)
+		SELECT id, json_extract(doc, '$.content') FROM memories WHERE json_valid(doc)`)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Remember stores a new memory
+func (s *SQLiteStore) Remember(memo *Memory) error {
+	initMemory(memo)
+	return s.put(memo, true)
+}
+
+// put writes a memory document and keeps the FTS row in step
+func (s *SQLiteStore) put(memo *Memory, isNew bool) error {
+	doc, err := json.Marshal(memo)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if isNew {
+		_, err = tx.Exec(`INSERT INTO memories (id, doc) VALUES (?, ?)`, memo.ID, string(doc))
+	} else {
+		_, err = tx.Exec(`UPDATE memories SET doc = ? WHERE id = ?`, string(doc), memo.ID)
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM memories_fts WHERE id = ?`, memo.ID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`INSERT INTO memories_fts (id, content) VALUES (?, ?)`, memo.ID, memo.Content); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// getMemoryRaw retrieves a memory without updating access stats
+func (s *SQLiteStore) getMemoryRaw(id string) (*Memory, error) {
+	var doc string
+	err := s.db.QueryRow(`SELECT doc FROM memories WHERE id = ?`, id).Scan(&doc)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("memory not found: %s", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var memo Memory
+	if err := json.Unmarshal([]byte(doc), &memo); err != nil {
+		return nil, err
+	}
+	return &memo, nil
+}
+
+// Peek retrieves a memory without updating access stats
+func (s *SQLiteStore) Peek(id string) (*Memory, error) {
+	return s.getMemoryRaw(id)
+}
+
+// Get retrieves a specific memory and updates access stats
+func (s *SQLiteStore) Get(id string) (*Memory, error) {
+	memo, err := s.getMemoryRaw(id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Update access stats (the returned copy keeps the previous values, as in Redis)
+	s.db.Exec(`UPDATE memories SET doc = json_set(doc,
+		'$.access_count', json_extract(doc, '$.access_count') + 1,
+		'$.accessed', ?) WHERE id = ?`, Now(), id)
+
+	return memo, nil
+}
+
+// Update modifies a memory's content
+func (s *SQLiteStore) Update(id, content string) error {
+	return updateContent(s, id, content)
+}
+
+// AddTag adds a tag to an existing memory
+func (s *SQLiteStore) AddTag(id, tag string) error {
+	return addTag(s, id, tag)
+}
+
+// Forget deletes a memory along with its index row and embedding
+func (s *SQLiteStore) Forget(id string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`DELETE FROM memories WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("memory not found: %s", id)
+	}
+	if _, err := tx.Exec(`DELETE FROM memories_fts WHERE id = ?`, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM embeddings WHERE id = ?`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// queryMemories runs a query whose single column is a memory document
+func (s *SQLiteStore) queryMemories(query string, args ...interface{}) ([]Memory, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var memos []Memory
+	for rows.Next() {
+		var doc string
+		if err := rows.Scan(&doc); err != nil {
+			return nil, err
+		}
+		var memo Memory
+		if err := json.Unmarshal([]byte(doc), &memo); err != nil {
+			continue
+		}
+		memos = append(memos, memo)
+	}
+	return memos, rows.Err()
+}
+
+// Recall searches memories using an FTS5 query
+func (s *SQLiteStore) Recall(query string, limit int) ([]Memory, error) {
+	return s.queryMemories(`SELECT m.doc FROM memories_fts f
+		JOIN memories m ON m.id = f.id
+		WHERE memories_fts MATCH ? ORDER BY f.rank LIMIT ?`, query, limit)
+}
+
+// TextSearch matches every word of query as plain text
+func (s *SQLiteStore) TextSearch(query string, limit int) ([]Memory, error) {
+	var terms []string
+	for _, w := range strings.Fields(query) {
+		terms = append(terms, `"`+strings.ReplaceAll(w, `"`, `""`)+`"`)
+	}
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	return s.Recall(strings.Join(terms, " "), limit)
+}
+
+// KeywordSearch ORs the query's words and orders by FTS5's bm25 rank
+func (s *SQLiteStore) KeywordSearch(query string, limit int, f Filter) ([]Memory, error) {
+	var terms []string
+	for _, w := range tokenize(query) {
+		terms = append(terms, `"`+w+`"`)
+	}
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	fetchLimit := limit
+	if !f.empty() {
+		fetchLimit = -1 // every match, filtered in Go
+	}
+	memos, err := s.Recall(strings.Join(terms, " OR "), fetchLimit)
+	if err != nil {
+		return nil, err
+	}
+	var matched []Memory
+	for _, m := range memos {
+		if f.Matches(m) && len(matched) < limit {
+			matched = append(matched, m)
+		}
+	}
+	return matched, nil
+}
+
+// Iterate streams memories matching the filter in rowid order. Each batch is
+// a separate keyset query, so no cursor stays open between calls to Next.
+func (s *SQLiteStore) Iterate(f Filter) MemoryIterator {
+	var where []string
+	var args []interface{}
+	if f.Type != "" {
+		where = append(where, `json_extract(`+validDoc+`, '$.type') = ?`)
+		args = append(args, f.Type)
+	}
+	if len(f.Projects) > 0 {
+		// Narrow by tag in SQL; $.project is checked by f.Matches
+		marks := strings.TrimSuffix(strings.Repeat("?, ", len(f.Projects)), ", ")
+		where = append(where, `EXISTS (SELECT 1 FROM json_each(`+validDoc+`, '$.tags')
+			WHERE value IN (`+marks+`))`)
+		for _, p := range f.Projects {
+			args = append(args, "project:"+p)
+		}
+	}
+	query := `SELECT rowid, doc FROM memories WHERE rowid > ?`
+	for _, w := range where {
+		query += " AND " + w
+	}
+	query += ` ORDER BY rowid LIMIT ?`
+
+	var last int64
+	return &batchIterator{
+		filter: f.Matches, // tag patterns are matched in Go
+		fetch: func() ([]Memory, bool, error) {
+			params := append([]interface{}{last}, args...)
+			rows, err := s.db.Query(query, append(params, iterBatchSize)...)
+			if err != nil {
+				return nil, true, err
+			}
+			defer rows.Close()
+
+			var memos []Memory
+			n := 0
+			for rows.Next() {
+				var doc string
+				if err := rows.Scan(&last, &doc); err != nil {
+					return nil, true, err
+				}
+				n++
+				var memo Memory
+				if err := json.Unmarshal([]byte(doc), &memo); err != nil {
+					continue
+				}
+				memos = append(memos, memo)
+			}
+			return memos, n < iterBatchSize, rows.Err()
+		},
+	}
+}
+
+// Count returns the number of memories matching the filter
+func (s *SQLiteStore) Count(f Filter) (int, error) {
+	return countMatching(s.Iterate(f))
+}
+
+// GetAllMemoryIDs returns all memory IDs for reindexing
+func (s *SQLiteStore) GetAllMemoryIDs() ([]string, error) {
+	rows, err := s.db.Query(`SELECT id FROM memories ORDER BY rowid`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// Projects returns all projects with their memory counts
+func (s *SQLiteStore) Projects() (map[string]int, error) {
+	rows, err := s.db.Query(`SELECT substr(t.value, 9), COUNT(*)
+		FROM memories, json_each(` + validDoc + `, '$.tags') t
+		WHERE t.value LIKE 'project:_%'
+		GROUP BY t.value`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	projects := make(map[string]int)
+	for rows.Next() {
+		var name string
+		var count int
+		if err := rows.Scan(&name, &count); err != nil {
+			return nil, err
+		}
+		projects[name] = count
+	}
+	return projects, rows.Err()
+}
+
+// ProjectAliases returns every alias and the project key it resolves to
+func (s *SQLiteStore) ProjectAliases() (map[string]string, error) {
+	rows, err := s.db.Query(`SELECT alias, project FROM project_aliases`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	aliases := make(map[string]string)
+	for rows.Next() {
+		var alias, key string
+		if err := rows.Scan(&alias, &key); err != nil {
+			return nil, err
+		}
+		aliases[alias] = key
+	}
+	return aliases, rows.Err()
+}
+
+// SetProjectAlias makes alias resolve to a project key
+func (s *SQLiteStore) SetProjectAlias(alias, key string) error {
+	_, err := s.db.Exec(`INSERT INTO project_aliases (alias, project) VALUES (?, ?)
+		ON CONFLICT (alias) DO UPDATE SET project = excluded.project`, alias, key)
+	return err
+}
+
+// DeleteProjectAlias removes an alias
+func (s *SQLiteStore) DeleteProjectAlias(alias string) error {
+	_, err := s.db.Exec(`DELETE FROM project_aliases WHERE alias = ?`, alias)
+	return err
+}
+
+// Stats returns memory statistics
+func (s *SQLiteStore) Stats() (map[string]int, error) {
+	stats := map[string]int{"fact": 0, "context": 0, "learned": 0, "preference": 0}
+
+	rows, err := s.db.Query(`SELECT json_extract(` + validDoc + `, '$.type'), COUNT(*) FROM memories GROUP BY 1`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var t sql.NullString
+		var count int
+		if err := rows.Scan(&t, &count); err != nil {
+			return nil, err
+		}
+		if _, ok := stats[t.String]; ok {
+			stats[t.String] = count
+		}
+		stats["total"] += count
+	}
+	return stats, rows.Err()
+}
+
+// EmbedMemory stores a memory's embedding
+func (s *SQLiteStore) EmbedMemory(id string, embedding []float64) error {
+	_, err := s.db.Exec(`INSERT INTO embeddings (id, vec) VALUES (?, ?)
+		ON CONFLICT (id) DO UPDATE SET vec = excluded.vec`, id, encodeVector(embedding))
+	return err
+}
+
+// GetEmbeddingByID returns the stored embedding for a memory
+func (s *SQLiteStore) GetEmbeddingByID(id string) ([]float64, error) {
+	var blob []byte
+	err := s.db.QueryRow(`SELECT vec FROM embeddings WHERE id = ?`, id).Scan(&blob)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("no embedding for memory: %s", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeVector(blob), nil
+}
+
+// Similar finds semantically similar memories by scanning every embedding
+func (s *SQLiteStore) Similar(embedding []float64, limit int, f Filter) ([]SimilarResult, error) {
+	rows, err := s.db.Query(`SELECT e.id, e.vec, m.doc FROM embeddings e JOIN memories m ON m.id = e.id`)
+	if err != nil {
+		return nil, err
+	}
+	var items []scoredID
+	memos := make(map[string]Memory)
+	for rows.Next() {
+		var id, doc string
+		var blob []byte
+		if err := rows.Scan(&id, &blob, &doc); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		var memo Memory
+		if err := json.Unmarshal([]byte(doc), &memo); err != nil || !f.Matches(memo) {
+			continue
+		}
+		memos[id] = memo
+		items = append(items, scoredID{id, cosineScore(embedding, decodeVector(blob))})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var results []SimilarResult
+	for _, item := range topScores(items, limit) {
+		results = append(results, SimilarResult{
+			Memory: memos[item.id],
+			Score:  fmt.Sprintf("%.2f", item.score),
+		})
+	}
+	return results, nil
+}
+
+// DeleteEmbeddings removes all vectors for reindexing
+func (s *SQLiteStore) DeleteEmbeddings() error {
+	_, err := s.db.Exec(`DELETE FROM embeddings`)
+	return err
+}
+
+// DeleteEmbedding removes one memory's vector
+func (s *SQLiteStore) DeleteEmbedding(id string) error {
+	_, err := s.db.Exec(`DELETE FROM embeddings WHERE id = ?`, id)
+	return err
+}
+
+// EmbeddingDims returns the dimension of every stored embedding
+func (s *SQLiteStore) EmbeddingDims() (map[string]int, error) {
+	rows, err := s.db.Query(`SELECT id, length(vec) / 4 FROM embeddings`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	dims := make(map[string]int)
+	for rows.Next() {
+		var id string
+		var dim int
+		if err := rows.Scan(&id, &dim); err != nil {
+			return nil, err
+		}
+		dims[id] = dim
+	}
+	return dims, rows.Err()
+}
+
+// CheckIndex compares the FTS table against the stored documents
+func (s *SQLiteStore) CheckIndex() ([]string, error) {
+	var missing, stale int
+	err := s.db.QueryRow(`SELECT
+		(SELECT COUNT(*) FROM memories WHERE id NOT IN (SELECT id FROM memories_fts)),
+		(SELECT COUNT(*) FROM memories_fts WHERE id NOT IN (SELECT id FROM memories))`).Scan(&missing, &stale)
+	if err != nil {
+		return nil, err
+	}
+
+	var issues []string
+	if missing > 0 {
+		issues = append(issues, fmt.Sprintf("%d memories missing from the full-text index", missing))
+	}
+	if stale > 0 {
+		issues = append(issues, fmt.Sprintf("%d full-text rows for deleted memories", stale))
+	}
+	return issues, nil
+}
+
+// GetBrief returns the stored brief for a project
+func (s *SQLiteStore) GetBrief(project string) (string, error) {
+	var brief string
+	err := s.db.QueryRow(`SELECT brief FROM briefs WHERE project = ?`, project).Scan(&brief)
+	if err != nil {
+		return "", err
+	}
+	return brief, nil
+}
+
+// SetBrief stores a brief for a project
+func (s *SQLiteStore) SetBrief(project, brief string) error {
+	_, err := s.db.Exec(`INSERT INTO briefs (project, brief) VALUES (?, ?)
+		ON CONFLICT (project) DO UPDATE SET brief = excluded.brief`, project, brief)
+	return err
+}
+
+// IsBriefStale checks if the brief needs regeneration
+func (s *SQLiteStore) IsBriefStale(project string) bool {
+	var stale int
+	err := s.db.QueryRow(`SELECT stale FROM briefs WHERE project = ?`, project).Scan(&stale)
+	if err != nil {
+		return true // No row means never generated
+	}
+	return stale == 1
+}
+
+// MarkBriefStale marks a project's brief as needing regeneration
+func (s *SQLiteStore) MarkBriefStale(project string) {
+	s.setBriefStale(project, 1)
+}
+
+// MarkBriefFresh marks a project's brief as up to date
+func (s *SQLiteStore) MarkBriefFresh(project string) {
+	s.setBriefStale(project, 0)
+}
+
+// BriefProjects returns every project with a brief or stale flag
+func (s *SQLiteStore) BriefProjects() ([]string, error) {
+	rows, err := s.db.Query(`SELECT project FROM briefs`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var projects []string
+	for rows.Next() {
+		var project string
+		if err := rows.Scan(&project); err != nil {
+			return nil, err
+		}
+		projects = append(projects, project)
+	}
+	return projects, rows.Err()
+}
+
+// DeleteBrief removes a project's brief and stale flag
+func (s *SQLiteStore) DeleteBrief(project string) error {
+	_, err := s.db.Exec(`DELETE FROM briefs WHERE project = ?`, project)
+	return err
+}
+
+func (s *SQLiteStore) setBriefStale(project string, stale int) {
+	s.db.Exec(`INSERT INTO briefs (project, stale) VALUES (?, ?)
+		ON CONFLICT (project) DO UPDATE SET stale = excluded.stale`, project, stale)
+}
+
+// SchemaVersion returns the schema version stored in the meta table
+func (s *SQLiteStore) SchemaVersion() (int, error) {
+	var version int
+	err := s.db.QueryRow(`SELECT CAST(value AS INTEGER) FROM meta WHERE key = 'schema_version'`).Scan(&version)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return version, err
+}
+
+// SetSchemaVersion records the schema version in the meta table
+func (s *SQLiteStore) SetSchemaVersion(version int) error {
+	_, err := s.db.Exec(`INSERT INTO meta (key, value) VALUES ('schema_version', ?)
+		ON CONFLICT (key) DO UPDATE SET value = excluded.value`, fmt.Sprint(version))
+	return err
+}
+
+// Documents returns every memory document
+func (s *SQLiteStore) Documents() (map[string]map[string]interface{}, error) {
+	rows, err := s.db.Query(`SELECT id, doc FROM memories`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	docs := make(map[string]map[string]interface{})
+	for rows.Next() {
+		var id, raw string
+		if err := rows.Scan(&id, &raw); err != nil {
+			return nil, err
+		}
+		var doc map[string]interface{}
+		if err := json.Unmarshal([]byte(raw), &doc); err != nil {
+			continue
+		}
+		docs[id] = doc
+	}
+	return docs, rows.Err()
+}
+
+// Dump reads the database inside one read transaction
+func (s *SQLiteStore) Dump() (*Dump, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	d := newDump()
+	err = tx.QueryRow(`SELECT CAST(value AS INTEGER) FROM meta WHERE key = 'schema_version'`).Scan(&d.SchemaVersion)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	rows, err := tx.Query(`SELECT id, doc FROM memories`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var id, raw string
+		if err := rows.Scan(&id, &raw); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		var doc map[string]interface{}
+		if err := json.Unmarshal([]byte(raw), &doc); err == nil {
+			d.Docs[id] = doc
+		}
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err = tx.Query(`SELECT id, vec FROM embeddings`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var id string
+		var vec []byte
+		if err := rows.Scan(&id, &vec); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		d.Embeddings[id] = decodeVector(vec)
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err = tx.Query(`SELECT project, brief, stale FROM briefs`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var project, brief string
+		var stale int
+		if err := rows.Scan(&project, &brief, &stale); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		d.Briefs[project] = brief
+		d.StaleBriefs[project] = stale == 1
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err = tx.Query(`SELECT alias, project FROM project_aliases`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var alias, project string
+		if err := rows.Scan(&alias, &project); err != nil {
+			return nil, err
+		}
+		d.Aliases[alias] = project
+	}
+	return d, rows.Err()
+}
+
+// PutDocument writes a memory document and refreshes its FTS row
+func (s *SQLiteStore) PutDocument(id string, doc map[string]interface{}) error {
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	content, _ := doc["content"].(string)
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`INSERT INTO memories (id, doc) VALUES (?, ?)
+		ON CONFLICT (id) DO UPDATE SET doc = excluded.doc`, id, string(data))
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM memories_fts WHERE id = ?`, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`INSERT INTO memories_fts (id, content) VALUES (?, ?)`, id, content); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// PutTrash stores a trash entry
+func (s *SQLiteStore) PutTrash(e TrashEntry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`INSERT OR REPLACE INTO trash (id, deleted, entry) VALUES (?, ?, ?)`, e.ID, e.Deleted, string(data))
+	return err
+}
+
+// TrashEntries returns the trash, most recently deleted first
+func (s *SQLiteStore) TrashEntries() ([]TrashEntry, error) {
+	rows, err := s.db.Query(`SELECT id, entry FROM trash ORDER BY deleted DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []TrashEntry
+	for rows.Next() {
+		var id, data string
+		if err := rows.Scan(&id, &data); err != nil {
+			return nil, err
+		}
+		var e TrashEntry
+		if err := json.Unmarshal([]byte(data), &e); err != nil {
+			return nil, fmt.Errorf("corrupt trash entry %s: %w", id, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// DeleteTrash removes a trash entry
+func (s *SQLiteStore) DeleteTrash(id string) error {
+	_, err := s.db.Exec(`DELETE FROM trash WHERE id = ?`, id)
+	return err
+}
+
+// Quarantine moves a memory's raw document into the quarantine table
+func (s *SQLiteStore) Quarantine(id string) (string, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`INSERT OR REPLACE INTO quarantine (id, quarantined, doc)
+		SELECT id, ?, doc FROM memories WHERE id = ?`, Now(), id)
+	if err != nil {
+		return "", err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return "", fmt.Errorf("memory not found: %s", id)
+	}
+	for _, table := range []string{"memories", "memories_fts", "embeddings"} {
+		if _, err := tx.Exec(`DELETE FROM `+table+` WHERE id = ?`, id); err != nil {
+			return "", err
+		}
+	}
+	return "the quarantine table", tx.Commit()
+}
+
+// AppendLog adds an entry to the audit_log table
+func (s *SQLiteStore) AppendLog(e LogEntry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`INSERT INTO audit_log (time, entry) VALUES (?, ?)`, e.Time, string(data))
+	return err
+}
+
+// ReadLog returns matching audit log entries, oldest first
+func (s *SQLiteStore) ReadLog(q LogQuery) ([]LogEntry, error) {
+	query := `SELECT entry FROM audit_log WHERE time >= ?`
+	args := []interface{}{q.Since}
+	if q.Until != "" {
+		query += ` AND time <= ?`
+		args = append(args, q.Until)
+	}
+	rows, err := s.db.Query(query+` ORDER BY seq`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []LogEntry
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var e LogEntry
+		if err := json.Unmarshal([]byte(data), &e); err != nil {
+			return nil, err
+		}
+		if q.Matches(e) {
+			entries = append(entries, e)
+		}
+	}
+	return entries, rows.Err()
+}
+
+// PushUndo appends an operation to the undo table and drops the oldest
+// beyond undoDepth
+func (s *SQLiteStore) PushUndo(op *Operation) error {
+	data, err := json.Marshal(op)
+	if err != nil {
+		return err
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`INSERT INTO undo (op) VALUES (?)`, string(data)); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`DELETE FROM undo WHERE seq NOT IN (SELECT seq FROM undo ORDER BY seq DESC LIMIT ?)`, undoDepth)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// PopUndo removes and returns the newest operation
+func (s *SQLiteStore) PopUndo() (*Operation, error) {
+	var seq int64
+	var data string
+	err := s.db.QueryRow(`SELECT seq, op FROM undo ORDER BY seq DESC LIMIT 1`).Scan(&seq, &data)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.db.Exec(`DELETE FROM undo WHERE seq = ?`, seq); err != nil {
+		return nil, err
+	}
+	var op Operation
+	if err := json.Unmarshal([]byte(data), &op); err != nil {
+		return nil, err
+	}
+	return &op, nil
+}
+
+// encodeVector packs an embedding as little-endian float32s
+func encodeVector(v []float64) []byte {
+	buf := make([]byte, 4*len(v))
+	for i, f := range v {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(float32(f)))
+	}
+	return buf
+}
+
+// decodeVector unpacks an embedding written by encodeVector
+func decodeVector(buf []byte) []float64 {
+	v := make([]float64, len(buf)/4)
+	for i := range v {
+		v[i] = float64(math.Float32frombits(binary.LittleEndian.Uint32(buf[4*i:])))
+	}
+	return v
+}
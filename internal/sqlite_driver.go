@@ -0,0 +1,8 @@
+//go:build sqlite
+
+package internal
+
+// The SQLite backend is opt-in so the default build stays free of the driver:
+//
+//	make build-sqlite
+import _ "modernc.org/sqlite"
@@ -0,0 +1,19 @@
+//go:build sqlite
+
+package internal
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestSQLiteStoreScenarios(t *testing.T) {
+	testStore(t, func(t *testing.T) Store {
+		s, err := NewSQLiteStore(filepath.Join(t.TempDir(), "memo.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { s.Close() })
+		return s
+	})
+}
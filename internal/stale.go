@@ -0,0 +1,202 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// StaleFile describes how an anchored file changed since its memory was
+// stored or last verified
+type StaleFile struct {
+	Anchor  Anchor
+	Deleted bool
+	// Added and Removed are the diffstat line counts against the file as recorded
+	Added, Removed int
+	// SymbolGone is set when the anchored symbol no longer appears in the file
+	SymbolGone bool
+}
+
+// StaleMemory is a memory with the anchored files that changed under it
+type StaleMemory struct {
+	Memory Memory
+	Files  []StaleFile
+}
+
+// pathToken finds words in memory content that look like file paths
+var pathToken = regexp.MustCompile(`[\w.\-]*[\w\-]+(?:/[\w.\-]+)*\.\w+|[\w.\-]+(?:/[\w.\-]+)+`)
+
+// RecordGitState stamps a memory with the current HEAD and the blob hash of
+// every file it is anchored to, writing the blobs to the object database so
+// later diffs start from the file as it was, uncommitted edits included.
+// Existing repo files mentioned in the content become anchors too. Outside
+// a git repo it does nothing.
+func RecordGitState(m *Memory) {
+	root, err := gitOutput("rev-parse", "--show-toplevel")
+	if err != nil {
+		return
+	}
+	if head, err := gitOutput("rev-parse", "HEAD"); err == nil {
+		m.Commit = head
+	}
+
+	anchored := AnchorPaths(*m)
+	for _, path := range mentionedFiles(root, m.Content) {
+		if !slices.Contains(anchored, path) {
+			m.Anchors = append(m.Anchors, Anchor{Path: path})
+		}
+	}
+	for i := range m.Anchors {
+		m.Anchors[i].Blob = hashFile(root, m.Anchors[i].Path, true)
+	}
+}
+
+// mentionedFiles returns the repo files named in text, as repo paths.
+// A name may be relative to the repo root or to the working directory.
+func mentionedFiles(root, text string) []string {
+	cwd, _ := os.Getwd()
+	var paths []string
+	seen := make(map[string]bool)
+	for _, token := range pathToken.FindAllString(text, -1) {
+		candidates := []string{filepath.Join(root, token)}
+		if cwd != "" {
+			candidates = append(candidates, filepath.Join(cwd, token))
+		}
+		for _, abs := range candidates {
+			rel, err := filepath.Rel(root, abs)
+			if err != nil || strings.HasPrefix(rel, "..") {
+				continue
+			}
+			path := filepath.ToSlash(rel)
+			if info, err := os.Stat(abs); err == nil && !info.IsDir() && !seen[path] {
+				seen[path] = true
+				paths = append(paths, path)
+				break
+			}
+		}
+	}
+	return paths
+}
+
+// hashFile returns the blob hash of a working tree file ("" if missing),
+// storing the blob in the object database if write is set
+func hashFile(root, path string, write bool) string {
+	args := []string{"-C", root, "hash-object"}
+	if write {
+		args = append(args, "-w")
+	}
+	hash, err := gitOutput(append(args, "--", path)...)
+	if err != nil {
+		return ""
+	}
+	return hash
+}
+
+// CheckStale compares a memory's anchors with the working tree. A file is
+// stale when it was deleted, lost its anchored symbol, or has at least
+// minLines lines changed since its blob was recorded. Memories stored before
+// anchors had blob hashes are never stale.
+func CheckStale(m Memory, minLines int) []StaleFile {
+	root, err := gitOutput("rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil
+	}
+
+	var stale []StaleFile
+	for _, a := range m.Anchors {
+		if a.Blob == "" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(a.Path)))
+		if err != nil {
+			stale = append(stale, StaleFile{Anchor: a, Deleted: true})
+			continue
+		}
+		if hashFile(root, a.Path, false) == a.Blob {
+			continue
+		}
+
+		f := StaleFile{Anchor: a}
+		if a.Symbol != "" && !strings.Contains(string(data), a.Symbol) {
+			f.SymbolGone = true
+		}
+		f.Added, f.Removed = fileChanges(root, m.Commit, a)
+		if f.SymbolGone || f.Added+f.Removed >= minLines {
+			stale = append(stale, f)
+		}
+	}
+	return stale
+}
+
+// fileChanges counts the lines added and removed in an anchored file since
+// its blob was recorded. Blobs recorded before memo wrote them to the object
+// database, or since pruned by git gc, fall back to diffing the recorded
+// commit against the working tree.
+func fileChanges(root, commit string, a Anchor) (int, int) {
+	if current := hashFile(root, a.Path, true); current != "" {
+		if out, err := gitOutput("-C", root, "diff", "--numstat", a.Blob, current); err == nil {
+			return parseNumstat(out)
+		}
+	}
+	if commit == "" {
+		return 0, 0
+	}
+	out, err := gitOutput("-C", root, "diff", "--numstat", commit, "--", a.Path)
+	if err != nil {
+		return 0, 0
+	}
+	return parseNumstat(out)
+}
+
+// parseNumstat reads the counts of one git diff --numstat line. Binary
+// files count as one change each way.
+func parseNumstat(out string) (int, int) {
+	if out == "" {
+		return 0, 0
+	}
+	fields := strings.Fields(out)
+	if len(fields) < 2 {
+		return 0, 0
+	}
+	added, err1 := strconv.Atoi(fields[0])
+	removed, err2 := strconv.Atoi(fields[1])
+	if err1 != nil || err2 != nil {
+		return 1, 1
+	}
+	return added, removed
+}
+
+// FindStale returns a project's anchored memories whose files changed
+// significantly since they were stored or last verified
+func FindStale(s Store, p *Project, minLines int) ([]StaleMemory, error) {
+	it := s.Iterate(Filter{Projects: p.Names()})
+	defer it.Close()
+
+	var stale []StaleMemory
+	for it.Next() {
+		m := it.Val()
+		if len(m.Anchors) == 0 {
+			continue
+		}
+		if files := CheckStale(m, minLines); len(files) > 0 {
+			stale = append(stale, StaleMemory{Memory: m, Files: files})
+		}
+	}
+	return stale, it.Err()
+}
+
+// VerifyMemory re-records a memory's git state, marking it as accurate
+// for the current code. It leaves the revision history alone because
+// Revise records revisions only when the content or tags change, which
+// verifying never does.
+func VerifyMemory(s Store, id string) error {
+	_, err := Revise(s, id, "verify", "verify", func(m *Memory) error {
+		RecordGitState(m)
+		m.Verified = Now()
+		return nil
+	})
+	return err
+}
@@ -0,0 +1,45 @@
+package internal
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestCheckStaleStartsFromTheRecordedFile(t *testing.T) {
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	t.Setenv("HOME", t.TempDir())
+	gitRepo(t, "")
+	write := func(lines ...string) {
+		t.Helper()
+		if err := os.WriteFile("main.go", []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("package main", "", "func main() {}")
+	for _, args := range [][]string{{"add", "main.go"}, {"-c", "user.name=t", "-c", "user.email=t@example.com", "commit", "-q", "-m", "main"}} {
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+
+	// Stored with edits that were never committed
+	write("package main", "", "func main() {}", "func a() {}", "func b() {}", "func c() {}", "func d() {}", "func e() {}", "func f() {}")
+	m := Memory{Content: "main.go has the helpers", Anchors: []Anchor{{Path: "main.go", Symbol: "main"}}}
+	RecordGitState(&m)
+	if stale := CheckStale(m, 5); len(stale) != 0 {
+		t.Fatalf("stale right after storing: %+v", stale)
+	}
+
+	write("package main", "", "func main() {}", "func a() {}", "func b() {}", "func c() {}", "func d() {}", "func e() {}", "func g() {}")
+	if stale := CheckStale(m, 5); len(stale) != 0 {
+		t.Errorf("stale after a one-line change: %+v", stale)
+	}
+
+	write("package main", "", "func main() {}")
+	stale := CheckStale(m, 5)
+	if len(stale) != 1 || stale[0].Removed != 6 || stale[0].Added != 0 {
+		t.Errorf("CheckStale() = %+v, want six lines removed", stale)
+	}
+}
@@ -0,0 +1,381 @@
+package internal
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Memory represents a stored memory
+// (yaml tags describe the FileStore frontmatter; content is the markdown body)
+type Memory struct {
+	ID          string   `json:"id" yaml:"id"`
+	Type        string   `json:"type" yaml:"type"`
+	Content     string   `json:"content" yaml:"-"`
+	Project     string   `json:"project,omitempty" yaml:"project,omitempty"`
+	Tags        []string `json:"tags" yaml:"tags"`
+	Created     string   `json:"created" yaml:"created"`
+	Accessed    string   `json:"accessed" yaml:"accessed"`
+	AccessCount int      `json:"access_count" yaml:"access_count"`
+	Anchors     []Anchor `json:"anchors,omitempty" yaml:"anchors,omitempty"`
+	// Commit is the git HEAD when the memory was stored or last verified
+	Commit string `json:"commit,omitempty" yaml:"commit,omitempty"`
+	// Source is the file (path:line) a memory was ingested from, if any
+	Source string `json:"source,omitempty" yaml:"source,omitempty"`
+	// Verified is when the memory was last confirmed accurate (memo verify)
+	Verified string `json:"verified,omitempty" yaml:"verified,omitempty"`
+	// Revisions holds every state of the content and tags, oldest first and
+	// ending with the current one (empty until either first changes)
+	Revisions []Revision `json:"revisions,omitempty" yaml:"revisions,omitempty"`
+}
+
+// Store is the persistence layer behind every memo command.
+// RedisStore is the default implementation.
+type Store interface {
+	// Close releases the backend's resources
+	Close() error
+
+	// Init creates (or recreates) the search index
+	Init() error
+
+	// Remember stores a new memory built from Type, Content, Tags, Project
+	// and Anchors, filling in its ID, timestamps and project tag
+	Remember(memo *Memory) error
+	// Get retrieves a memory and updates its access stats
+	Get(id string) (*Memory, error)
+	// Peek retrieves a memory without touching its access stats
+	Peek(id string) (*Memory, error)
+	// Update replaces a memory's content, recording a revision
+	Update(id, content string) error
+	// AddTag adds a tag to an existing memory, recording a revision
+	AddTag(id, tag string) error
+	// Forget deletes a memory and its embedding permanently (see TrashMemory)
+	Forget(id string) error
+
+	// Recall runs a full-text query
+	Recall(query string, limit int) ([]Memory, error)
+	// TextSearch runs a full-text search with the query escaped as plain text
+	TextSearch(query string, limit int) ([]Memory, error)
+	// KeywordSearch ranks the memories matching the filter that contain any
+	// word of query by BM25 relevance, best first
+	KeywordSearch(query string, limit int, f Filter) ([]Memory, error)
+	// Iterate streams every memory matching the filter, in batches
+	Iterate(f Filter) MemoryIterator
+	// Count returns how many memories match the filter
+	Count(f Filter) (int, error)
+	// GetAllMemoryIDs returns the IDs of every stored memory
+	GetAllMemoryIDs() ([]string, error)
+	// Projects returns memory counts keyed by project
+	Projects() (map[string]int, error)
+	// ProjectAliases maps each alias to the project key it resolves to
+	ProjectAliases() (map[string]string, error)
+	// SetProjectAlias makes alias resolve to a project key
+	SetProjectAlias(alias, key string) error
+	// DeleteProjectAlias removes an alias
+	DeleteProjectAlias(alias string) error
+	// Stats returns memory counts keyed by type, plus "total"
+	Stats() (map[string]int, error)
+
+	// EmbedMemory stores the embedding for a memory
+	EmbedMemory(id string, embedding []float64) error
+	// GetEmbeddingByID returns the stored embedding for a memory
+	GetEmbeddingByID(id string) ([]float64, error)
+	// Similar finds the memories nearest to an embedding among those matching the filter
+	Similar(embedding []float64, limit int, f Filter) ([]SimilarResult, error)
+	// DeleteEmbeddings removes every stored embedding
+	DeleteEmbeddings() error
+	// DeleteEmbedding removes one stored embedding
+	DeleteEmbedding(id string) error
+	// EmbeddingDims returns the dimension of every stored embedding, keyed by memory ID
+	EmbeddingDims() (map[string]int, error)
+	// CheckIndex reports problems with the search index (nil if healthy)
+	CheckIndex() ([]string, error)
+
+	// GetBrief returns the stored brief for a project
+	GetBrief(project string) (string, error)
+	// SetBrief stores a brief for a project
+	SetBrief(project, brief string) error
+	// IsBriefStale checks if the brief needs regeneration
+	IsBriefStale(project string) bool
+	// MarkBriefStale marks a project's brief as needing regeneration
+	MarkBriefStale(project string)
+	// MarkBriefFresh marks a project's brief as up to date
+	MarkBriefFresh(project string)
+	// BriefProjects returns every project with a stored brief or stale flag
+	BriefProjects() ([]string, error)
+	// DeleteBrief removes a project's brief and stale flag
+	DeleteBrief(project string) error
+
+	// SchemaVersion returns the document schema version recorded in the store (0 if none)
+	SchemaVersion() (int, error)
+	// SetSchemaVersion records the document schema version
+	SetSchemaVersion(version int) error
+	// Documents returns every stored memory as a decoded JSON document, keyed by ID
+	Documents() (map[string]map[string]interface{}, error)
+	// PutDocument writes a memory document, creating the memory if needed
+	PutDocument(id string, doc map[string]interface{}) error
+	// Dump reads every document, vector, brief and alias as of one moment
+	Dump() (*Dump, error)
+
+	// PutTrash stores a deleted memory in the trash
+	PutTrash(e TrashEntry) error
+	// TrashEntries returns the trash, most recently deleted first
+	TrashEntries() ([]TrashEntry, error)
+	// DeleteTrash permanently removes a trash entry
+	DeleteTrash(id string) error
+	// Quarantine moves an unreadable memory's raw data out of the way,
+	// keeping it for manual recovery, and says where it went
+	Quarantine(id string) (string, error)
+
+	// AppendLog adds an entry to the audit log, which is never rewritten
+	AppendLog(e LogEntry) error
+	// ReadLog returns the audit log entries matching the query, oldest first
+	ReadLog(q LogQuery) ([]LogEntry, error)
+
+	// PushUndo records an operation, keeping only the newest undoDepth
+	PushUndo(op *Operation) error
+	// PopUndo removes and returns the newest operation (nil if there is none)
+	PopUndo() (*Operation, error)
+}
+
+// initMemory fills in what Remember sets on every new memory
+func initMemory(memo *Memory) {
+	ts := Now()
+	memo.ID = GenID()
+	memo.Tags = memoryTags(memo.Project, memo.Tags)
+	memo.Created = ts
+	memo.Accessed = ts
+	memo.AccessCount = 0
+}
+
+// RememberMemory stores a new memory so that memo undo and the audit log
+// cover it
+func RememberMemory(s Store, memo *Memory) error {
+	if err := s.Remember(memo); err != nil {
+		return err
+	}
+	journal.created(memo.ID)
+	return auditMemory(s, "remember", memo.ID, nil, memo)
+}
+
+// memoryTags prepends the project tag, if any, to a new memory's tags.
+// Memories outside a project (global, user and org scopes) have none.
+func memoryTags(project string, tags []string) []string {
+	if project == "" {
+		return tags
+	}
+	return append([]string{"project:" + project}, tags...)
+}
+
+// Filter narrows the memories Iterate and Count return.
+// Empty fields match everything.
+type Filter struct {
+	Type string
+	// Projects matches memories filed under any of these project keys or aliases
+	Projects []string
+	// Tag is a RediSearch-style tag filter: "a|b*" matches tag a or any tag starting with b
+	Tag string
+	// InDir, when set, drops memories scoped to a sub-directory unless InDir
+	// is that directory or below it ("." is the repo root). It is only
+	// evaluated client-side.
+	InDir string
+	// OnBranch, when set, drops memories recorded on any other git branch
+	// ("HEAD" when detached hides them all). Also client-side only.
+	OnBranch string
+	// File matches memories anchored to a repo path or glob (** spans
+	// directories; a plain directory matches everything below it)
+	File string
+}
+
+func (f Filter) empty() bool {
+	return f.Type == "" && len(f.Projects) == 0 && f.Tag == "" && f.InDir == "" && f.OnBranch == "" && f.File == ""
+}
+
+// clientSide reports whether the filter has parts backends cannot query
+// exactly (backends may still narrow by them, e.g. by a glob's prefix)
+func (f Filter) clientSide() bool {
+	return f.InDir != "" || f.OnBranch != "" || f.File != ""
+}
+
+// Matches reports whether a memory passes the filter
+func (f Filter) Matches(m Memory) bool {
+	if f.Type != "" && m.Type != f.Type {
+		return false
+	}
+	if len(f.Projects) > 0 && !inProjects(m, f.Projects) {
+		return false
+	}
+	if f.Tag != "" && !matchesTagFilter(m.Tags, f.Tag) {
+		return false
+	}
+	if f.InDir != "" {
+		for _, tag := range m.Tags {
+			if dir, ok := strings.CutPrefix(tag, "dir:"); ok && f.InDir != dir && !strings.HasPrefix(f.InDir, dir+"/") {
+				return false
+			}
+		}
+	}
+	if f.OnBranch != "" {
+		if branch := BranchOf(m); branch != "" && branch != f.OnBranch {
+			return false
+		}
+	}
+	if f.File != "" && !anchoredTo(m, f.File) {
+		return false
+	}
+	return true
+}
+
+// inProjects reports whether a memory is filed under any of the projects
+func inProjects(m Memory, projects []string) bool {
+	for _, p := range projects {
+		if m.Project == p || hasTag(m.Tags, "project:"+p) {
+			return true
+		}
+	}
+	return false
+}
+
+// MemoryIterator streams memories without holding the whole result set:
+//
+//	it := store.Iterate(filter)
+//	defer it.Close()
+//	for it.Next() {
+//		m := it.Val()
+//	}
+//	if err := it.Err(); err != nil { ... }
+type MemoryIterator interface {
+	Next() bool
+	Val() Memory
+	Err() error
+	Close() error
+}
+
+// iterBatchSize is how many memories an iterator fetches per round trip
+const iterBatchSize = 500
+
+// batchIterator implements MemoryIterator over a backend's fetch function.
+// fetch returns the next batch and whether it was the last one.
+type batchIterator struct {
+	fetch  func() ([]Memory, bool, error)
+	filter func(Memory) bool
+	close  func() error
+
+	batch []Memory
+	pos   int
+	done  bool
+	cur   Memory
+	err   error
+}
+
+func (it *batchIterator) Next() bool {
+	for {
+		for it.pos < len(it.batch) {
+			m := it.batch[it.pos]
+			it.pos++
+			if it.filter == nil || it.filter(m) {
+				it.cur = m
+				return true
+			}
+		}
+		if it.done || it.err != nil {
+			return false
+		}
+		it.batch, it.done, it.err = it.fetch()
+		it.pos = 0
+	}
+}
+
+func (it *batchIterator) Val() Memory {
+	return it.cur
+}
+
+func (it *batchIterator) Err() error {
+	return it.err
+}
+
+func (it *batchIterator) Close() error {
+	it.done = true
+	it.batch = nil
+	if it.close != nil {
+		return it.close()
+	}
+	return nil
+}
+
+// Collect drains an iterator into a slice, stopping after limit memories (0 = no limit)
+func Collect(it MemoryIterator, limit int) ([]Memory, error) {
+	defer it.Close()
+	var memos []Memory
+	for it.Next() {
+		memos = append(memos, it.Val())
+		if limit > 0 && len(memos) >= limit {
+			break
+		}
+	}
+	return memos, it.Err()
+}
+
+// countMatching counts what an iterator yields, for backends without a cheaper way
+func countMatching(it MemoryIterator) (int, error) {
+	defer it.Close()
+	n := 0
+	for it.Next() {
+		n++
+	}
+	return n, it.Err()
+}
+
+// OpenStore opens the backend selected by cfg.Backend
+func OpenStore(cfg *Config) (Store, error) {
+	switch cfg.Backend {
+	case "redis":
+		return NewRedisStore(cfg.Redis)
+	case "sqlite":
+		return NewSQLiteStore(cfg.SQLite.Path)
+	case "files":
+		return NewFileStore(cfg.Files.Dir)
+	default:
+		return nil, fmt.Errorf("unknown backend: %s", cfg.Backend)
+	}
+}
+
+// SimilarResult holds a memory with its similarity score
+type SimilarResult struct {
+	Memory Memory
+	Score  string
+}
+
+// reservedIDs are the RedisStore keys that share the memo: namespace with
+// memories; a memory with one of these IDs would overwrite them
+var reservedIDs = []string{"meta", "aliases", "trash", "undo", "quarantine", "log"}
+
+// ValidateID rejects IDs that are unsafe as a file name or key. IDs from
+// GenID always pass; imports and sync peers can send anything.
+func ValidateID(id string) error {
+	if id == "" || strings.ContainsAny(id, "/\\\x00") || strings.Contains(id, "..") {
+		return fmt.Errorf("invalid memory ID: %q", id)
+	}
+	if slices.Contains(reservedIDs, id) {
+		return fmt.Errorf("invalid memory ID: %q is reserved", id)
+	}
+	return nil
+}
+
+// GenID generates a short unique ID
+func GenID() string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// timeLayout is how memo formats timestamps
+const timeLayout = "2006-01-02T15:04:05Z"
+
+// Now returns current ISO timestamp
+func Now() string {
+	return time.Now().UTC().Format(timeLayout)
+}
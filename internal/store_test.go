@@ -0,0 +1,392 @@
+package internal
+
+import (
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// testStore runs the scenarios every Store must pass against fresh stores
+// from open
+func testStore(t *testing.T, open func(t *testing.T) Store) {
+	useDefaultSettings(t)
+	remember := func(t *testing.T, s Store, m *Memory) *Memory {
+		t.Helper()
+		if m.Tags == nil {
+			m.Tags = []string{}
+		}
+		if err := s.Remember(m); err != nil {
+			t.Fatal(err)
+		}
+		return m
+	}
+	ids := func(memos []Memory) []string {
+		var ids []string
+		for _, m := range memos {
+			ids = append(ids, m.ID)
+		}
+		sort.Strings(ids)
+		return ids
+	}
+	sorted := func(ids ...string) []string {
+		sort.Strings(ids)
+		return ids
+	}
+
+	t.Run("remember and get", func(t *testing.T) {
+		s := open(t)
+		m := remember(t, s, &Memory{Type: "fact", Content: "The API listens on port 8080", Project: "github.com/acme/api", Tags: []string{"api"}})
+		if m.ID == "" || m.Created == "" {
+			t.Fatalf("Remember left %+v", m)
+		}
+		got, err := s.Get(m.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Content != m.Content || got.Project != m.Project || !reflect.DeepEqual(got.Tags, []string{"project:github.com/acme/api", "api"}) {
+			t.Errorf("Get() = %+v", got)
+		}
+		if peeked, _ := s.Peek(m.ID); peeked.AccessCount != 1 {
+			t.Errorf("access count %d after one Get", peeked.AccessCount)
+		}
+		if _, err := s.Peek("missing"); err == nil {
+			t.Error("Peek found a memory that does not exist")
+		}
+	})
+
+	t.Run("update, tag and forget", func(t *testing.T) {
+		s := open(t)
+		m := remember(t, s, &Memory{Type: "fact", Content: "Builds take five minutes"})
+		if err := s.Update(m.ID, "Builds take two minutes"); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.AddTag(m.ID, "ci"); err != nil {
+			t.Fatal(err)
+		}
+		got, _ := s.Peek(m.ID)
+		if got.Content != "Builds take two minutes" || !slices.Contains(got.Tags, "ci") {
+			t.Errorf("after update and tag: %+v", got)
+		}
+		if err := s.EmbedMemory(m.ID, []float64{1, 0}); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := s.Forget(m.ID); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.Peek(m.ID); err == nil {
+			t.Error("memory still there after Forget")
+		}
+		if _, err := s.GetEmbeddingByID(m.ID); err == nil {
+			t.Error("embedding still there after Forget")
+		}
+		if found, _ := s.Recall("minutes", 10); len(found) != 0 {
+			t.Errorf("full-text search still finds %v", ids(found))
+		}
+		if err := s.Forget(m.ID); err == nil {
+			t.Error("forgot a memory twice")
+		}
+	})
+
+	t.Run("search", func(t *testing.T) {
+		s := open(t)
+		pnpm := remember(t, s, &Memory{Type: "preference", Content: "Use pnpm instead of npm"})
+		db := remember(t, s, &Memory{Type: "fact", Content: "Tests need a running database"})
+		both := remember(t, s, &Memory{Type: "fact", Content: "Run pnpm test against the database"})
+
+		if found, _ := s.Recall("pnpm", 10); !reflect.DeepEqual(ids(found), sorted(pnpm.ID, both.ID)) {
+			t.Errorf("Recall(pnpm) = %v", ids(found))
+		}
+		if found, _ := s.TextSearch("running database", 10); !reflect.DeepEqual(ids(found), []string{db.ID}) {
+			t.Errorf("TextSearch = %v", ids(found))
+		}
+		found, err := s.KeywordSearch("pnpm database", 10, Filter{Type: "fact"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(found) != 2 || found[0].ID != both.ID {
+			t.Errorf("KeywordSearch = %v, want %s (both words) first, only facts", ids(found), both.ID)
+		}
+	})
+
+	t.Run("filters", func(t *testing.T) {
+		s := open(t)
+		api := remember(t, s, &Memory{Type: "fact", Content: "The API uses Go", Project: "github.com/acme/api", Tags: []string{"lang:go"}})
+		web := remember(t, s, &Memory{Type: "fact", Content: "The web app uses TypeScript", Project: "github.com/acme/web", Tags: []string{"lang:ts"}})
+		pref := remember(t, s, &Memory{Type: "preference", Content: "Prefer small commits", Tags: []string{"style"}})
+
+		tests := []struct {
+			f    Filter
+			want []string
+		}{
+			{Filter{}, sorted(api.ID, web.ID, pref.ID)},
+			{Filter{Type: "fact"}, sorted(api.ID, web.ID)},
+			{Filter{Projects: []string{"github.com/acme/api"}}, []string{api.ID}},
+			{Filter{Tag: "style|lang:ts"}, sorted(web.ID, pref.ID)},
+			{Filter{Tag: "lang:*"}, sorted(api.ID, web.ID)},
+		}
+		for _, tt := range tests {
+			var got []Memory
+			it := s.Iterate(tt.f)
+			for it.Next() {
+				got = append(got, it.Val())
+			}
+			if err := it.Err(); err != nil {
+				t.Fatal(err)
+			}
+			it.Close()
+			if !reflect.DeepEqual(ids(got), tt.want) {
+				t.Errorf("Iterate(%+v) = %v, want %v", tt.f, ids(got), tt.want)
+			}
+			if n, _ := s.Count(tt.f); n != len(tt.want) {
+				t.Errorf("Count(%+v) = %d, want %d", tt.f, n, len(tt.want))
+			}
+		}
+
+		all, _ := s.GetAllMemoryIDs()
+		if sort.Strings(all); !reflect.DeepEqual(all, sorted(api.ID, web.ID, pref.ID)) {
+			t.Errorf("GetAllMemoryIDs() = %v", all)
+		}
+		if projects, _ := s.Projects(); projects["github.com/acme/api"] != 1 || projects["github.com/acme/web"] != 1 {
+			t.Errorf("Projects() = %v", projects)
+		}
+		if stats, _ := s.Stats(); stats["total"] != 3 || stats["fact"] != 2 || stats["preference"] != 1 {
+			t.Errorf("Stats() = %v", stats)
+		}
+	})
+
+	t.Run("embeddings", func(t *testing.T) {
+		s := open(t)
+		near := remember(t, s, &Memory{Type: "fact", Content: "near"})
+		far := remember(t, s, &Memory{Type: "preference", Content: "far"})
+		if err := s.EmbedMemory(near.ID, []float64{1, 0}); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.EmbedMemory(far.ID, []float64{0, 1}); err != nil {
+			t.Fatal(err)
+		}
+
+		results, err := s.Similar([]float64{0.9, 0.1}, 10, Filter{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 2 || results[0].Memory.ID != near.ID {
+			t.Errorf("Similar() = %+v, want %s first", results, near.ID)
+		}
+		if results, _ := s.Similar([]float64{0.9, 0.1}, 10, Filter{Type: "preference"}); len(results) != 1 || results[0].Memory.ID != far.ID {
+			t.Errorf("Similar() filtered to preferences = %+v", results)
+		}
+		if dims, _ := s.EmbeddingDims(); !reflect.DeepEqual(dims, map[string]int{near.ID: 2, far.ID: 2}) {
+			t.Errorf("EmbeddingDims() = %v", dims)
+		}
+
+		if err := s.DeleteEmbedding(near.ID); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.GetEmbeddingByID(near.ID); err == nil {
+			t.Error("embedding still there after DeleteEmbedding")
+		}
+		if err := s.DeleteEmbeddings(); err != nil {
+			t.Fatal(err)
+		}
+		if dims, _ := s.EmbeddingDims(); len(dims) != 0 {
+			t.Errorf("EmbeddingDims() = %v after DeleteEmbeddings", dims)
+		}
+	})
+
+	t.Run("briefs and aliases", func(t *testing.T) {
+		s := open(t)
+		const project = "github.com/acme/api"
+		if !s.IsBriefStale(project) {
+			t.Error("a brief never generated is not stale")
+		}
+		if err := s.SetBrief(project, "The API is written in Go."); err != nil {
+			t.Fatal(err)
+		}
+		s.MarkBriefFresh(project)
+		if brief, _ := s.GetBrief(project); brief != "The API is written in Go." || s.IsBriefStale(project) {
+			t.Errorf("brief %q, stale %v", brief, s.IsBriefStale(project))
+		}
+		s.MarkBriefStale(project)
+		if !s.IsBriefStale(project) {
+			t.Error("MarkBriefStale did not stick")
+		}
+		if projects, _ := s.BriefProjects(); !reflect.DeepEqual(projects, []string{project}) {
+			t.Errorf("BriefProjects() = %v", projects)
+		}
+		if err := s.DeleteBrief(project); err != nil {
+			t.Fatal(err)
+		}
+		if projects, _ := s.BriefProjects(); len(projects) != 0 {
+			t.Errorf("BriefProjects() = %v after DeleteBrief", projects)
+		}
+
+		if err := s.SetProjectAlias("github.com/acme/old-api", project); err != nil {
+			t.Fatal(err)
+		}
+		if aliases, _ := s.ProjectAliases(); !reflect.DeepEqual(aliases, map[string]string{"github.com/acme/old-api": project}) {
+			t.Errorf("ProjectAliases() = %v", aliases)
+		}
+		if err := s.DeleteProjectAlias("github.com/acme/old-api"); err != nil {
+			t.Fatal(err)
+		}
+		if aliases, _ := s.ProjectAliases(); len(aliases) != 0 {
+			t.Errorf("ProjectAliases() = %v after delete", aliases)
+		}
+	})
+
+	t.Run("documents and dump", func(t *testing.T) {
+		s := open(t)
+		if v, _ := s.SchemaVersion(); v != 0 {
+			t.Errorf("new store at schema version %d", v)
+		}
+		if err := s.SetSchemaVersion(SchemaVersion); err != nil {
+			t.Fatal(err)
+		}
+		m := remember(t, s, &Memory{Type: "fact", Content: "Deploys run from main", Project: "github.com/acme/api"})
+		if err := s.EmbedMemory(m.ID, []float64{1, 0}); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.SetBrief("github.com/acme/api", "brief"); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.SetProjectAlias("old", "github.com/acme/api"); err != nil {
+			t.Fatal(err)
+		}
+
+		docs, err := s.Documents()
+		if err != nil {
+			t.Fatal(err)
+		}
+		doc := docs[m.ID]
+		doc["content"] = "Deploys run from the release branch"
+		if err := s.PutDocument(m.ID, doc); err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := s.Peek(m.ID); got.Content != "Deploys run from the release branch" {
+			t.Errorf("PutDocument left %q", got.Content)
+		}
+		if found, _ := s.Recall("release", 10); len(found) != 1 {
+			t.Error("full-text search does not see the document PutDocument wrote")
+		}
+
+		d, err := s.Dump()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.SchemaVersion != SchemaVersion || len(d.Docs) != 1 || len(d.Embeddings[m.ID]) != 2 ||
+			d.Briefs["github.com/acme/api"] != "brief" || d.Aliases["old"] != "github.com/acme/api" {
+			t.Errorf("Dump() = %+v", d)
+		}
+	})
+
+	t.Run("trash", func(t *testing.T) {
+		s := open(t)
+		older := TrashEntry{ID: "a1", Deleted: "2026-01-01T00:00:00Z", Doc: map[string]interface{}{"id": "a1"}}
+		newer := TrashEntry{ID: "b2", Deleted: "2026-02-01T00:00:00Z", Doc: map[string]interface{}{"id": "b2"}, Embedding: []float64{1, 0}}
+		for _, e := range []TrashEntry{older, newer} {
+			if err := s.PutTrash(e); err != nil {
+				t.Fatal(err)
+			}
+		}
+		entries, err := s.TrashEntries()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 2 || entries[0].ID != "b2" || !reflect.DeepEqual(entries[0].Embedding, []float64{1, 0}) {
+			t.Errorf("TrashEntries() = %+v, want b2 first with its vector", entries)
+		}
+		if err := s.DeleteTrash("b2"); err != nil {
+			t.Fatal(err)
+		}
+		if entries, _ := s.TrashEntries(); len(entries) != 1 {
+			t.Errorf("%d trash entries after DeleteTrash", len(entries))
+		}
+	})
+
+	t.Run("quarantine", func(t *testing.T) {
+		s := open(t)
+		m := remember(t, s, &Memory{Type: "fact", Content: "Kept aside"})
+		where, err := s.Quarantine(m.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if where == "" {
+			t.Error("Quarantine did not say where the memory went")
+		}
+		if all, _ := s.GetAllMemoryIDs(); len(all) != 0 {
+			t.Errorf("GetAllMemoryIDs() = %v after quarantine", all)
+		}
+	})
+
+	t.Run("log", func(t *testing.T) {
+		s := open(t)
+		entries := []LogEntry{
+			{Time: "2026-01-01T00:00:00Z", Actor: "ana", Project: "p", Command: "memo remember", Action: "remember", ID: "a1"},
+			{Time: "2026-01-02T00:00:00Z", Actor: "bo", Project: "q", Command: "memo forget", Action: "forget", ID: "b2"},
+			{Time: "2026-01-03T00:00:00Z", Actor: "ana", Project: "p", Command: "memo update", Action: "update", ID: "a1"},
+		}
+		for _, e := range entries {
+			if err := s.AppendLog(e); err != nil {
+				t.Fatal(err)
+			}
+		}
+		got, err := s.ReadLog(LogQuery{ID: "a1"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 2 || got[0].Action != "remember" || got[1].Action != "update" {
+			t.Errorf("ReadLog(a1) = %+v, want oldest first", got)
+		}
+		if got, _ := s.ReadLog(LogQuery{Since: "2026-01-02T00:00:00Z", Until: "2026-01-02T00:00:00Z"}); len(got) != 1 || got[0].ID != "b2" {
+			t.Errorf("ReadLog of one day = %+v", got)
+		}
+	})
+
+	t.Run("undo stack", func(t *testing.T) {
+		s := open(t)
+		for i := 0; i < undoDepth+2; i++ {
+			op := &Operation{Command: "remember", Changes: []Change{{ID: strings.Repeat("x", i+1)}}}
+			if err := s.PushUndo(op); err != nil {
+				t.Fatal(err)
+			}
+		}
+		popped := 0
+		for {
+			op, err := s.PopUndo()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if op == nil {
+				break
+			}
+			if popped == 0 && op.Changes[0].ID != strings.Repeat("x", undoDepth+2) {
+				t.Errorf("popped %+v first, want the newest", op)
+			}
+			popped++
+		}
+		if popped != undoDepth {
+			t.Errorf("popped %d operations, want the newest %d", popped, undoDepth)
+		}
+	})
+
+	t.Run("index check", func(t *testing.T) {
+		s := open(t)
+		remember(t, s, &Memory{Type: "fact", Content: "Indexed"})
+		if issues, err := s.CheckIndex(); err != nil || len(issues) != 0 {
+			t.Errorf("CheckIndex() = %v, %v on a healthy store", issues, err)
+		}
+	})
+}
+
+func TestFileStoreScenarios(t *testing.T) {
+	testStore(t, func(t *testing.T) Store {
+		s, err := NewFileStore(t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	})
+}
@@ -0,0 +1,775 @@
+// Package storetest provides an in-memory internal.Store for tests, so
+// commands and helpers can run without Redis, SQLite or a directory of files.
+package storetest
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"memo/internal"
+)
+
+// undoDepth matches how many operations the real backends keep
+const undoDepth = 20
+
+// Store keeps every memory, vector, brief and log entry in maps. Memories
+// are held as JSON documents, so what callers get back is always a copy,
+// the same as from a real backend.
+type Store struct {
+	mu          sync.Mutex
+	docs        map[string]map[string]interface{}
+	vectors     map[string][]float64
+	briefs      map[string]string
+	staleBriefs map[string]bool
+	aliases     map[string]string
+	schema      int
+	trash       map[string]internal.TrashEntry
+	quarantine  map[string]map[string]interface{}
+	// unreadable memories fail to decode, as if corrupted on disk
+	unreadable map[string]bool
+	log        []internal.LogEntry
+	undo       []*internal.Operation
+}
+
+// New returns an empty store at the current schema version
+func New() *Store {
+	return &Store{
+		docs:        make(map[string]map[string]interface{}),
+		vectors:     make(map[string][]float64),
+		briefs:      make(map[string]string),
+		staleBriefs: make(map[string]bool),
+		aliases:     make(map[string]string),
+		schema:      internal.SchemaVersion,
+		trash:       make(map[string]internal.TrashEntry),
+		quarantine:  make(map[string]map[string]interface{}),
+		unreadable:  make(map[string]bool),
+	}
+}
+
+// Close does nothing; the store lives as long as the test
+func (s *Store) Close() error { return nil }
+
+// Init has no index to build
+func (s *Store) Init() error { return nil }
+
+// Remember stores a new memory the way every backend does: fresh ID and
+// timestamps, and the project tag first
+func (s *Store) Remember(memo *internal.Memory) error {
+	ts := internal.Now()
+	memo.ID = internal.GenID()
+	if memo.Project != "" {
+		memo.Tags = append([]string{"project:" + memo.Project}, memo.Tags...)
+	}
+	memo.Created = ts
+	memo.Accessed = ts
+	memo.AccessCount = 0
+	return s.put(memo)
+}
+
+func (s *Store) put(memo *internal.Memory) error {
+	doc, err := toDocument(memo)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.docs[memo.ID] = doc
+	delete(s.unreadable, memo.ID)
+	return nil
+}
+
+// Corrupt makes a stored memory unreadable, the way a bad hand edit or a
+// truncated row would: it is still listed, but cannot be decoded
+func (s *Store) Corrupt(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.docs[id]; !ok {
+		s.docs[id] = map[string]interface{}{}
+	}
+	s.unreadable[id] = true
+}
+
+// Get retrieves a memory and bumps its access stats; the returned copy
+// keeps the previous values, as in Redis
+func (s *Store) Get(id string) (*internal.Memory, error) {
+	memo, err := s.Peek(id)
+	if err != nil {
+		return nil, err
+	}
+	updated := *memo
+	updated.AccessCount++
+	updated.Accessed = internal.Now()
+	return memo, s.put(&updated)
+}
+
+// Peek retrieves a memory without touching its access stats
+func (s *Store) Peek(id string) (*internal.Memory, error) {
+	s.mu.Lock()
+	doc, ok := s.docs[id]
+	unreadable := s.unreadable[id]
+	s.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("memory not found: %s", id)
+	}
+	if unreadable {
+		return nil, fmt.Errorf("memory %s: cannot be parsed", id)
+	}
+	memo, err := toMemory(doc)
+	if err != nil {
+		return nil, err
+	}
+	memo.ID = id
+	return memo, nil
+}
+
+// Update replaces a memory's content, recording a revision
+func (s *Store) Update(id, content string) error {
+	_, err := internal.Revise(s, id, "update", "update", func(m *internal.Memory) error {
+		m.Content = content
+		return nil
+	})
+	return err
+}
+
+// AddTag adds a tag, recording a revision
+func (s *Store) AddTag(id, tag string) error {
+	_, err := internal.Revise(s, id, "tag", "tag "+tag, func(m *internal.Memory) error {
+		if slices.Contains(m.Tags, tag) {
+			return fmt.Errorf("tag already exists: %s", tag)
+		}
+		m.Tags = append(m.Tags, tag)
+		return nil
+	})
+	return err
+}
+
+// Forget deletes a memory and its vector
+func (s *Store) Forget(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.docs[id]; !ok {
+		return fmt.Errorf("memory not found: %s", id)
+	}
+	delete(s.docs, id)
+	delete(s.vectors, id)
+	delete(s.unreadable, id)
+	return nil
+}
+
+// all returns every memory that decodes, oldest first, skipping the rest
+// as the backends skip unreadable files and rows
+func (s *Store) all() ([]internal.Memory, error) {
+	s.mu.Lock()
+	ids := make([]string, 0, len(s.docs))
+	for id := range s.docs {
+		ids = append(ids, id)
+	}
+	s.mu.Unlock()
+
+	memos := make([]internal.Memory, 0, len(ids))
+	for _, id := range ids {
+		if memo, err := s.Peek(id); err == nil {
+			memos = append(memos, *memo)
+		}
+	}
+	sort.Slice(memos, func(i, j int) bool {
+		if memos[i].Created != memos[j].Created {
+			return memos[i].Created < memos[j].Created
+		}
+		return memos[i].ID < memos[j].ID
+	})
+	return memos, nil
+}
+
+// Recall returns memories containing every query word ("word*" matches a prefix)
+func (s *Store) Recall(query string, limit int) ([]internal.Memory, error) {
+	var terms []string
+	for _, field := range strings.Fields(strings.ToLower(query)) {
+		prefix := strings.HasSuffix(field, "*")
+		for _, w := range words(field) {
+			if prefix {
+				w += "*"
+			}
+			terms = append(terms, w)
+		}
+	}
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	memos, err := s.all()
+	if err != nil {
+		return nil, err
+	}
+	var found []internal.Memory
+	for _, m := range memos {
+		if len(found) >= limit {
+			break
+		}
+		if containsAll(words(m.Content), terms) {
+			found = append(found, m)
+		}
+	}
+	return found, nil
+}
+
+// TextSearch matches every word of query as plain text
+func (s *Store) TextSearch(query string, limit int) ([]internal.Memory, error) {
+	return s.Recall(strings.Join(words(query), " "), limit)
+}
+
+// KeywordSearch ranks the memories matching the filter by how many of the
+// query's words they contain, then oldest first. It stands in for BM25:
+// tests that depend on exact scores belong with the real ranking.
+func (s *Store) KeywordSearch(query string, limit int, f internal.Filter) ([]internal.Memory, error) {
+	query = strings.Join(words(query), " ")
+	memos, err := s.all()
+	if err != nil {
+		return nil, err
+	}
+	hits := make(map[string]int)
+	var found []internal.Memory
+	for _, m := range memos {
+		if !f.Matches(m) {
+			continue
+		}
+		content := words(m.Content)
+		for _, q := range strings.Fields(query) {
+			if slices.Contains(content, q) {
+				hits[m.ID]++
+			}
+		}
+		if hits[m.ID] > 0 {
+			found = append(found, m)
+		}
+	}
+	sort.SliceStable(found, func(i, j int) bool {
+		return hits[found[i].ID] > hits[found[j].ID]
+	})
+	if len(found) > limit {
+		found = found[:limit]
+	}
+	return found, nil
+}
+
+// Iterate yields the memories matching the filter, oldest first
+func (s *Store) Iterate(f internal.Filter) internal.MemoryIterator {
+	memos, err := s.all()
+	it := &iterator{err: err}
+	for _, m := range memos {
+		if f.Matches(m) {
+			it.memos = append(it.memos, m)
+		}
+	}
+	return it
+}
+
+// Count returns how many memories match the filter
+func (s *Store) Count(f internal.Filter) (int, error) {
+	it := s.Iterate(f)
+	defer it.Close()
+	n := 0
+	for it.Next() {
+		n++
+	}
+	return n, it.Err()
+}
+
+// GetAllMemoryIDs returns every memory ID, readable or not
+func (s *Store) GetAllMemoryIDs() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ids := make([]string, 0, len(s.docs))
+	for id := range s.docs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// Projects counts memories per project tag
+func (s *Store) Projects() (map[string]int, error) {
+	memos, err := s.all()
+	if err != nil {
+		return nil, err
+	}
+	projects := make(map[string]int)
+	for _, m := range memos {
+		for _, tag := range m.Tags {
+			if project, ok := strings.CutPrefix(tag, "project:"); ok && project != "" {
+				projects[project]++
+			}
+		}
+	}
+	return projects, nil
+}
+
+// ProjectAliases maps each alias to its project key
+func (s *Store) ProjectAliases() (map[string]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	aliases := make(map[string]string, len(s.aliases))
+	for alias, key := range s.aliases {
+		aliases[alias] = key
+	}
+	return aliases, nil
+}
+
+// SetProjectAlias makes alias resolve to a project key
+func (s *Store) SetProjectAlias(alias, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.aliases[alias] = key
+	return nil
+}
+
+// DeleteProjectAlias removes an alias
+func (s *Store) DeleteProjectAlias(alias string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.aliases, alias)
+	return nil
+}
+
+// Stats counts memories per type, plus "total"
+func (s *Store) Stats() (map[string]int, error) {
+	memos, err := s.all()
+	if err != nil {
+		return nil, err
+	}
+	stats := map[string]int{"fact": 0, "context": 0, "learned": 0, "preference": 0}
+	for _, m := range memos {
+		if _, ok := stats[m.Type]; ok {
+			stats[m.Type]++
+		}
+	}
+	stats["total"] = len(memos)
+	return stats, nil
+}
+
+// EmbedMemory stores a memory's vector
+func (s *Store) EmbedMemory(id string, embedding []float64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.vectors[id] = slices.Clone(embedding)
+	return nil
+}
+
+// GetEmbeddingByID returns a memory's vector
+func (s *Store) GetEmbeddingByID(id string) ([]float64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	embedding, ok := s.vectors[id]
+	if !ok {
+		return nil, fmt.Errorf("no embedding for memory: %s", id)
+	}
+	return slices.Clone(embedding), nil
+}
+
+// Similar ranks every vector by cosine similarity to embedding
+func (s *Store) Similar(embedding []float64, limit int, f internal.Filter) ([]internal.SimilarResult, error) {
+	memos, err := s.all()
+	if err != nil {
+		return nil, err
+	}
+	type scored struct {
+		memo  internal.Memory
+		score float64
+	}
+	var ranked []scored
+	s.mu.Lock()
+	for _, m := range memos {
+		if vec, ok := s.vectors[m.ID]; ok && f.Matches(m) {
+			ranked = append(ranked, scored{m, cosine(embedding, vec)})
+		}
+	}
+	s.mu.Unlock()
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].score > ranked[j].score
+	})
+
+	var results []internal.SimilarResult
+	for _, r := range ranked {
+		if len(results) >= limit {
+			break
+		}
+		results = append(results, internal.SimilarResult{Memory: r.memo, Score: fmt.Sprintf("%.2f", r.score)})
+	}
+	return results, nil
+}
+
+// DeleteEmbeddings removes every vector
+func (s *Store) DeleteEmbeddings() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.vectors = make(map[string][]float64)
+	return nil
+}
+
+// DeleteEmbedding removes one vector
+func (s *Store) DeleteEmbedding(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.vectors, id)
+	return nil
+}
+
+// EmbeddingDims returns the dimension of every vector
+func (s *Store) EmbeddingDims() (map[string]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dims := make(map[string]int, len(s.vectors))
+	for id, vec := range s.vectors {
+		dims[id] = len(vec)
+	}
+	return dims, nil
+}
+
+// CheckIndex has no index to check
+func (s *Store) CheckIndex() ([]string, error) { return nil, nil }
+
+// GetBrief returns a project's brief
+func (s *Store) GetBrief(project string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	brief, ok := s.briefs[project]
+	if !ok {
+		return "", fmt.Errorf("no brief for project: %s", project)
+	}
+	return brief, nil
+}
+
+// SetBrief stores a project's brief
+func (s *Store) SetBrief(project, brief string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.briefs[project] = brief
+	return nil
+}
+
+// IsBriefStale reports whether a brief needs regenerating; one that was
+// never generated does
+func (s *Store) IsBriefStale(project string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stale, ok := s.staleBriefs[project]
+	return !ok || stale
+}
+
+// MarkBriefStale flags a project's brief for regeneration
+func (s *Store) MarkBriefStale(project string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.staleBriefs[project] = true
+}
+
+// MarkBriefFresh flags a project's brief as up to date
+func (s *Store) MarkBriefFresh(project string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.staleBriefs[project] = false
+}
+
+// BriefProjects returns every project with a brief or stale flag
+func (s *Store) BriefProjects() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var projects []string
+	for project := range s.briefs {
+		projects = append(projects, project)
+	}
+	for project := range s.staleBriefs {
+		if _, ok := s.briefs[project]; !ok {
+			projects = append(projects, project)
+		}
+	}
+	sort.Strings(projects)
+	return projects, nil
+}
+
+// DeleteBrief removes a project's brief and stale flag
+func (s *Store) DeleteBrief(project string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.briefs, project)
+	delete(s.staleBriefs, project)
+	return nil
+}
+
+// SchemaVersion returns the recorded schema version
+func (s *Store) SchemaVersion() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.schema, nil
+}
+
+// SetSchemaVersion records the schema version
+func (s *Store) SetSchemaVersion(version int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.schema = version
+	return nil
+}
+
+// Documents returns a copy of every memory document
+func (s *Store) Documents() (map[string]map[string]interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	docs := make(map[string]map[string]interface{}, len(s.docs))
+	for id, doc := range s.docs {
+		if s.unreadable[id] {
+			continue
+		}
+		copied, err := copyDocument(doc)
+		if err != nil {
+			return nil, err
+		}
+		docs[id] = copied
+	}
+	return docs, nil
+}
+
+// PutDocument writes a memory document as given, like the JSON backends
+func (s *Store) PutDocument(id string, doc map[string]interface{}) error {
+	copied, err := copyDocument(doc)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.docs[id] = copied
+	delete(s.unreadable, id)
+	return nil
+}
+
+// Dump copies everything the store holds
+func (s *Store) Dump() (*internal.Dump, error) {
+	docs, err := s.Documents()
+	if err != nil {
+		return nil, err
+	}
+	aliases, _ := s.ProjectAliases()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d := &internal.Dump{
+		SchemaVersion: s.schema,
+		Docs:          docs,
+		Embeddings:    make(map[string][]float64, len(s.vectors)),
+		Briefs:        make(map[string]string, len(s.briefs)),
+		StaleBriefs:   make(map[string]bool, len(s.staleBriefs)),
+		Aliases:       aliases,
+	}
+	for id, vec := range s.vectors {
+		if _, ok := docs[id]; ok {
+			d.Embeddings[id] = slices.Clone(vec)
+		}
+	}
+	for project, brief := range s.briefs {
+		d.Briefs[project] = brief
+		d.StaleBriefs[project] = true
+	}
+	for project, stale := range s.staleBriefs {
+		d.StaleBriefs[project] = stale
+	}
+	return d, nil
+}
+
+// PutTrash stores a trash entry
+func (s *Store) PutTrash(e internal.TrashEntry) error {
+	if err := internal.ValidateID(e.ID); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.trash[e.ID] = e
+	return nil
+}
+
+// TrashEntries returns the trash, most recently deleted first
+func (s *Store) TrashEntries() ([]internal.TrashEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries := make([]internal.TrashEntry, 0, len(s.trash))
+	for _, e := range s.trash {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Deleted != entries[j].Deleted {
+			return entries[i].Deleted > entries[j].Deleted
+		}
+		return entries[i].ID < entries[j].ID
+	})
+	return entries, nil
+}
+
+// DeleteTrash removes a trash entry
+func (s *Store) DeleteTrash(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.trash, id)
+	return nil
+}
+
+// Quarantine moves a memory's document aside
+func (s *Store) Quarantine(id string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	doc, ok := s.docs[id]
+	if !ok {
+		return "", fmt.Errorf("memory not found: %s", id)
+	}
+	s.quarantine[id] = doc
+	delete(s.docs, id)
+	delete(s.vectors, id)
+	delete(s.unreadable, id)
+	return "the quarantine", nil
+}
+
+// Quarantined returns the documents Quarantine moved aside, keyed by ID
+func (s *Store) Quarantined() map[string]map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return copyDocuments(s.quarantine)
+}
+
+// AppendLog adds an audit log entry
+func (s *Store) AppendLog(e internal.LogEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.log = append(s.log, e)
+	return nil
+}
+
+// ReadLog returns the matching audit log entries, oldest first
+func (s *Store) ReadLog(q internal.LogQuery) ([]internal.LogEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var entries []internal.LogEntry
+	for _, e := range s.log {
+		if q.Matches(e) {
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
+
+// PushUndo records an operation, keeping the newest undoDepth
+func (s *Store) PushUndo(op *internal.Operation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.undo = append(s.undo, op)
+	if len(s.undo) > undoDepth {
+		s.undo = s.undo[len(s.undo)-undoDepth:]
+	}
+	return nil
+}
+
+// PopUndo removes and returns the newest operation
+func (s *Store) PopUndo() (*internal.Operation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.undo) == 0 {
+		return nil, nil
+	}
+	op := s.undo[len(s.undo)-1]
+	s.undo = s.undo[:len(s.undo)-1]
+	return op, nil
+}
+
+// iterator walks a slice read up front
+type iterator struct {
+	memos []internal.Memory
+	next  int
+	err   error
+}
+
+func (it *iterator) Next() bool {
+	if it.err != nil || it.next >= len(it.memos) {
+		return false
+	}
+	it.next++
+	return true
+}
+
+func (it *iterator) Val() internal.Memory { return it.memos[it.next-1] }
+func (it *iterator) Err() error           { return it.err }
+func (it *iterator) Close() error         { return nil }
+
+// words splits text into lowercase words, as the full-text backends do
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// containsAll reports whether every term (possibly a "prefix*") is among words
+func containsAll(words, terms []string) bool {
+	for _, t := range terms {
+		prefix, isPrefix := strings.CutSuffix(t, "*")
+		if !slices.ContainsFunc(words, func(w string) bool {
+			return w == t || isPrefix && strings.HasPrefix(w, prefix)
+		}) {
+			return false
+		}
+	}
+	return true
+}
+
+func cosine(a, b []float64) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += a[i] * b[i]
+		na += a[i] * a[i]
+		nb += b[i] * b[i]
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
+
+func toDocument(memo *internal.Memory) (map[string]interface{}, error) {
+	data, err := json.Marshal(memo)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]interface{}
+	return doc, json.Unmarshal(data, &doc)
+}
+
+func toMemory(doc map[string]interface{}) (*internal.Memory, error) {
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	var memo internal.Memory
+	return &memo, json.Unmarshal(data, &memo)
+}
+
+func copyDocument(doc map[string]interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	var copied map[string]interface{}
+	return copied, json.Unmarshal(data, &copied)
+}
+
+func copyDocuments(docs map[string]map[string]interface{}) map[string]map[string]interface{} {
+	copied := make(map[string]map[string]interface{}, len(docs))
+	for id, doc := range docs {
+		copied[id], _ = copyDocument(doc)
+	}
+	return copied
+}
+
+var _ internal.Store = (*Store)(nil)
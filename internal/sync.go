@@ -0,0 +1,553 @@
+package internal
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// SyncChange is one memory's state sent between memo instances: its
+// document and vector, or a tombstone when it was deleted
+type SyncChange struct {
+	ID string `json:"id"`
+	// Rev is the memory's revision counter (1 until it first changes)
+	Rev       int                    `json:"rev,omitempty"`
+	Doc       map[string]interface{} `json:"doc,omitempty"`
+	Embedding []float64              `json:"embedding,omitempty"`
+	// Deleted is when the memory was deleted; only tombstones have it
+	Deleted string `json:"deleted,omitempty"`
+}
+
+// SyncBatch is the changes one instance sends, stamped with its clock
+type SyncBatch struct {
+	Now            string       `json:"now"`
+	EmbeddingModel string       `json:"embedding_model"`
+	SchemaVersion  int          `json:"schema_version"`
+	Changes        []SyncChange `json:"changes"`
+}
+
+// SyncConflict is a memory both sides changed differently. The most
+// recent change won; Receiver and Sender hold both contents ("" for a
+// deleted memory).
+type SyncConflict struct {
+	ID        string `json:"id"`
+	Receiver  string `json:"receiver"`
+	Sender    string `json:"sender"`
+	SenderWon bool   `json:"sender_won"`
+}
+
+// SyncReport counts what applying a batch did
+type SyncReport struct {
+	// Sent is how many changes a push sent
+	Sent                                 int `json:"sent,omitempty"`
+	Created, Updated, Deleted, Unchanged int
+	Conflicts                            []SyncConflict `json:"conflicts,omitempty"`
+	// Unembedded lists memories that could not be embedded
+	Unembedded []string `json:"unembedded,omitempty"`
+}
+
+// syncPush is the body of a push: the batch, plus when the pusher last
+// pulled (by the receiver's clock)
+type syncPush struct {
+	Base  string    `json:"base"`
+	Batch SyncBatch `json:"batch"`
+}
+
+type syncPushResult struct {
+	Now    string     `json:"now"`
+	Report SyncReport `json:"report"`
+}
+
+// memoryRev is a memory's revision counter
+func memoryRev(m *Memory) int {
+	if n := len(m.Revisions); n > 0 {
+		return m.Revisions[n-1].Rev
+	}
+	return 1
+}
+
+// descends reports whether a's history contains b's current state, i.e.
+// a is b plus later changes (or the same)
+func descends(a, b *Memory) bool {
+	if a.Created != b.Created {
+		return false
+	}
+	n := len(b.Revisions)
+	if n == 0 {
+		return true
+	}
+	last := b.Revisions[n-1]
+	for _, r := range a.Revisions {
+		if r.Rev == last.Rev && r.Time == last.Time && r.Content == last.Content {
+			return true
+		}
+	}
+	return false
+}
+
+// reverified reports whether a and b share the same history but a was
+// verified later, so only its git state is newer
+func reverified(a, b *Memory) bool {
+	return descends(a, b) && descends(b, a) && a.Verified > b.Verified
+}
+
+// ChangesSince collects every memory changed or deleted since a time on
+// this instance's clock (everything when since is empty). The audit log
+// is the change feed: the latest entry per memory decides whether it is
+// sent as a document or as a tombstone.
+func ChangesSince(s Store, since string) (*SyncBatch, error) {
+	batch := &SyncBatch{Now: Now(), EmbeddingModel: settings.Embeddings.Model}
+	var err error
+	if batch.SchemaVersion, err = s.SchemaVersion(); err != nil {
+		return nil, err
+	}
+	entries, err := s.ReadLog(LogQuery{Since: since})
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	seen := make(map[string]bool)
+	deleted := make(map[string]string)
+	for _, e := range entries {
+		if e.ID == "" {
+			continue
+		}
+		if !seen[e.ID] {
+			seen[e.ID] = true
+			ids = append(ids, e.ID)
+		}
+		if e.After == "" {
+			deleted[e.ID] = e.Time
+		} else {
+			delete(deleted, e.ID)
+		}
+	}
+	if since == "" {
+		// Memories stored before the audit log existed have no entries
+		all, err := s.GetAllMemoryIDs()
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range all {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+
+	for _, id := range ids {
+		m, err := s.Peek(id)
+		if err != nil {
+			if when, ok := deleted[id]; ok {
+				batch.Changes = append(batch.Changes, SyncChange{ID: id, Deleted: when})
+			}
+			continue
+		}
+		doc, err := memoryToDocument(m)
+		if err != nil {
+			return nil, err
+		}
+		embedding, _ := s.GetEmbeddingByID(id)
+		batch.Changes = append(batch.Changes, SyncChange{ID: id, Rev: memoryRev(m), Doc: doc, Embedding: embedding})
+	}
+	return batch, nil
+}
+
+// ApplyChanges merges a batch from another instance. base is when this
+// instance last synced with the sender, by its own clock ("" if never).
+// A change whose history contains ours fast-forwards; one ours already
+// contains is ignored; otherwise the most recent change wins and the
+// conflict is reported. Tombstones move memories to the trash.
+func ApplyChanges(s Store, batch *SyncBatch, base string) (*SyncReport, error) {
+	if batch.SchemaVersion > SchemaVersion {
+		return nil, fmt.Errorf("sender has schema version %d, newer than this binary (%d)", batch.SchemaVersion, SchemaVersion)
+	}
+	reuseVectors := batch.EmbeddingModel == settings.Embeddings.Model
+
+	// Memories deleted here since the last sync, and when
+	entries, err := s.ReadLog(LogQuery{Since: base})
+	if err != nil {
+		return nil, err
+	}
+	deletedHere := make(map[string]string)
+	for _, e := range entries {
+		if e.ID == "" {
+			continue
+		}
+		if e.After == "" {
+			deletedHere[e.ID] = e.Time
+		} else {
+			delete(deletedHere, e.ID)
+		}
+	}
+
+	report := &SyncReport{}
+	for _, c := range batch.Changes {
+		if err := ValidateID(c.ID); err != nil {
+			return report, err
+		}
+		local, _ := s.Peek(c.ID)
+		if c.Deleted != "" {
+			switch {
+			case local == nil:
+				report.Unchanged++
+			case lastChanged(local) >= c.Deleted:
+				// Changed here after (or as) it was deleted there: keep the change
+				report.Conflicts = append(report.Conflicts, SyncConflict{ID: c.ID, Receiver: local.Content})
+			default:
+				if err := TrashMemory(s, c.ID); err != nil {
+					return report, err
+				}
+				report.Deleted++
+			}
+			continue
+		}
+
+		for _, m := range migrations[batch.SchemaVersion:] {
+			if err := m.Apply(c.Doc); err != nil {
+				return report, fmt.Errorf("migration v%d on %s: %w", m.Version, c.ID, err)
+			}
+		}
+		remote, err := documentToMemory(c.Doc)
+		if err != nil {
+			return report, fmt.Errorf("%s: %w", c.ID, err)
+		}
+		remote.ID = c.ID
+
+		if local == nil {
+			if when, ok := deletedHere[c.ID]; ok {
+				conflict := SyncConflict{ID: c.ID, Sender: remote.Content, SenderWon: lastChanged(remote) >= when}
+				report.Conflicts = append(report.Conflicts, conflict)
+				if !conflict.SenderWon {
+					continue
+				}
+			}
+			if err := s.PutDocument(c.ID, c.Doc); err != nil {
+				return report, err
+			}
+			journal.created(c.ID)
+			if err := auditMemory(s, "sync", c.ID, nil, remote); err != nil {
+				return report, err
+			}
+			// A trashed copy would otherwise come back a second time
+			s.DeleteTrash(c.ID)
+			syncEmbedding(s, c, remote, reuseVectors, report)
+			report.Created++
+			continue
+		}
+
+		switch {
+		case hashMemory(local) == hashMemory(remote), descends(local, remote) && !reverified(remote, local):
+			report.Unchanged++
+			continue
+		case !descends(remote, local):
+			// Diverged: last writer wins, ties go to the higher revision
+			senderWon := lastChanged(remote) > lastChanged(local) ||
+				(lastChanged(remote) == lastChanged(local) && memoryRev(remote) > memoryRev(local))
+			report.Conflicts = append(report.Conflicts, SyncConflict{ID: c.ID, Receiver: local.Content, Sender: remote.Content, SenderWon: senderWon})
+			if !senderWon {
+				continue
+			}
+		}
+
+		contentChanged := local.Content != remote.Content
+		journal.snapshot(s, local, contentChanged)
+		if err := s.PutDocument(c.ID, c.Doc); err != nil {
+			return report, err
+		}
+		if err := auditMemory(s, "sync", c.ID, local, remote); err != nil {
+			return report, err
+		}
+		if contentChanged {
+			syncEmbedding(s, c, remote, reuseVectors, report)
+		}
+		report.Updated++
+	}
+	return report, nil
+}
+
+func syncEmbedding(s Store, c SyncChange, remote *Memory, reuse bool, report *SyncReport) {
+	imported := &ImportReport{}
+	if err := importEmbedding(s, remote, c.Embedding, reuse, imported); err != nil || len(imported.Unembedded) > 0 {
+		report.Unembedded = append(report.Unembedded, c.ID)
+	}
+}
+
+// syncHash is what the other side is known to hold for a change
+func syncHash(c SyncChange) string {
+	if c.Deleted != "" {
+		return "deleted"
+	}
+	m, err := documentToMemory(c.Doc)
+	if err != nil {
+		return ""
+	}
+	return hashMemory(m)
+}
+
+// syncRemote is what this instance remembers about one remote
+type syncRemote struct {
+	// PulledAt is the remote's clock at the last pull; the next pull asks for changes since
+	PulledAt string `json:"pulled_at,omitempty"`
+	// SyncedAt is our clock when the last pull started, the base for its conflicts
+	SyncedAt string `json:"synced_at,omitempty"`
+	// PushedAt is our clock when the last push collected its changes
+	PushedAt string `json:"pushed_at,omitempty"`
+	// Known maps memory IDs to the hash the remote has, so a push skips what it has
+	Known map[string]string `json:"known,omitempty"`
+}
+
+// syncStatePath keeps the sync cursors of the current profile, keyed by remote URL
+func syncStatePath() string {
+	return dataPath(filepath.Join("sync", settings.Profile+".json"))
+}
+
+func loadSyncState() (map[string]*syncRemote, error) {
+	state := make(map[string]*syncRemote)
+	data, err := os.ReadFile(syncStatePath())
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("corrupt %s: %w", syncStatePath(), err)
+	}
+	return state, nil
+}
+
+func saveSyncState(state map[string]*syncRemote) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(syncStatePath()), 0o755); err != nil {
+		return err
+	}
+	return writeFileAtomic(syncStatePath(), data)
+}
+
+func remoteState(state map[string]*syncRemote, remote string) *syncRemote {
+	r, ok := state[remote]
+	if !ok {
+		r = &syncRemote{}
+		state[remote] = r
+	}
+	if r.Known == nil {
+		r.Known = make(map[string]string)
+	}
+	return r
+}
+
+// SyncStatus describes one remote this instance has synced with
+type SyncStatus struct {
+	URL, PulledAt, PushedAt string
+}
+
+// SyncRemotes lists the remotes with sync cursors
+func SyncRemotes() ([]SyncStatus, error) {
+	state, err := loadSyncState()
+	if err != nil {
+		return nil, err
+	}
+	var remotes []SyncStatus
+	for u, r := range state {
+		remotes = append(remotes, SyncStatus{URL: u, PulledAt: r.PulledAt, PushedAt: r.PushedAt})
+	}
+	return remotes, nil
+}
+
+// ResolveRemote turns a name from [sync.remotes] or an http(s) URL into a URL
+func ResolveRemote(name string) (string, error) {
+	if u, ok := settings.Sync.Remotes[name]; ok {
+		name = u
+	}
+	if !strings.HasPrefix(name, "http://") && !strings.HasPrefix(name, "https://") {
+		return "", fmt.Errorf("unknown remote %q (an http(s) URL or a name under [sync.remotes])", name)
+	}
+	return strings.TrimSuffix(name, "/"), nil
+}
+
+// SyncPull applies the remote's changes since the last pull
+func SyncPull(s Store, remote string) (*SyncReport, error) {
+	state, err := loadSyncState()
+	if err != nil {
+		return nil, err
+	}
+	r := remoteState(state, remote)
+	start := Now()
+
+	var batch SyncBatch
+	if err := syncRequest("GET", remote+"/sync/changes?since="+url.QueryEscape(r.PulledAt), nil, &batch); err != nil {
+		return nil, err
+	}
+	// Skip what we already hold, such as our own pushes coming back
+	changes := batch.Changes[:0]
+	for _, c := range batch.Changes {
+		if r.Known[c.ID] != syncHash(c) {
+			changes = append(changes, c)
+		}
+	}
+	batch.Changes = changes
+
+	report, err := ApplyChanges(s, &batch, r.SyncedAt)
+	if err != nil {
+		return report, err
+	}
+	for _, c := range changes {
+		r.Known[c.ID] = syncHash(c)
+	}
+	r.PulledAt, r.SyncedAt = batch.Now, start
+	return report, saveSyncState(state)
+}
+
+// SyncPush sends the changes made here since the last push, except
+// those the remote is known to have already
+func SyncPush(s Store, remote string) (*SyncReport, error) {
+	state, err := loadSyncState()
+	if err != nil {
+		return nil, err
+	}
+	r := remoteState(state, remote)
+
+	batch, err := ChangesSince(s, r.PushedAt)
+	if err != nil {
+		return nil, err
+	}
+	changes := batch.Changes[:0]
+	for _, c := range batch.Changes {
+		if r.Known[c.ID] != syncHash(c) {
+			changes = append(changes, c)
+		}
+	}
+	batch.Changes = changes
+
+	var result syncPushResult
+	if len(changes) > 0 {
+		if err := syncRequest("POST", remote+"/sync/changes", syncPush{Base: r.PulledAt, Batch: *batch}, &result); err != nil {
+			return nil, err
+		}
+	}
+	for _, c := range changes {
+		r.Known[c.ID] = syncHash(c)
+	}
+	r.PushedAt = batch.Now
+	result.Report.Sent = len(changes)
+	return &result.Report, saveSyncState(state)
+}
+
+func syncRequest(method, u string, body, out interface{}) error {
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequest(method, u, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if settings.Sync.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+settings.Sync.Token)
+	}
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("sync remote unavailable: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var msg bytes.Buffer
+		msg.ReadFrom(resp.Body)
+		return fmt.Errorf("sync remote error: %d %s", resp.StatusCode, strings.TrimSpace(msg.String()))
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// CheckServeAddr refuses to serve the store on addr without a sync token
+// unless addr only accepts connections from this machine
+func CheckServeAddr(addr string) error {
+	if settings.Sync.Token != "" {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid address %q: %w", addr, err)
+	}
+	// An empty host listens on every interface
+	if ip := net.ParseIP(host); host == "localhost" || (ip != nil && ip.IsLoopback()) {
+		return nil
+	}
+	return fmt.Errorf("refusing to serve on %s without a sync token; set sync.token (or MEMO_SYNC_TOKEN) or listen on 127.0.0.1", addr)
+}
+
+// maxSyncPush caps a push body, which holds every changed memory with its
+// vector; a first push of a large store fits with room to spare
+var maxSyncPush int64 = 256 << 20
+
+// SyncHandler serves a store to memo sync push and pull. Requests are
+// handled one at a time, each audited under its own command line and
+// undoable on its own with memo undo.
+func SyncHandler(s Store) http.Handler {
+	var mu sync.Mutex
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /sync/changes", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		batch, err := ChangesSince(s, r.URL.Query().Get("since"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(batch)
+	})
+	mux.HandleFunc("POST /sync/changes", func(w http.ResponseWriter, r *http.Request) {
+		var push syncPush
+		body := http.MaxBytesReader(w, r.Body, maxSyncPush)
+		if err := json.NewDecoder(body).Decode(&push); err != nil {
+			status := http.StatusBadRequest
+			if _, tooLarge := err.(*http.MaxBytesError); tooLarge {
+				status = http.StatusRequestEntityTooLarge
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		command := "sync serve (push from " + r.RemoteAddr + ")"
+		StartAudit("memo " + command)
+		BeginUndo(command)
+		report, err := ApplyChanges(s, &push.Batch, push.Base)
+		if undoErr := CommitUndo(s); err == nil {
+			err = undoErr
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(syncPushResult{Now: Now(), Report: *report})
+	})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token := settings.Sync.Token; token != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		mux.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,269 @@
+package internal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// useDefaultSettings loads the built-in defaults, with nowhere to reach an
+// embedding service, for tests of code that reads settings
+func useDefaultSettings(t *testing.T) {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("MEMO_CONFIG", filepath.Join(home, "config.toml"))
+	t.Setenv("MEMO_PROFILE", "")
+	t.Setenv("MEMO_BACKEND", "files")
+	t.Setenv("MEMO_SYNC_TOKEN", "")
+	t.Setenv("EMBEDDINGS_URL", "http://127.0.0.1:1/embed")
+	saved := settings
+	if _, err := LoadConfig(""); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { settings = saved })
+}
+
+// history builds a memory whose revisions hold the given contents a
+// minute apart, the last being current; one content means it never changed
+func history(created string, contents ...string) *Memory {
+	m := &Memory{ID: "m1", Type: "fact", Content: contents[len(contents)-1], Tags: []string{}, Created: created, Accessed: created}
+	if len(contents) == 1 {
+		return m
+	}
+	start, _ := time.Parse(time.RFC3339, created)
+	for i, c := range contents {
+		when := start.Add(time.Duration(i) * time.Minute).Format(time.RFC3339)
+		m.Revisions = append(m.Revisions, Revision{Rev: i + 1, Content: c, Tags: []string{}, Time: when})
+	}
+	return m
+}
+
+func TestDescends(t *testing.T) {
+	const t0 = "2026-01-01T00:00:00Z"
+	base := history(t0, "a")
+	one := history(t0, "a", "b")
+	two := history(t0, "a", "b", "c")
+	fork := history(t0, "a", "x")
+	other := history("2026-02-01T00:00:00Z", "a")
+
+	tests := []struct {
+		name string
+		a, b *Memory
+		want bool
+	}{
+		{"itself", one, one, true},
+		{"anything descends an unchanged memory", two, base, true},
+		{"later revision", two, one, true},
+		{"earlier revision", one, two, false},
+		{"forked", fork, one, false},
+		{"forked the other way", one, fork, false},
+		{"different memory with the same ID", other, base, false},
+	}
+	for _, tt := range tests {
+		if got := descends(tt.a, tt.b); got != tt.want {
+			t.Errorf("%s: descends() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	verified := *one
+	verified.Verified = "2026-03-01T00:00:00Z"
+	if !reverified(&verified, one) || reverified(one, &verified) || reverified(two, one) {
+		t.Error("reverified should only hold for the same history verified later")
+	}
+}
+
+func TestApplyChangesLastWriterWins(t *testing.T) {
+	useDefaultSettings(t)
+	const t0 = "2026-01-01T00:00:00Z"
+
+	tests := []struct {
+		name          string
+		local, remote *Memory
+		deleted       string // the remote's tombstone time, instead of remote
+		wantContent   string // "" when the memory should be gone
+		wantConflict  bool
+		senderWon     bool
+	}{
+		{name: "remote is ahead", local: history(t0, "a", "b"), remote: history(t0, "a", "b", "c"), wantContent: "c"},
+		{name: "local is ahead", local: history(t0, "a", "b", "c"), remote: history(t0, "a", "b"), wantContent: "c"},
+		{name: "new on the remote", remote: history(t0, "a"), wantContent: "a"},
+		{name: "diverged, remote changed last", local: history(t0, "a", "local"), remote: history(t0, "a", "x", "remote"),
+			wantContent: "remote", wantConflict: true, senderWon: true},
+		{name: "diverged, local changed last", local: history(t0, "a", "x", "local"), remote: history(t0, "a", "remote"),
+			wantContent: "local", wantConflict: true},
+		{name: "deleted there after the last change here", local: history(t0, "a", "b"), deleted: "2026-06-01T00:00:00Z"},
+		{name: "changed here after the delete there", local: history(t0, "a", "b"), deleted: "2025-06-01T00:00:00Z",
+			wantContent: "b", wantConflict: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewFileStore(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.local != nil {
+				doc, _ := memoryToDocument(tt.local)
+				if err := s.PutDocument("m1", doc); err != nil {
+					t.Fatal(err)
+				}
+			}
+			c := SyncChange{ID: "m1", Deleted: tt.deleted}
+			if tt.remote != nil {
+				c.Doc, _ = memoryToDocument(tt.remote)
+				c.Rev = memoryRev(tt.remote)
+			}
+
+			report, err := ApplyChanges(s, &SyncBatch{SchemaVersion: SchemaVersion, Changes: []SyncChange{c}}, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := ""
+			if m, err := s.Peek("m1"); err == nil {
+				got = m.Content
+			}
+			if got != tt.wantContent {
+				t.Errorf("content %q, want %q", got, tt.wantContent)
+			}
+			if len(report.Conflicts) > 0 != tt.wantConflict {
+				t.Fatalf("conflicts %+v, want one: %v", report.Conflicts, tt.wantConflict)
+			}
+			if tt.wantConflict && report.Conflicts[0].SenderWon != tt.senderWon {
+				t.Errorf("sender won: %v, want %v", report.Conflicts[0].SenderWon, tt.senderWon)
+			}
+		})
+	}
+}
+
+func TestApplyChangesTakesANewerVerification(t *testing.T) {
+	useDefaultSettings(t)
+	s, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	local := history("2026-01-01T00:00:00Z", "a", "b")
+	doc, _ := memoryToDocument(local)
+	if err := s.PutDocument("m1", doc); err != nil {
+		t.Fatal(err)
+	}
+
+	remote := *local
+	remote.Verified, remote.Commit = "2026-05-01T00:00:00Z", "abc123"
+	remote.Anchors = []Anchor{{Path: "main.go", Blob: "f00"}}
+	doc, _ = memoryToDocument(&remote)
+	report, err := ApplyChanges(s, &SyncBatch{SchemaVersion: SchemaVersion, Changes: []SyncChange{{ID: "m1", Doc: doc}}}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, _ := s.Peek("m1")
+	if report.Updated != 1 || m.Commit != "abc123" || len(m.Revisions) != 2 {
+		t.Errorf("report %+v, memory %+v", report, m)
+	}
+
+	// Sent back, the older verification changes nothing
+	doc, _ = memoryToDocument(local)
+	if report, err = ApplyChanges(s, &SyncBatch{SchemaVersion: SchemaVersion, Changes: []SyncChange{{ID: "m1", Doc: doc}}}, ""); err != nil {
+		t.Fatal(err)
+	}
+	if report.Unchanged != 1 {
+		t.Errorf("an older verification was applied: %+v", report)
+	}
+}
+
+func TestApplyChangesRejectsUnsafeIDs(t *testing.T) {
+	useDefaultSettings(t)
+	s, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, _ := memoryToDocument(history("2026-01-01T00:00:00Z", "a"))
+	for _, id := range []string{"../../evil", "log"} {
+		batch := &SyncBatch{SchemaVersion: SchemaVersion, Changes: []SyncChange{{ID: id, Doc: doc}}}
+		if _, err := ApplyChanges(s, batch, ""); err == nil {
+			t.Errorf("applied a change with the unsafe ID %q", id)
+		}
+	}
+}
+
+func TestCheckServeAddr(t *testing.T) {
+	useDefaultSettings(t)
+	for addr, ok := range map[string]bool{
+		"127.0.0.1:7979": true,
+		"localhost:7979": true,
+		"[::1]:7979":     true,
+		"0.0.0.0:7979":   false,
+		":7979":          false,
+		"10.0.0.5:7979":  false,
+		"example.com:80": false,
+		"no-port":        false,
+	} {
+		if err := CheckServeAddr(addr); (err == nil) != ok {
+			t.Errorf("CheckServeAddr(%q) = %v without a token", addr, err)
+		}
+	}
+
+	settings.Sync.Token = "s3cret"
+	if err := CheckServeAddr("0.0.0.0:7979"); err != nil {
+		t.Errorf("CheckServeAddr refused a non-loopback address with a token: %v", err)
+	}
+}
+
+func TestSyncHandlerRequiresTheToken(t *testing.T) {
+	useDefaultSettings(t)
+	settings.Sync.Token = "s3cret"
+	s, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(SyncHandler(s))
+	defer srv.Close()
+
+	for auth, want := range map[string]int{
+		"":              http.StatusUnauthorized,
+		"Bearer s3cre":  http.StatusUnauthorized,
+		"Bearer s3cret": http.StatusOK,
+		"s3cret":        http.StatusUnauthorized,
+	} {
+		req, _ := http.NewRequest("GET", srv.URL+"/sync/changes", nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("Authorization %q: status %d, want %d", auth, resp.StatusCode, want)
+		}
+	}
+}
+
+func TestSyncHandlerLimitsPushes(t *testing.T) {
+	useDefaultSettings(t)
+	saved := maxSyncPush
+	maxSyncPush = 1 << 10
+	t.Cleanup(func() { maxSyncPush = saved })
+	s, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(SyncHandler(s))
+	defer srv.Close()
+
+	body := `{"batch": {"changes": [{"id": "m1", "doc": {"content": "` + strings.Repeat("x", 2<<10) + `"}}]}}`
+	resp, err := http.Post(srv.URL+"/sync/changes", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("status %d for an oversized push, want 413", resp.StatusCode)
+	}
+	if ids, _ := s.GetAllMemoryIDs(); len(ids) != 0 {
+		t.Errorf("an oversized push stored %v", ids)
+	}
+}
@@ -0,0 +1,120 @@
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// TrashEntry is a deleted memory kept for restoring, with its embedding
+type TrashEntry struct {
+	ID        string                 `json:"id"`
+	Deleted   string                 `json:"deleted"`
+	Doc       map[string]interface{} `json:"doc"`
+	Embedding []float64              `json:"embedding,omitempty"`
+}
+
+// sortTrash orders entries most recently deleted first
+func sortTrash(entries []TrashEntry) {
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Deleted > entries[j].Deleted
+	})
+}
+
+// TrashMemory moves a memory and its embedding to the trash, then drops
+// whatever has outlived trash.retention_days
+func TrashMemory(s Store, id string) error {
+	m, err := s.Peek(id)
+	if err != nil {
+		return err
+	}
+	doc, err := memoryToDocument(m)
+	if err != nil {
+		return err
+	}
+	embedding, _ := s.GetEmbeddingByID(id)
+
+	entry := TrashEntry{ID: id, Deleted: Now(), Doc: doc, Embedding: embedding}
+	if err := s.PutTrash(entry); err != nil {
+		return err
+	}
+	if err := s.Forget(id); err != nil {
+		return err
+	}
+	journal.trashed(id)
+	if err := auditMemory(s, "forget", id, m, nil); err != nil {
+		return err
+	}
+
+	return ExpireTrash(s)
+}
+
+// ExpireTrash drops trash entries older than trash.retention_days; 0 keeps
+// them until memo trash empty
+func ExpireTrash(s Store) error {
+	if settings.Trash.RetentionDays <= 0 {
+		return nil
+	}
+	_, err := PurgeTrash(s, settings.Trash.RetentionDays)
+	return err
+}
+
+// RestoreMemory moves a memory and its embedding back out of the trash
+func RestoreMemory(s Store, id string) (*Memory, error) {
+	entries, err := s.TrashEntries()
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if e.ID != id {
+			continue
+		}
+		if _, err := s.Peek(id); err == nil {
+			return nil, fmt.Errorf("memory %s already exists", id)
+		}
+		if err := s.PutDocument(id, e.Doc); err != nil {
+			return nil, err
+		}
+		if len(e.Embedding) > 0 {
+			if err := s.EmbedMemory(id, e.Embedding); err != nil {
+				return nil, err
+			}
+		}
+		if err := s.DeleteTrash(id); err != nil {
+			return nil, err
+		}
+		m, err := documentToMemory(e.Doc)
+		if err != nil {
+			return nil, err
+		}
+		journal.created(id)
+		return m, auditMemory(s, "restore", id, nil, m)
+	}
+	return nil, fmt.Errorf("%s is not in the trash", id)
+}
+
+// PurgeTrash permanently deletes trash entries older than days (0 empties
+// the trash) and returns how many went
+func PurgeTrash(s Store, days int) (int, error) {
+	entries, err := s.TrashEntries()
+	if err != nil {
+		return 0, err
+	}
+	cutoff := time.Now().UTC().AddDate(0, 0, -days).Format(timeLayout)
+	purged := 0
+	for _, e := range entries {
+		if days > 0 && e.Deleted >= cutoff {
+			continue
+		}
+		if err := s.DeleteTrash(e.ID); err != nil {
+			return purged, err
+		}
+		if m, err := documentToMemory(e.Doc); err == nil {
+			if err := auditMemory(s, "purge", e.ID, m, nil); err != nil {
+				return purged, err
+			}
+		}
+		purged++
+	}
+	return purged, nil
+}
@@ -0,0 +1,52 @@
+package internal
+
+import "testing"
+
+func TestForgetKeepsTheTrash(t *testing.T) {
+	useDefaultSettings(t)
+	s, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := TrashEntry{ID: "old", Deleted: "2020-01-01T00:00:00Z", Doc: map[string]interface{}{
+		"id": "old", "type": "fact", "content": "Deleted long ago", "tags": []interface{}{},
+		"created": "2020-01-01T00:00:00Z", "accessed": "2020-01-01T00:00:00Z",
+	}}
+	forget := func() string {
+		t.Helper()
+		if err := s.PutTrash(old); err != nil {
+			t.Fatal(err)
+		}
+		m := &Memory{Type: "fact", Content: "Builds take five minutes", Tags: []string{}}
+		if err := s.Remember(m); err != nil {
+			t.Fatal(err)
+		}
+		if err := TrashMemory(s, m.ID); err != nil {
+			t.Fatal(err)
+		}
+		return m.ID
+	}
+
+	t.Run("retention 0 keeps everything", func(t *testing.T) {
+		settings.Trash.RetentionDays = 0
+		id := forget()
+		if entries, _ := s.TrashEntries(); len(entries) != 2 {
+			t.Errorf("%d trash entries, want the old one and the forgotten one", len(entries))
+		}
+		m, err := RestoreMemory(s, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.Content != "Builds take five minutes" {
+			t.Errorf("restored %q", m.Content)
+		}
+	})
+	t.Run("retention drops expired entries", func(t *testing.T) {
+		settings.Trash.RetentionDays = 30
+		id := forget()
+		entries, _ := s.TrashEntries()
+		if len(entries) != 1 || entries[0].ID != id {
+			t.Errorf("trash %+v, want only %s", entries, id)
+		}
+	})
+}
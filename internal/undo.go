@@ -0,0 +1,172 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+)
+
+// undoDepth is how many operations memo undo can walk back
+const undoDepth = 20
+
+// Operation records what one command changed, so memo undo can reverse it
+type Operation struct {
+	Command string   `json:"command"`
+	Time    string   `json:"time"`
+	Changes []Change `json:"changes"`
+	// Irreversible says what the command did that undo cannot reverse; such
+	// an operation is only dropped, so undo never reverses an older one instead
+	Irreversible string `json:"irreversible,omitempty"`
+}
+
+// Change is one memory's state before an operation touched it
+type Change struct {
+	ID string `json:"id"`
+	// Before is the memory document beforehand; nil if the operation created it
+	Before map[string]interface{} `json:"before,omitempty"`
+	// Embedding is the vector beforehand, kept when the content changed
+	Embedding []float64 `json:"embedding,omitempty"`
+	// Trashed is set when the operation moved the memory to the trash
+	Trashed bool `json:"trashed,omitempty"`
+}
+
+// journal collects the changes of the running command between BeginUndo
+// and CommitUndo. While it is nil nothing is recorded.
+var journal *Operation
+
+// BeginUndo starts recording changes for a command
+func BeginUndo(command string) {
+	journal = &Operation{Command: command, Time: Now()}
+}
+
+// CommitUndo saves what the command changed, if anything, as the newest
+// undoable operation
+func CommitUndo(s Store) error {
+	op := journal
+	journal = nil
+	if op == nil || (len(op.Changes) == 0 && op.Irreversible == "") {
+		return nil
+	}
+	return s.PushUndo(op)
+}
+
+func (op *Operation) change(id string) *Change {
+	for i := range op.Changes {
+		if op.Changes[i].ID == id {
+			return &op.Changes[i]
+		}
+	}
+	op.Changes = append(op.Changes, Change{ID: id})
+	return &op.Changes[len(op.Changes)-1]
+}
+
+func (op *Operation) has(id string) bool {
+	for _, c := range op.Changes {
+		if c.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// snapshot records a memory's state before its first change in this
+// operation (the embedding only if the content is changing)
+func (op *Operation) snapshot(s Store, before *Memory, contentChanged bool) {
+	if op == nil || op.has(before.ID) {
+		return
+	}
+	doc, err := memoryToDocument(before)
+	if err != nil {
+		return
+	}
+	c := op.change(before.ID)
+	c.Before = doc
+	if contentChanged {
+		c.Embedding, _ = s.GetEmbeddingByID(before.ID)
+	}
+}
+
+// snapshotDocument is snapshot for a raw document that may not decode as
+// a memory, such as one memo doctor is about to repair
+func (op *Operation) snapshotDocument(id string, doc map[string]interface{}) {
+	if op == nil || op.has(id) {
+		return
+	}
+	op.change(id).Before = copyDocument(doc)
+}
+
+func (op *Operation) created(id string) {
+	if op != nil {
+		op.change(id)
+	}
+}
+
+func (op *Operation) trashed(id string) {
+	if op != nil {
+		op.change(id).Trashed = true
+	}
+}
+
+func (op *Operation) irreversible(what string) {
+	if op != nil && op.Irreversible == "" {
+		op.Irreversible = what
+	}
+}
+
+// Undo reverses the newest recorded operation: memories it created go to
+// the trash, trashed ones come back and changed ones get their earlier
+// document and embedding back
+func Undo(s Store) (*Operation, error) {
+	op, err := s.PopUndo()
+	if err != nil {
+		return nil, err
+	}
+	if op == nil {
+		return nil, fmt.Errorf("nothing to undo")
+	}
+	if op.Irreversible != "" {
+		return nil, fmt.Errorf("memo %s cannot be undone (%s); run memo undo again to go further back", op.Command, op.Irreversible)
+	}
+
+	var failed []string
+	for i := len(op.Changes) - 1; i >= 0; i-- {
+		c := op.Changes[i]
+		if err := undoChange(s, c); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", c.ID, err))
+		}
+	}
+	if len(failed) > 0 {
+		return op, fmt.Errorf("partly undone:\n  %s", strings.Join(failed, "\n  "))
+	}
+	return op, nil
+}
+
+func undoChange(s Store, c Change) error {
+	current, _ := s.Peek(c.ID)
+	// Both the project the memory is in now and the one it goes back to change
+	MarkBriefsStale(s, current)
+	if c.Trashed {
+		restored, err := RestoreMemory(s, c.ID)
+		if err != nil {
+			return err
+		}
+		MarkBriefsStale(s, restored)
+	} else if c.Before == nil {
+		return TrashMemory(s, c.ID)
+	}
+	if c.Before == nil {
+		return nil
+	}
+	if err := s.PutDocument(c.ID, c.Before); err != nil {
+		return err
+	}
+	if before, err := documentToMemory(c.Before); err == nil {
+		MarkBriefsStale(s, before)
+		if err := auditMemory(s, "undo", c.ID, current, before); err != nil {
+			return err
+		}
+	}
+	if len(c.Embedding) > 0 {
+		return s.EmbedMemory(c.ID, c.Embedding)
+	}
+	return nil
+}